@@ -3,18 +3,27 @@ package main
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
 
+	"github.com/go-sod/sod/internal/alert"
 	"github.com/go-sod/sod/internal/buildinfo"
 	"github.com/go-sod/sod/internal/collect"
 	sod "github.com/go-sod/sod/internal/config"
+	"github.com/go-sod/sod/internal/entity"
+	"github.com/go-sod/sod/internal/feedback"
 	"github.com/go-sod/sod/internal/logging"
 	"github.com/go-sod/sod/internal/predict"
+	"github.com/go-sod/sod/internal/remotewrite"
+	"github.com/go-sod/sod/internal/rpc"
 	"github.com/go-sod/sod/internal/server"
 	"github.com/go-sod/sod/internal/setup"
 	"github.com/go-sod/sod/internal/shutdown"
+	"github.com/go-sod/sod/internal/stream"
+	"github.com/go-sod/sod/internal/telemetry"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 func main() {
@@ -85,15 +94,76 @@ func run(ctx context.Context, cancel func()) error {
 		return fmt.Errorf("collect.NewHandler: %w", err)
 	}
 
-	mux.Handle("/predict", predictHandler)
+	thresholdHandler, err := predict.NewThresholdHandler(outlier)
+	if err != nil {
+		return fmt.Errorf("predict.NewThresholdHandler: %w", err)
+	}
+
+	entityConfigHandler, err := entity.NewConfigHandler(outlier)
+	if err != nil {
+		return fmt.Errorf("entity.NewConfigHandler: %w", err)
+	}
+	feedbackHandler, err := feedback.NewHandler(outlier)
+	if err != nil {
+		return fmt.Errorf("feedback.NewHandler: %w", err)
+	}
+
+	deadLetterHandler, err := alert.NewDeadLetterHandler(notifier)
+	if err != nil {
+		return fmt.Errorf("alert.NewDeadLetterHandler: %w", err)
+	}
+
+	incidentHandler, err := alert.NewIncidentHandler(notifier)
+	if err != nil {
+		return fmt.Errorf("alert.NewIncidentHandler: %w", err)
+	}
+
+	historyHandler, err := alert.NewHistoryHandler(notifier)
+	if err != nil {
+		return fmt.Errorf("alert.NewHistoryHandler: %w", err)
+	}
+
+	streamHandler, err := stream.NewHandler(&config.Stream, outlier)
+	if err != nil {
+		return fmt.Errorf("stream.NewHandler: %w", err)
+	}
+
+	if err := prometheus.Register(telemetry.NewDatasetCollector(outlier.DatasetSizes)); err != nil {
+		return fmt.Errorf("prometheus.Register: %w", err)
+	}
+	if err := prometheus.Register(telemetry.NewQueueCollector(outlier.QueueDepths)); err != nil {
+		return fmt.Errorf("prometheus.Register: %w", err)
+	}
+
+	mux.Handle("/predict", telemetry.InstrumentHandler("predict", predictHandler))
+	mux.Handle("/threshold", telemetry.InstrumentHandler("threshold", thresholdHandler))
+	mux.Handle("/entities/config", telemetry.InstrumentHandler("entity_config", entityConfigHandler))
+	mux.Handle("/feedback", telemetry.InstrumentHandler("feedback", feedbackHandler))
+	mux.Handle("/alerts", telemetry.InstrumentHandler("alert_history", historyHandler))
+	mux.Handle("/alerts/dead-letters", telemetry.InstrumentHandler("alert_dead_letters", deadLetterHandler))
+	mux.Handle("/alerts/incidents", telemetry.InstrumentHandler("alert_incidents", incidentHandler))
+	mux.Handle("/alerts/incidents/", telemetry.InstrumentHandler("alert_incidents", incidentHandler))
+	mux.Handle("/stream/outliers", telemetry.InstrumentHandler("stream_outliers", streamHandler))
 	mux.Handle("/health", server.HandleHealth(ctx))
+	mux.Handle("/metrics", telemetry.Handler())
 
 	if config.SvcModeType == sod.SvcModeTypeCollect {
 		collectHandler, err := collect.NewHandler(&config.Collect, outlier)
 		if err != nil {
 			return fmt.Errorf("collect.NewHandler: %w", err)
 		}
-		mux.Handle("/collect", collectHandler)
+		mux.Handle("/collect", telemetry.InstrumentHandler("collect", collectHandler))
+
+		// the remote write without the mappings would accept the samples and drop them
+		if len(config.RemoteWrite.Mappings) > 0 {
+			remoteWriteHandler, err := remotewrite.NewHandler(ctx, &config.RemoteWrite, outlier)
+			if err != nil {
+				return fmt.Errorf("remotewrite.NewHandler: %w", err)
+			}
+			mux.Handle("/api/v1/write", telemetry.InstrumentHandler("remote_write", remoteWriteHandler))
+		} else {
+			logging.FromContext(ctx).Info("remote write is disabled, SOD_REMOTE_WRITE_MAPPINGS is not defined")
+		}
 	}
 
 	go func() {
@@ -102,11 +172,31 @@ func run(ctx context.Context, cancel func()) error {
 		}
 	}()
 
+	if config.GRPC.Addr != "" {
+		l, err := net.Listen("tcp", config.GRPC.Addr)
+		if err != nil {
+			return fmt.Errorf("failed to create grpc listener on %s: %w", config.GRPC.Addr, err)
+		}
+		grpcSrv := rpc.New(&config.GRPC, outlier)
+		go func() {
+			if err := rpc.Serve(ctx, grpcSrv, l); err != nil {
+				cancel()
+			}
+		}()
+	}
+
 	go func() {
 		if err := http.ListenAndServe("0.0.0.0:8080", nil); err != nil {
 			cancel()
 		}
 	}()
 
-	return <-shutdownCh
+	// every service reports its shutdown, the dispatcher flushes the drained queues before the notifier stops
+	var shutdownErr error
+	for i := 0; i < shutdownCount; i++ {
+		if err := <-shutdownCh; err != nil && shutdownErr == nil {
+			shutdownErr = err
+		}
+	}
+	return shutdownErr
 }
@@ -1,36 +1,42 @@
 package alert
 
 import (
-	"bytes"
-	"compress/gzip"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
-	"io/ioutil"
-	"net/http"
-	"net/url"
+	"regexp"
+	"sort"
 	"sync"
 	"time"
 
 	alertDb "github.com/go-sod/sod/internal/alert/database"
 	"github.com/go-sod/sod/internal/alert/model"
 	"github.com/go-sod/sod/internal/database"
-	"github.com/go-sod/sod/internal/httputil"
 	"github.com/go-sod/sod/internal/logging"
 	metricModel "github.com/go-sod/sod/internal/metric/model"
+	"github.com/go-sod/sod/internal/telemetry"
 	"github.com/go-sod/sod/pkg/rworker"
+	"github.com/google/uuid"
 )
 
 type ProvideFn = func(chan<- error) (Manager, error)
 
-const UserAgent = "SOD/0.1"
+// the reasons of the outliers not notified by the manager
+const (
+	ReasonAcknowledged = "acknowledged"
+	ReasonSilenced     = "silenced"
+	ReasonUnrouted     = "unrouted"
+)
 
 type Options struct {
 	maxConcurrentRequest int
 	requestTimeout       time.Duration
 	alertInterval        time.Duration
 	targets              Targets
+	sinks                SinkConfigs
+	routes               Routes
+	retry                retryPolicy
+	resolveAfter         int
+	historyRetention     time.Duration
 }
 
 type Option func(*manager)
@@ -53,46 +59,119 @@ func WithTargets(m Targets) Option {
 	}
 }
 
-type data struct {
-	NormalVec  []float64   `json:"norm"`
-	OutlierVec []float64   `json:"outlier"`
-	CreatedAt  time.Time   `json:"createdAt"`
-	Extra      interface{} `json:"extra"`
+func WithSinks(sinks SinkConfigs) Option {
+	return func(o *manager) {
+		o.opts.sinks = sinks
+	}
+}
+
+func WithRoutes(routes Routes) Option {
+	return func(o *manager) {
+		o.opts.routes = routes
+	}
+}
+
+func WithRequestTimeout(t time.Duration) Option {
+	return func(o *manager) {
+		o.opts.requestTimeout = t
+	}
+}
+
+func WithMaxRetries(n int) Option {
+	return func(o *manager) {
+		o.opts.retry.maxRetries = n
+	}
+}
+
+// WithBackoff sets the delay before the first retry and the limit of the exponentially growing delays
+func WithBackoff(initial, max time.Duration) Option {
+	return func(o *manager) {
+		o.opts.retry.initialBackoff = initial
+		o.opts.retry.maxBackoff = max
+	}
+}
+
+// WithResolveAfter sets the number of the consecutive normal points resolving the incident
+func WithResolveAfter(n int) Option {
+	return func(o *manager) {
+		o.opts.resolveAfter = n
+	}
 }
 
-type request struct {
-	EntityID string `json:"entityId"`
-	Data     []data `json:"data"`
+// WithHistoryRetention sets the time the delivery results are kept, the zero time disables the history
+func WithHistoryRetention(t time.Duration) Option {
+	return func(o *manager) {
+		o.opts.historyRetention = t
+	}
 }
 
 func New(db *database.DB, shutdownCh chan<- error, opts ...Option) (*manager, error) {
 	m := &manager{
 		alertDB:    alertDb.New(db),
 		shutdownCh: shutdownCh,
-		targets:    Targets{},
-		alerts:     map[string][]metricModel.Metric{},
+		opts: Options{
+			maxConcurrentRequest: 64,
+			requestTimeout:       10 * time.Second,
+			alertInterval:        5 * time.Second,
+			resolveAfter:         10,
+			retry: retryPolicy{
+				maxRetries:     3,
+				initialBackoff: time.Second,
+				maxBackoff:     time.Minute,
+			},
+		},
+		sinks:      map[string]Sink{},
+		policies:   map[string]retryPolicy{},
+		queue:      map[string][]model.Alert{},
+		incidents:  map[string]*model.Incident{},
+		normalRuns: map[string]int{},
+		delivering: map[string]bool{},
 	}
 	for _, f := range opts {
 		f(m)
 	}
-	for _, target := range m.targets {
-		if _, ok := m.clients[target.EntityID]; !ok {
-			client, err := httputil.NewClientFromConfig(target.HTTPConfig, true)
-			if err != nil {
-				return nil, fmt.Errorf("unable crate client for entity %s: %w", target.EntityID, err)
-			}
-			m.clients[target.EntityID] = client
+
+	sinks := append(SinkConfigs{}, m.opts.sinks...)
+	routes := append(Routes{}, m.opts.routes...)
+	for _, target := range m.opts.targets {
+		sink := target.sink()
+		sinks = append(sinks, sink)
+		routes = append(routes, Route{Regex: "^" + regexp.QuoteMeta(target.EntityID) + "$", Sinks: []string{sink.Name}})
+	}
+	for _, cfg := range sinks {
+		if _, ok := m.sinks[cfg.Name]; ok {
+			return nil, fmt.Errorf("duplicate sink %s", cfg.Name)
+		}
+		sink, err := newSink(cfg, m.opts.requestTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("unable create sink: %w", err)
 		}
+		m.sinks[cfg.Name] = sink
+		m.policies[cfg.Name] = m.retryPolicy(cfg.Retry)
+	}
+	router, err := newRouter(routes, m.sinks)
+	if err != nil {
+		return nil, fmt.Errorf("invalid alert routes: %w", err)
 	}
+	for name, base := range router.bases {
+		m.policies[name] = m.policies[base]
+	}
+	m.router = router
 	return m, nil
 }
 
 type Notifier interface {
+	// Notify queues the outliers for the delivery, the first outlier opens the incident of the entity
 	Notify(metrics ...metricModel.Metric)
+	// Observe accepts every processed metric, the run of the normal points resolves the incident
+	Observe(metric metricModel.Metric)
 }
 
 type Manager interface {
 	Notifier
+	DeadLetterer
+	IncidentManager
+	HistoryReader
 	Run(context.Context) error
 	Stop()
 }
@@ -102,19 +181,31 @@ type manager struct {
 	opts       Options
 	alertDB    *alertDb.DB
 	shutdownCh chan<- error
-	targets    Targets
-	clients    map[string]*http.Client
-	alerts     map[string][]metricModel.Metric
+	// sinks and their retry policies by the name
+	sinks    map[string]Sink
+	policies map[string]retryPolicy
+	router   *router
+	// alerts of the entities waiting for the delivery in order
+	queue map[string][]model.Alert
+	// open incidents of the entities
+	incidents map[string]*model.Incident
+	// normal points of the entities since the last outlier
+	normalRuns map[string]int
+	// entities with the alerts being delivered, their next alerts wait for the delivery to keep the order
+	delivering map[string]bool
 	cancel     func()
 }
 
 func (m *manager) Run(ctx context.Context) error {
 	ctx, cancel := context.WithCancel(ctx)
 	m.cancel = cancel
-	go m.notifier(ctx, m.alertDB.Store, m.alertDB.Delete)
+	if err := m.loadIncidents(ctx); err != nil {
+		return fmt.Errorf("can not start alert manager: %w", err)
+	}
 	if err := m.bulkLoad(ctx, m.alertDB.Delete, m.alertDB.FindAll); err != nil {
 		return fmt.Errorf("can not start alert manager: %w", err)
 	}
+	go m.notifier(ctx, m.alertDB.Store, m.alertDB.Delete, m.alertDB.StoreDeadLetter)
 	return nil
 }
 
@@ -124,13 +215,34 @@ func (m *manager) Stop() {
 
 func (m *manager) Notify(metrics ...metricModel.Metric) {
 	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	now := time.Now()
 	for i := range metrics {
-		if _, ok := m.alerts[metrics[i].EntityID]; !ok {
-			m.alerts[metrics[i].EntityID] = []metricModel.Metric{}
+		incident := m.open(metrics[i])
+		incident.Outliers++
+		incident.LastOutlierAt = metrics[i].CreatedAt
+		m.normalRuns[metrics[i].EntityID] = 0
+		switch {
+		case incident.Acknowledged():
+			telemetry.AlertsSuppressedTotal.WithLabelValues(metrics[i].EntityID, ReasonAcknowledged).Inc()
+		case incident.Silenced(now):
+			telemetry.AlertsSuppressedTotal.WithLabelValues(metrics[i].EntityID, ReasonSilenced).Inc()
+		default:
+			m.enqueue(incident.ID, metrics[i])
 		}
-		m.alerts[metrics[i].EntityID] = append(m.alerts[metrics[i].EntityID], metrics[i])
 	}
-	m.mtx.Unlock()
+}
+
+// enqueue appends the outlier to the last alert of the incident or starts the new alert, must be called under the lock
+func (m *manager) enqueue(incidentID uuid.UUID, metric metricModel.Metric) {
+	queue := m.queue[metric.EntityID]
+	if n := len(queue); n > 0 && queue[n-1].Status == model.StatusFiring && queue[n-1].IncidentID == incidentID {
+		queue[n-1].Metrics = append(queue[n-1].Metrics, metric)
+		return
+	}
+	alert := model.NewAlert(metric.EntityID, []metricModel.Metric{metric})
+	alert.IncidentID = incidentID
+	m.queue[metric.EntityID] = append(queue, alert)
 }
 
 type deleteFn func(context.Context, model.Alert) error
@@ -143,8 +255,15 @@ func (m *manager) bulkLoad(ctx context.Context, deleteFn deleteFn, fetchFn fetch
 	if err != nil {
 		logger.Errorf("Error with fetching data from db, %v", err)
 	}
+	sort.Slice(alerts, func(i, j int) bool {
+		return alerts[i].CreatedAt.Before(alerts[j].CreatedAt)
+	})
+	m.mtx.Lock()
+	for i := range alerts {
+		m.queue[alerts[i].EntityID] = append(m.queue[alerts[i].EntityID], alerts[i])
+	}
+	m.mtx.Unlock()
 	for i := range alerts {
-		m.Notify(alerts[i].Metrics...)
 		if err := deleteFn(context.Background(), alerts[i]); err != nil {
 			return fmt.Errorf("unable delete alert on bulkLoad: %w", err)
 		}
@@ -154,21 +273,59 @@ func (m *manager) bulkLoad(ctx context.Context, deleteFn deleteFn, fetchFn fetch
 
 type storeFn func(context.Context, model.Alert) error
 
+type storeDeadLetterFn func(context.Context, model.DeadLetter) error
+
 func (m *manager) shutdown(fn storeFn) error {
 	m.mtx.Lock()
 	defer m.mtx.Unlock()
-	for entityID, metrics := range m.alerts {
-		alert := model.NewAlert(entityID, metrics)
-		if err := fn(context.Background(), alert); err != nil {
-			return fmt.Errorf("alert shutdown: unable store alert: %w", err)
+	for _, alerts := range m.queue {
+		for i := range alerts {
+			if err := fn(context.Background(), alerts[i]); err != nil {
+				return fmt.Errorf("alert shutdown: unable store alert: %w", err)
+			}
 		}
 	}
 	return nil
 }
 
-type makeRequestFn func() request
+// take returns the alerts queued for the entity and resets the queue
+func (m *manager) take(entityID string) []model.Alert {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	alerts := m.queue[entityID]
+	delete(m.queue, entityID)
+	return alerts
+}
+
+// entities returns the entities with the queued alerts which are not being delivered
+func (m *manager) entities() []string {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	entities := make([]string, 0, len(m.queue))
+	for entityID := range m.queue {
+		if !m.delivering[entityID] {
+			entities = append(entities, entityID)
+		}
+	}
+	return entities
+}
+
+func (m *manager) setDelivering(entityID string, delivering bool) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if delivering {
+		m.delivering[entityID] = true
+		return
+	}
+	delete(m.delivering, entityID)
+}
 
-func (m *manager) notifier(ctx context.Context, storeFn storeFn, deleteFn deleteFn) {
+func (m *manager) notifier(
+	ctx context.Context,
+	storeFn storeFn,
+	deleteFn deleteFn,
+	storeDeadLetterFn storeDeadLetterFn,
+) {
 	logger := logging.FromContext(ctx)
 	errCh := make(chan error, 1)
 	rateCh := make(chan struct{}, m.opts.maxConcurrentRequest)
@@ -184,110 +341,137 @@ func (m *manager) notifier(ctx context.Context, storeFn storeFn, deleteFn delete
 	}()
 	wg := sync.WaitGroup{}
 	ticker := time.NewTicker(m.opts.alertInterval)
+	defer ticker.Stop()
+	historyTicker := time.NewTicker(historyCleanupInterval)
+	defer historyTicker.Stop()
 	for {
 		select {
+		case <-historyTicker.C:
+			if m.opts.historyRetention > 0 {
+				if _, err := m.alertDB.DeleteDeliveriesBefore(ctx, time.Now().Add(-m.opts.historyRetention)); err != nil {
+					logger.Errorf("unable delete outdated alert deliveries: %v", err)
+				}
+			}
 		case <-ticker.C:
-		OuterLoop:
-			for _, target := range m.targets {
-				metrics, ok := m.alerts[target.EntityID]
-				if !ok || len(metrics) == 0 {
-					continue OuterLoop
+			for _, entityID := range m.entities() {
+				alerts := m.take(entityID)
+				sinks := m.router.route(entityID)
+				if len(sinks) == 0 {
+					// the alerts are kept to be replayed after the routes are fixed
+					m.deadLetterUnrouted(ctx, alerts, storeDeadLetterFn)
+					continue
 				}
-				rworker.Job(&wg, func() error {
-					alertModel := model.NewAlert(metrics[0].EntityID, metrics)
-					if err := storeFn(context.Background(), alertModel); err != nil {
-						return fmt.Errorf("unable store alert: %w", err)
+				// the alerts are kept in the database until the delivery to all targets is finished
+				for i := range alerts {
+					if err := storeFn(context.Background(), alerts[i]); err != nil {
+						logger.Errorf("unable store alert: %v", err)
 					}
-					if err := m.do(context.Background(), target, func() request {
-						outliers := make([]data, len(metrics))
-						for i := range metrics {
-							outliers[i] = data{
-								NormalVec:  metrics[i].NormVec,
-								OutlierVec: metrics[i].CheckedVec,
-								CreatedAt:  metrics[i].CreatedAt,
-								Extra:      metrics[i].Extra,
+				}
+				// the delivery with the retries does not block the tick, the next alerts of the entity wait for it
+				m.setDelivering(entityID, true)
+				wg.Add(1)
+				go func(entityID string, alerts []model.Alert, sinks []Sink) {
+					defer wg.Done()
+					defer m.setDelivering(entityID, false)
+					sent := sync.WaitGroup{}
+					for _, sink := range sinks {
+						sink := sink
+						rworker.Job(&sent, func() error {
+							// the alerts of the entity are sent in order, e.g. the resolved one after the firing one
+							var lastErr error
+							for i := range alerts {
+								if err := m.send(ctx, sink, alerts[i], storeDeadLetterFn); err != nil {
+									lastErr = err
+								}
 							}
-						}
-						return request{
-							EntityID: target.EntityID,
-							Data:     outliers,
-						}
-					}); err != nil {
-						return fmt.Errorf("alert do request error: %w", err)
+							return lastErr
+						}, rateCh, errCh)
 					}
-					if err := deleteFn(context.Background(), alertModel); err != nil {
-						return fmt.Errorf("unable store alert: %w", err)
+					sent.Wait()
+					if ctx.Err() != nil {
+						// the interrupted alerts are loaded from the database on the next start
+						return
 					}
-					m.mtx.Lock()
-					m.alerts[target.EntityID] = m.alerts[target.EntityID][:0]
-					m.mtx.Unlock()
-					return nil
-				}, rateCh, errCh)
+					for i := range alerts {
+						if err := deleteFn(context.Background(), alerts[i]); err != nil {
+							logger.Errorf("unable delete alert: %v", err)
+						}
+					}
+				}(entityID, alerts, sinks)
 			}
-			wg.Wait()
 		case <-ctx.Done():
+			wg.Wait()
 			return
 		}
 	}
 }
 
-func (m *manager) do(ctx context.Context, target Target, fn makeRequestFn) error {
-	ctx, cancel := context.WithTimeout(ctx, m.opts.requestTimeout)
-	defer cancel()
-
-	request := fn()
-	body, err := json.Marshal(request)
-	if err != nil {
-		return fmt.Errorf("unable encode json data: %w", err)
+// deadLetterUnrouted moves the alerts of the entity without the route to the dead letters
+func (m *manager) deadLetterUnrouted(ctx context.Context, alerts []model.Alert, fn storeDeadLetterFn) {
+	for i := range alerts {
+		deadLetter := model.NewDeadLetter(alerts[i], "", 0, ErrUnrouted)
+		deadLetter.Reason = ReasonUnrouted
+		if err := fn(context.Background(), deadLetter); err != nil {
+			logging.FromContext(ctx).Errorf("unable store dead letter: %v", err)
+			continue
+		}
+		telemetry.AlertDeadLettersTotal.WithLabelValues(alerts[i].EntityID).Inc()
 	}
+}
 
-	b := make([]byte, len(body))
-	link, err := url.Parse(target.URL)
-	if err != nil {
-		return fmt.Errorf("url parsing error: %w", err)
+// send delivers the alert to the sink with retries,
+// the alert not delivered after all retries is moved to the dead letters
+func (m *manager) send(ctx context.Context, sink Sink, alert model.Alert, fn storeDeadLetterFn) error {
+	attempts, err := retry(ctx, m.policies[sink.Name()], func() error {
+		return m.deliver(ctx, sink, alert)
+	})
+	if ctx.Err() != nil {
+		return err
 	}
-
-	req, err := http.NewRequestWithContext(ctx, "POST", link.String(), bytes.NewReader(b))
-	if err != nil {
-		return fmt.Errorf("creating request error: %w", err)
+	m.record(model.NewDelivery(alert, sink.Name(), attempts, err))
+	if err == nil {
+		return nil
 	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Add("User-Agent", UserAgent)
-	req.Header.Add("Accept-Encoding", "gzip")
-
-	client, ok := m.clients[target.EntityID]
-	if !ok {
-		return fmt.Errorf("client for entityID %s not defined", target.EntityID)
+	if err := fn(context.Background(), model.NewDeadLetter(alert, sink.Name(), attempts, err)); err != nil {
+		return fmt.Errorf("unable store dead letter: %w", err)
 	}
+	telemetry.AlertDeadLettersTotal.WithLabelValues(alert.EntityID).Inc()
+	return fmt.Errorf("alert to %s moved to dead letters after %d attempts: %w", sink.Name(), attempts, err)
+}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("sending request error: %w", err)
+// record stores the delivery result in the history if it is enabled
+func (m *manager) record(delivery model.Delivery) {
+	if m.opts.historyRetention <= 0 {
+		return
 	}
-
-	defer resp.Body.Close()
-
-	var reader io.ReadCloser
-	switch resp.Header.Get("Content-Encoding") {
-	case "gzip":
-		reader, err := gzip.NewReader(resp.Body)
-		if err != nil {
-			return fmt.Errorf("unable create gzip.NewReader: %w", err)
-		}
-		defer reader.Close()
-	default:
-		reader = resp.Body
+	if err := m.alertDB.StoreDelivery(context.Background(), delivery); err != nil {
+		logging.FromContext(context.Background()).Errorf("unable store alert delivery: %v", err)
 	}
+}
 
-	_, err = ioutil.ReadAll(reader)
-	if err != nil {
-		return fmt.Errorf("failed to read response body: %w", err)
+// retryPolicy returns the global retry policy with the overrides of the sink
+func (m *manager) retryPolicy(cfg *RetryConfig) retryPolicy {
+	policy := m.opts.retry
+	if cfg != nil {
+		if cfg.MaxRetries != nil {
+			policy.maxRetries = *cfg.MaxRetries
+		}
+		if cfg.InitialBackoff != nil {
+			policy.initialBackoff = cfg.InitialBackoff.Duration
+		}
+		if cfg.MaxBackoff != nil {
+			policy.maxBackoff = cfg.MaxBackoff.Duration
+		}
 	}
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("response was not 200 OK: %s", body)
+	if policy.maxBackoff < policy.initialBackoff {
+		policy.maxBackoff = policy.initialBackoff
 	}
+	return policy
+}
 
-	return nil
+// deliver sends the alert to the sink and records the delivery result
+func (m *manager) deliver(ctx context.Context, sink Sink, alert model.Alert) error {
+	err := sink.Send(ctx, alert)
+	telemetry.AlertDeliveriesTotal.WithLabelValues(alert.EntityID, telemetry.Result(err)).Inc()
+	return err
 }
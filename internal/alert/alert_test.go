@@ -0,0 +1,721 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	netmail "net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-sod/sod/internal/alert/model"
+	"github.com/go-sod/sod/internal/database"
+	metricModel "github.com/go-sod/sod/internal/metric/model"
+	"github.com/go-sod/sod/pkg/webhook"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	t.Parallel()
+	policy := retryPolicy{initialBackoff: 100 * time.Millisecond, maxBackoff: time.Second}
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{attempt: 1, max: 100 * time.Millisecond},
+		{attempt: 2, max: 200 * time.Millisecond},
+		{attempt: 3, max: 400 * time.Millisecond},
+		{attempt: 10, max: time.Second},
+	}
+	for _, test := range tests {
+		for i := 0; i < 100; i++ {
+			if d := policy.backoff(test.attempt); d < test.max/2 || d > test.max {
+				t.Fatalf("backoff of attempt %d, got: %v, expected in [%v, %v]", test.attempt, d, test.max/2, test.max)
+			}
+		}
+	}
+}
+
+func TestManager_Send(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name                string
+		failures            int32
+		status              int
+		expectedCalls       int32
+		expectedDeadLetters int
+	}{
+		{
+			name:          "positive_delivered_after_retries",
+			failures:      2,
+			status:        http.StatusServiceUnavailable,
+			expectedCalls: 3,
+		},
+		{
+			name:                "positive_retries_exhausted",
+			failures:            10,
+			status:              http.StatusInternalServerError,
+			expectedCalls:       4,
+			expectedDeadLetters: 1,
+		},
+		{
+			name:                "positive_permanent_error",
+			failures:            10,
+			status:              http.StatusBadRequest,
+			expectedCalls:       1,
+			expectedDeadLetters: 1,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			var calls int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&calls, 1) <= test.failures {
+					w.WriteHeader(test.status)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer srv.Close()
+
+			m, err := New(
+				newTestDB(t),
+				make(chan error, 1),
+				WithSinks(SinkConfigs{{Name: "hook", URL: srv.URL}}),
+				WithMaxRetries(3),
+				WithBackoff(time.Millisecond, 5*time.Millisecond),
+			)
+			if err != nil {
+				t.Fatalf("new manager: %v", err)
+			}
+			alert := model.NewAlert("test", []metricModel.Metric{{EntityID: "test", CheckedVec: []float64{1}}})
+			_ = m.send(context.Background(), m.sinks["hook"], alert, m.alertDB.StoreDeadLetter)
+
+			if got := atomic.LoadInt32(&calls); got != test.expectedCalls {
+				t.Errorf("delivery attempts, got: %d, expected: %d", got, test.expectedCalls)
+			}
+			deadLetters, err := m.DeadLetters(context.Background(), "test")
+			if err != nil {
+				t.Fatalf("dead letters: %v", err)
+			}
+			if len(deadLetters) != test.expectedDeadLetters {
+				t.Fatalf("dead letters, got: %d, expected: %d", len(deadLetters), test.expectedDeadLetters)
+			}
+			if test.expectedDeadLetters == 0 {
+				return
+			}
+			if int32(deadLetters[0].Attempts) != test.expectedCalls {
+				t.Errorf("dead letter attempts, got: %d, expected: %d", deadLetters[0].Attempts, test.expectedCalls)
+			}
+
+			atomic.StoreInt32(&calls, test.failures)
+			if err := m.ReplayDeadLetter(context.Background(), deadLetters[0].ID); err != nil {
+				t.Fatalf("replay dead letter: %v", err)
+			}
+			if deadLetters, _ = m.DeadLetters(context.Background(), ""); len(deadLetters) != 0 {
+				t.Errorf("dead letters after replay, got: %d, expected: 0", len(deadLetters))
+			}
+		})
+	}
+}
+
+func TestManager_NotifierRetries(t *testing.T) {
+	t.Parallel()
+	delivered := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if strings.Contains(string(body), "failing") {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		select {
+		case delivered <- struct{}{}:
+		default:
+		}
+	}))
+	defer srv.Close()
+
+	shutdownCh := make(chan error, 1)
+	m, err := New(
+		newTestDB(t),
+		shutdownCh,
+		WithSinks(SinkConfigs{{Name: "hook", URL: srv.URL}}),
+		WithRoutes(Routes{{Default: true, Sinks: []string{"hook"}}}),
+		WithScrapeInterval(10*time.Millisecond),
+		WithMaxRetries(3),
+		WithBackoff(time.Minute, time.Minute),
+	)
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := m.Run(ctx); err != nil {
+		t.Fatalf("run manager: %v", err)
+	}
+
+	// the alert of the failing entity waits for the retry backoff, the next ticks are not blocked by it
+	m.Notify(metricModel.Metric{EntityID: "failing", CheckedVec: []float64{1}})
+	time.Sleep(50 * time.Millisecond)
+	m.Notify(metricModel.Metric{EntityID: "test", CheckedVec: []float64{1}})
+	select {
+	case <-delivered:
+	case <-time.After(5 * time.Second):
+		t.Errorf("alert is not delivered during the retries of the other alert")
+	}
+
+	cancel()
+	select {
+	case err := <-shutdownCh:
+		if err != nil {
+			t.Errorf("shutdown: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("manager is not stopped")
+	}
+}
+
+func TestManager_Unrouted(t *testing.T) {
+	t.Parallel()
+	var delivered int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&delivered, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	shutdownCh := make(chan error, 1)
+	m, err := New(
+		newTestDB(t),
+		shutdownCh,
+		WithSinks(SinkConfigs{{Name: "hook", URL: srv.URL}}),
+		WithRoutes(Routes{{Entity: "routed", Sinks: []string{"hook"}}}),
+		WithScrapeInterval(10*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := m.Run(ctx); err != nil {
+		t.Fatalf("run manager: %v", err)
+	}
+
+	// the alert of the entity without the route is moved to the dead letters
+	m.Notify(metricModel.Metric{EntityID: "unrouted", CheckedVec: []float64{1}})
+	var deadLetters []model.DeadLetter
+	for start := time.Now(); len(deadLetters) == 0 && time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		if deadLetters, err = m.DeadLetters(ctx, "unrouted"); err != nil {
+			t.Fatalf("dead letters: %v", err)
+		}
+	}
+	if len(deadLetters) != 1 || deadLetters[0].Reason != ReasonUnrouted || deadLetters[0].Sink != "" {
+		t.Fatalf("dead letters, got: %+v, expected the unrouted one", deadLetters)
+	}
+	// the replay of the alert still without the route keeps it
+	if err := m.ReplayDeadLetter(ctx, deadLetters[0].ID); !errors.Is(err, ErrSinkNotFound) {
+		t.Errorf("replay unrouted, got: %v, expected: %v", err, ErrSinkNotFound)
+	}
+	if _, err := m.DeadLetter(ctx, deadLetters[0].ID); err != nil {
+		t.Errorf("dead letter after failed replay: %v", err)
+	}
+
+	// the unrouted alert of the entity with the route is delivered by the replay
+	routed := model.NewDeadLetter(model.NewAlert("routed", nil), "", 0, ErrUnrouted)
+	routed.Reason = ReasonUnrouted
+	if err := m.alertDB.StoreDeadLetter(ctx, routed); err != nil {
+		t.Fatalf("store dead letter: %v", err)
+	}
+	if err := m.ReplayDeadLetter(ctx, routed.ID); err != nil {
+		t.Errorf("replay routed: %v", err)
+	}
+	if _, err := m.DeadLetter(ctx, routed.ID); !errors.Is(err, ErrDeadLetterNotFound) {
+		t.Errorf("dead letter after replay, got: %v, expected: %v", err, ErrDeadLetterNotFound)
+	}
+	if n := atomic.LoadInt32(&delivered); n != 1 {
+		t.Errorf("delivered, got: %d, expected: 1", n)
+	}
+
+	cancel()
+	select {
+	case err := <-shutdownCh:
+		if err != nil {
+			t.Errorf("shutdown: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("manager is not stopped")
+	}
+}
+
+func TestManager_SignedDelivery(t *testing.T) {
+	t.Parallel()
+	var verified int32
+	verifier := webhook.NewVerifier([][]byte{[]byte("secret")})
+	srv := httptest.NewServer(verifier.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&verified, 1)
+		w.WriteHeader(http.StatusOK)
+	})))
+	defer srv.Close()
+
+	m, err := New(newTestDB(t), make(chan error, 1), WithSinks(SinkConfigs{
+		{Name: "signed", URL: srv.URL, Secret: "secret"},
+		{Name: "wrong", URL: srv.URL, Secret: "wrong"},
+	}))
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	alert := model.NewAlert("test", []metricModel.Metric{{EntityID: "test", CheckedVec: []float64{1}}})
+	if err := m.deliver(context.Background(), m.sinks["signed"], alert); err != nil {
+		t.Fatalf("signed delivery: %v", err)
+	}
+	if err := m.deliver(context.Background(), m.sinks["signed"], alert); err != nil {
+		t.Fatalf("repeated delivery: %v", err)
+	}
+	if err := m.deliver(context.Background(), m.sinks["wrong"], model.NewAlert("test", alert.Metrics)); err == nil {
+		t.Errorf("delivery with the wrong secret is accepted")
+	}
+	if atomic.LoadInt32(&verified) != 1 {
+		t.Errorf("verified deliveries, got: %d, expected: 1", verified)
+	}
+}
+
+func TestWebhookSink_Template(t *testing.T) {
+	t.Parallel()
+	var (
+		body        []byte
+		contentType string
+		token       string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		contentType, token = r.Header.Get("Content-Type"), r.Header.Get("X-Token")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	sink, err := newSink(SinkConfig{
+		Name: "chat",
+		URL:  srv.URL,
+		Template: `{"text": {{ json (printf "%s: %d outliers" .EntityID (len .Outliers)) }}, ` +
+			`{{ with index .Outliers 0 }}"score": "{{ score .Score 2 }}", "vec": "{{ vector .OutlierVec }}", ` +
+			`"host": {{ json (extraField .Extra "host") }}, "extra": {{ json (extra .Extra) }}{{ end }}}`,
+		ContentType: "application/vnd.chat+json",
+		Headers:     map[string]string{"X-Token": "token"},
+	}, time.Second)
+	if err != nil {
+		t.Fatalf("new sink: %v", err)
+	}
+	alert := model.NewAlert("api", []metricModel.Metric{{
+		EntityID:   "api",
+		CheckedVec: []float64{1.5, 20},
+		Score:      3.14159,
+		Extra:      map[string]interface{}{"host": "web-1"},
+	}})
+	if err := sink.Send(context.Background(), alert); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	expected := `{"text": "api: 1 outliers", "score": "3.14", "vec": "[1.5, 20]", "host": "web-1", "extra": "{\"host\":\"web-1\"}"}`
+	if string(body) != expected {
+		t.Errorf("body, got: %s, expected: %s", body, expected)
+	}
+	if contentType != "application/vnd.chat+json" || token != "token" {
+		t.Errorf("headers, got: %s %s, expected: application/vnd.chat+json token", contentType, token)
+	}
+	if _, err := newSink(SinkConfig{Name: "broken", URL: srv.URL, Template: "{{ .Unknown"}, time.Second); err == nil {
+		t.Errorf("broken template is accepted")
+	}
+}
+
+func TestSMTPSink_Digest(t *testing.T) {
+	t.Parallel()
+	srv := newFakeSMTPServer(t)
+	mail, err := newSink(SinkConfig{
+		Name: "mail",
+		Type: SinkTypeSMTP,
+		SMTP: &SMTPConfig{Host: "127.0.0.1", Port: srv.port},
+		From: "sod@example.com",
+		To:   []string{"ops@example.com"},
+	}, time.Second)
+	if err != nil {
+		t.Fatalf("new sink: %v", err)
+	}
+	sinks := map[string]Sink{"mail": mail}
+	rt, err := newRouter(Routes{
+		{Entity: "db-*", Sinks: []string{"mail"}, To: []string{"dba@example.com", "oncall@example.com"}},
+		{Default: true, Sinks: []string{"mail"}},
+	}, sinks)
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+
+	createdAt := time.Date(2021, 3, 1, 12, 30, 0, 0, time.UTC)
+	alert := model.NewAlert("db-1", []metricModel.Metric{
+		{EntityID: "db-1", NormVec: []float64{1, 2}, CheckedVec: []float64{10, 20}, Score: 2.5, CreatedAt: createdAt},
+		{EntityID: "db-1", NormVec: []float64{1, 2}, CheckedVec: []float64{11, 21}, Score: 2.75, CreatedAt: createdAt.Add(time.Second)},
+	})
+	routed := rt.route("db-1")
+	if len(routed) != 1 {
+		t.Fatalf("sinks of db-1, got: %d, expected: 1", len(routed))
+	}
+	if err := routed[0].Send(context.Background(), alert); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if err := rt.route("web")[0].Send(context.Background(), alert); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	msgs := srv.messages()
+	if len(msgs) != 2 {
+		t.Fatalf("messages, got: %d, expected: 2", len(msgs))
+	}
+	if msgs[0].from != "sod@example.com" || strings.Join(msgs[0].to, ",") != "dba@example.com,oncall@example.com" {
+		t.Errorf("route addresses, got: %s %v", msgs[0].from, msgs[0].to)
+	}
+	if strings.Join(msgs[1].to, ",") != "ops@example.com" {
+		t.Errorf("sink addresses, got: %v, expected: [ops@example.com]", msgs[1].to)
+	}
+	for _, expected := range []string{
+		"Subject: [SOD] db-1 firing: 2 outliers",
+		"Time:        2021-03-01 12:30:00 UTC",
+		"Outlier:     [10, 20]",
+		"Last normal: [1, 2]",
+		"Score:       2.750",
+	} {
+		if !strings.Contains(msgs[0].data, expected) {
+			t.Errorf("message does not contain %q:\n%s", expected, msgs[0].data)
+		}
+	}
+	// the message ids of the sinks derived from the routes are valid and differ
+	ids := map[string]struct{}{}
+	for _, msg := range msgs {
+		parsed, err := netmail.ReadMessage(strings.NewReader(msg.data))
+		if err != nil {
+			t.Fatalf("parse message: %v", err)
+		}
+		id := parsed.Header.Get("Message-ID")
+		if _, err := netmail.ParseAddress(id); err != nil {
+			t.Errorf("message id %s: %v", id, err)
+		}
+		ids[id] = struct{}{}
+	}
+	if len(ids) != 2 {
+		t.Errorf("message ids, got: %v, expected: 2 different ids", ids)
+	}
+
+	srv.mtx.Lock()
+	srv.reject = true
+	srv.mtx.Unlock()
+	err = mail.Send(context.Background(), alert)
+	if err == nil || retryable(err) {
+		t.Errorf("rejected recipient, got: %v, expected permanent error", err)
+	}
+}
+
+func TestManager_IncidentLifecycle(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	db := newTestDB(t)
+	m, err := New(db, make(chan error, 1), WithResolveAfter(2))
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	outlier := metricModel.Metric{EntityID: "test", Outlier: true, CheckedVec: []float64{1}, CreatedAt: time.Now()}
+	normal := metricModel.Metric{EntityID: "test", CheckedVec: []float64{0}, CreatedAt: time.Now()}
+
+	m.Observe(outlier)
+	m.Notify(outlier)
+	m.Observe(normal)
+	m.Observe(outlier)
+	m.Notify(outlier)
+	incidents, err := m.Incidents(ctx, "test", model.StatusFiring)
+	if err != nil {
+		t.Fatalf("incidents: %v", err)
+	}
+	if len(incidents) != 1 || incidents[0].Outliers != 2 {
+		t.Fatalf("firing incidents, got: %+v, expected one with 2 outliers", incidents)
+	}
+
+	// the open incident is restored by the next manager
+	restored, err := New(db, make(chan error, 1))
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	if err := restored.loadIncidents(ctx); err != nil {
+		t.Fatalf("load incidents: %v", err)
+	}
+	if _, ok := restored.incidents["test"]; !ok {
+		t.Errorf("open incident is not restored")
+	}
+
+	if _, err := m.Acknowledge(ctx, incidents[0].ID, "tester"); err != nil {
+		t.Fatalf("acknowledge: %v", err)
+	}
+	m.Observe(outlier)
+	m.Notify(outlier)
+	m.Observe(normal)
+	m.Observe(normal)
+
+	alerts := m.take("test")
+	if len(alerts) != 2 {
+		t.Fatalf("queued alerts, got: %d, expected: 2", len(alerts))
+	}
+	if alerts[0].Status != model.StatusFiring || len(alerts[0].Metrics) != 2 {
+		t.Errorf("first alert, got: %s with %d metrics, expected: firing with 2", alerts[0].Status, len(alerts[0].Metrics))
+	}
+	if alerts[1].Status != model.StatusResolved || alerts[1].IncidentID != incidents[0].ID {
+		t.Errorf("second alert, got: %s of %s, expected: resolved of %s", alerts[1].Status, alerts[1].IncidentID, incidents[0].ID)
+	}
+	if _, err := m.Silence(ctx, incidents[0].ID, time.Hour); !errors.Is(err, ErrIncidentResolved) {
+		t.Errorf("silence resolved incident, got: %v, expected: %v", err, ErrIncidentResolved)
+	}
+}
+
+func TestManager_History(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	m, err := New(newTestDB(t), make(chan error, 1), WithHistoryRetention(time.Hour))
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < 10; i++ {
+		entityID := "even"
+		var deliveryErr error
+		if i%2 == 1 {
+			entityID, deliveryErr = "odd", errors.New("failed")
+		}
+		delivery := model.NewDelivery(model.NewAlert(entityID, nil), "http://localhost", 1, deliveryErr)
+		delivery.CreatedAt = start.Add(time.Duration(i) * time.Minute)
+		if err := m.alertDB.StoreDelivery(ctx, delivery); err != nil {
+			t.Fatalf("store delivery: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		query    HistoryQuery
+		expected []int
+	}{
+		{
+			name:     "positive_all",
+			query:    HistoryQuery{},
+			expected: []int{10},
+		},
+		{
+			name:     "positive_pages",
+			query:    HistoryQuery{Limit: 4},
+			expected: []int{4, 4, 2},
+		},
+		{
+			name:     "positive_entity_pages",
+			query:    HistoryQuery{EntityID: "odd", Limit: 3},
+			expected: []int{3, 2},
+		},
+		{
+			name:     "positive_status",
+			query:    HistoryQuery{Status: model.DeliveryStatusDelivered},
+			expected: []int{5},
+		},
+		{
+			name:     "positive_time_range",
+			query:    HistoryQuery{From: start.Add(2 * time.Minute), To: start.Add(5 * time.Minute), Limit: 2},
+			expected: []int{2, 2},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		// the subtests read the history before the deletion below
+		t.Run(test.name, func(t *testing.T) {
+			q := test.query
+			var last time.Time
+			for page, expected := range test.expected {
+				deliveries, cursor, err := m.History(ctx, q)
+				if err != nil {
+					t.Fatalf("history: %v", err)
+				}
+				if len(deliveries) != expected {
+					t.Fatalf("page %d len, got: %d, expected: %d", page, len(deliveries), expected)
+				}
+				for _, delivery := range deliveries {
+					if !last.IsZero() && !delivery.CreatedAt.Before(last) {
+						t.Fatalf("deliveries are not ordered from the newest one")
+					}
+					last = delivery.CreatedAt
+				}
+				if (cursor == "") != (page == len(test.expected)-1) {
+					t.Fatalf("page %d cursor: %q", page, cursor)
+				}
+				q.Cursor = cursor
+			}
+		})
+	}
+
+	deleted, err := m.alertDB.DeleteDeliveriesBefore(ctx, start.Add(5*time.Minute))
+	if err != nil {
+		t.Fatalf("delete deliveries: %v", err)
+	}
+	if deleted != 5 {
+		t.Errorf("deleted deliveries, got: %d, expected: 5", deleted)
+	}
+}
+
+func TestRouter_Route(t *testing.T) {
+	t.Parallel()
+	sinks := map[string]Sink{}
+	for _, name := range []string{"pager", "chat", "ops", "legacy"} {
+		sinks[name] = &webhookSink{name: name}
+	}
+	routes := Routes{
+		{Entity: "api-*", Sinks: []string{"pager", "chat"}},
+		{Regex: "^api-(eu|us)$", Sinks: []string{"chat", "ops"}},
+		{Regex: "^db\\[1\\]$", Sinks: []string{"legacy"}},
+		{Default: true, Sinks: []string{"ops"}},
+	}
+	rt, err := newRouter(routes, sinks)
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+	tests := []struct {
+		entityID string
+		expected []string
+	}{
+		{entityID: "api-eu", expected: []string{"pager", "chat", "ops"}},
+		{entityID: "api-asia", expected: []string{"pager", "chat"}},
+		{entityID: "db[1]", expected: []string{"legacy"}},
+		{entityID: "weather", expected: []string{"ops"}},
+	}
+	for _, test := range tests {
+		got := rt.route(test.entityID)
+		names := make([]string, len(got))
+		for i := range got {
+			names[i] = got[i].Name()
+		}
+		if strings.Join(names, ",") != strings.Join(test.expected, ",") {
+			t.Errorf("sinks of %s, got: %v, expected: %v", test.entityID, names, test.expected)
+		}
+	}
+
+	if _, err := newRouter(Routes{{Entity: "*", Sinks: []string{"unknown"}}}, sinks); err == nil {
+		t.Errorf("route to unknown sink is accepted")
+	}
+}
+
+func newTestDB(t *testing.T) *database.DB {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "sod-alert")
+	if err != nil {
+		t.Fatalf("create temp dir: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+	boltDB, err := bolt.Open(filepath.Join(dir, "test.db"), 0600, nil)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = boltDB.Close()
+	})
+	return &database.DB{DB: boltDB}
+}
+
+type smtpMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+// fakeSMTPServer is the local smtp server accepting the messages without the authentication
+type fakeSMTPServer struct {
+	port   int
+	reject bool
+
+	mtx  sync.Mutex
+	msgs []smtpMessage
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = l.Close()
+	})
+	srv := &fakeSMTPServer{port: l.Addr().(*net.TCPAddr).Port}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go srv.serve(conn)
+		}
+	}()
+	return srv
+}
+
+func (s *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	c := textproto.NewConn(conn)
+	var msg smtpMessage
+	_ = c.PrintfLine("220 localhost ready")
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch {
+		case cmd == "EHLO" || cmd == "HELO":
+			_ = c.PrintfLine("250 localhost")
+		case strings.HasPrefix(strings.ToUpper(line), "MAIL FROM:"):
+			msg = smtpMessage{from: strings.Trim(line[len("MAIL FROM:"):], "<>")}
+			_ = c.PrintfLine("250 ok")
+		case strings.HasPrefix(strings.ToUpper(line), "RCPT TO:"):
+			s.mtx.Lock()
+			reject := s.reject
+			s.mtx.Unlock()
+			if reject {
+				_ = c.PrintfLine("550 no such user")
+				continue
+			}
+			msg.to = append(msg.to, strings.Trim(line[len("RCPT TO:"):], "<>"))
+			_ = c.PrintfLine("250 ok")
+		case cmd == "DATA":
+			_ = c.PrintfLine("354 go ahead")
+			data, err := c.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.data = string(data)
+			s.mtx.Lock()
+			s.msgs = append(s.msgs, msg)
+			s.mtx.Unlock()
+			_ = c.PrintfLine("250 ok")
+		case cmd == "QUIT":
+			_ = c.PrintfLine("221 bye")
+			return
+		default:
+			_ = c.PrintfLine("250 ok")
+		}
+	}
+}
+
+func (s *fakeSMTPServer) messages() []smtpMessage {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return append([]smtpMessage(nil), s.msgs...)
+}
@@ -5,13 +5,23 @@ import (
 	"time"
 
 	"github.com/go-sod/sod/internal/httputil"
+	"github.com/go-sod/sod/internal/timeutil"
 )
 
 type Config struct {
-	AllowAlerts          bool          `envconfig:"SOD_ALLOW_ALERTS" default:"true"`
+	AllowAlerts bool `envconfig:"SOD_ALLOW_ALERTS" default:"true"`
+	// Targets are the webhook sinks of the exact entities, kept for compatibility with Sinks and Routes
 	Targets              Targets       `envconfig:"SOD_ALERT_TARGETS"`
+	Sinks                SinkConfigs   `envconfig:"SOD_ALERT_SINKS"`
+	Routes               Routes        `envconfig:"SOD_ALERT_ROUTES"`
 	Interval             time.Duration `envconfig:"SOD_ALERT_INTERVAL" default:"5s"`
 	MaxConcurrentRequest int           `envconfig:"SOD_ALERT_MAX_CONCURRENT_REQUEST" default:"64"`
+	RequestTimeout       time.Duration `envconfig:"SOD_ALERT_REQUEST_TIMEOUT" default:"10s"`
+	MaxRetries           int           `envconfig:"SOD_ALERT_MAX_RETRIES" default:"3"`
+	InitialBackoff       time.Duration `envconfig:"SOD_ALERT_INITIAL_BACKOFF" default:"1s"`
+	MaxBackoff           time.Duration `envconfig:"SOD_ALERT_MAX_BACKOFF" default:"1m"`
+	ResolveAfter         int           `envconfig:"SOD_ALERT_RESOLVE_AFTER" default:"10"`
+	HistoryRetention     time.Duration `envconfig:"SOD_ALERT_HISTORY_RETENTION" default:"168h"`
 }
 
 type Targets []Target
@@ -29,4 +39,26 @@ type Target struct {
 	URL        string                    `json:"url"`
 	EntityID   string                    `json:"entityId"`
 	HTTPConfig httputil.HTTPClientConfig `json:"httpConfig"`
+	Retry      *RetryConfig              `json:"retry,omitempty"`
+	// Secret signs the requests to the target, see the pkg/webhook
+	Secret string `json:"secret,omitempty"`
+}
+
+// sink returns the webhook sink of the target, the same url of the different entities makes different sinks
+func (t Target) sink() SinkConfig {
+	return SinkConfig{
+		Name:       t.EntityID + " " + t.URL,
+		Type:       SinkTypeWebhook,
+		Retry:      t.Retry,
+		URL:        t.URL,
+		HTTPConfig: t.HTTPConfig,
+		Secret:     t.Secret,
+	}
+}
+
+// RetryConfig overrides the global retry policy for the sink, nil fields keep the global values
+type RetryConfig struct {
+	MaxRetries     *int               `json:"maxRetries,omitempty"`
+	InitialBackoff *timeutil.Duration `json:"initialBackoff,omitempty"`
+	MaxBackoff     *timeutil.Duration `json:"maxBackoff,omitempty"`
 }
@@ -112,12 +112,15 @@ func (db *DB) FindAll(_ context.Context, filter FilterFn) ([]model.Alert, error)
 
 	c := b.Cursor()
 
-	for k, v := c.First(); k != nil; k, v = c.Next() {
-		keys = append(keys, string(v))
+	for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		keys = append(keys, string(k))
 	}
 
 	for _, key := range keys {
 		b := tx.Bucket([]byte(key))
+		if b == nil {
+			continue
+		}
 		c := b.Cursor()
 		for k, v := c.First(); k != nil; k, v = c.Next() {
 			var m model.Alert
@@ -129,6 +132,9 @@ func (db *DB) FindAll(_ context.Context, filter FilterFn) ([]model.Alert, error)
 	}
 
 	if filter == nil {
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("committing transaction: %w", err)
+		}
 		return metrics, nil
 	}
 
@@ -0,0 +1,97 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/go-sod/sod/internal/alert/model"
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+const deadLetterBucket = "alert:dead-letters:"
+
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+type DeadLetterFilterFn func(deadLetter model.DeadLetter) bool
+
+func (db *DB) StoreDeadLetter(_ context.Context, deadLetter model.DeadLetter) error {
+	bytes, err := json.Marshal(deadLetter)
+	if err != nil {
+		return err
+	}
+	if err := db.sDB.DB.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(deadLetterBucket))
+		if err != nil {
+			return fmt.Errorf("create bucket: %w", err)
+		}
+		if err := b.Put([]byte(deadLetter.ID.String()), bytes); err != nil {
+			return fmt.Errorf("put to bucket error: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("update transaction error: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) FindDeadLetter(_ context.Context, id uuid.UUID) (model.DeadLetter, error) {
+	var deadLetter model.DeadLetter
+	if err := db.sDB.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(deadLetterBucket))
+		if b == nil {
+			return ErrDeadLetterNotFound
+		}
+		v := b.Get([]byte(id.String()))
+		if v == nil {
+			return ErrDeadLetterNotFound
+		}
+		return json.Unmarshal(v, &deadLetter)
+	}); err != nil {
+		return model.DeadLetter{}, fmt.Errorf("view transaction error: %w", err)
+	}
+	return deadLetter, nil
+}
+
+func (db *DB) FindDeadLetters(_ context.Context, filter DeadLetterFilterFn) ([]model.DeadLetter, error) {
+	deadLetters := []model.DeadLetter{}
+	if err := db.sDB.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(deadLetterBucket))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(_, v []byte) error {
+			var deadLetter model.DeadLetter
+			if err := json.Unmarshal(v, &deadLetter); err != nil {
+				return fmt.Errorf("dead letter unmarshal error: %w", err)
+			}
+			if filter == nil || filter(deadLetter) {
+				deadLetters = append(deadLetters, deadLetter)
+			}
+			return nil
+		})
+	}); err != nil {
+		return nil, fmt.Errorf("view transaction error: %w", err)
+	}
+	return deadLetters, nil
+}
+
+func (db *DB) DeleteDeadLetters(_ context.Context, ids ...uuid.UUID) error {
+	if err := db.sDB.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(deadLetterBucket))
+		if b == nil {
+			return nil
+		}
+		for _, id := range ids {
+			if err := b.Delete([]byte(id.String())); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("update transaction error: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-sod/sod/internal/alert/model"
+	bolt "go.etcd.io/bbolt"
+)
+
+const historyBucket = "alert:history:"
+
+var ErrInvalidCursor = errors.New("invalid history cursor")
+
+// HistoryQuery selects the deliveries, the zero values do not filter
+type HistoryQuery struct {
+	EntityID string
+	From     time.Time
+	To       time.Time
+	Status   model.DeliveryStatus
+	Limit    int
+	// Cursor is the position after the last delivery of the previous page
+	Cursor string
+}
+
+// historyKey orders the deliveries by the time, the id makes the key unique
+func historyKey(delivery model.Delivery) []byte {
+	key := make([]byte, 8, 8+len(delivery.ID))
+	binary.BigEndian.PutUint64(key, uint64(delivery.CreatedAt.UnixNano()))
+	return append(key, delivery.ID[:]...)
+}
+
+func timeKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	return key
+}
+
+func (db *DB) StoreDelivery(_ context.Context, delivery model.Delivery) error {
+	value, err := json.Marshal(delivery)
+	if err != nil {
+		return err
+	}
+	if err := db.sDB.DB.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(historyBucket))
+		if err != nil {
+			return fmt.Errorf("create bucket: %w", err)
+		}
+		if err := b.Put(historyKey(delivery), value); err != nil {
+			return fmt.Errorf("put to bucket error: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("update transaction error: %w", err)
+	}
+	return nil
+}
+
+// FindDeliveries returns the page of the deliveries from the newest one and the cursor of the next page,
+// the cursor is empty on the last page
+func (db *DB) FindDeliveries(_ context.Context, q HistoryQuery) ([]model.Delivery, string, error) {
+	var (
+		start      []byte
+		nextCursor string
+	)
+	deliveries := []model.Delivery{}
+	if q.Cursor != "" {
+		cursor, err := hex.DecodeString(q.Cursor)
+		if err != nil {
+			return nil, "", ErrInvalidCursor
+		}
+		start = cursor
+	}
+	if !q.To.IsZero() {
+		if to := timeKey(q.To.Add(time.Nanosecond)); start == nil || bytes.Compare(to, start) < 0 {
+			start = to
+		}
+	}
+	if err := db.sDB.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(historyBucket))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		var k, v []byte
+		if start == nil {
+			k, v = c.Last()
+		} else {
+			// the cursor is positioned before the start key
+			if k, v = c.Seek(start); k == nil {
+				k, v = c.Last()
+			} else {
+				k, v = c.Prev()
+			}
+		}
+		from := timeKey(q.From)
+		for ; k != nil; k, v = c.Prev() {
+			if !q.From.IsZero() && bytes.Compare(k[:8], from) < 0 {
+				break
+			}
+			var delivery model.Delivery
+			if err := json.Unmarshal(v, &delivery); err != nil {
+				return fmt.Errorf("delivery unmarshal error: %w", err)
+			}
+			if q.EntityID != "" && delivery.Alert.EntityID != q.EntityID {
+				continue
+			}
+			if q.Status != "" && delivery.Status != q.Status {
+				continue
+			}
+			if q.Limit > 0 && len(deliveries) == q.Limit {
+				nextCursor = hex.EncodeToString(historyKey(deliveries[len(deliveries)-1]))
+				break
+			}
+			deliveries = append(deliveries, delivery)
+		}
+		return nil
+	}); err != nil {
+		return nil, "", fmt.Errorf("view transaction error: %w", err)
+	}
+	return deliveries, nextCursor, nil
+}
+
+// DeleteDeliveriesBefore removes the deliveries created before the time
+func (db *DB) DeleteDeliveriesBefore(_ context.Context, t time.Time) (int, error) {
+	var deleted int
+	to := timeKey(t)
+	if err := db.sDB.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(historyBucket))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k[:8], to) < 0; k, _ = c.First() {
+			if err := c.Delete(); err != nil {
+				return err
+			}
+			deleted++
+		}
+		return nil
+	}); err != nil {
+		return 0, fmt.Errorf("update transaction error: %w", err)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/go-sod/sod/internal/alert/model"
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+const incidentBucket = "alert:incidents:"
+
+var ErrIncidentNotFound = errors.New("incident not found")
+
+type IncidentFilterFn func(incident model.Incident) bool
+
+func (db *DB) StoreIncident(_ context.Context, incident model.Incident) error {
+	bytes, err := json.Marshal(incident)
+	if err != nil {
+		return err
+	}
+	if err := db.sDB.DB.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(incidentBucket))
+		if err != nil {
+			return fmt.Errorf("create bucket: %w", err)
+		}
+		if err := b.Put([]byte(incident.ID.String()), bytes); err != nil {
+			return fmt.Errorf("put to bucket error: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("update transaction error: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) FindIncident(_ context.Context, id uuid.UUID) (model.Incident, error) {
+	var incident model.Incident
+	if err := db.sDB.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(incidentBucket))
+		if b == nil {
+			return ErrIncidentNotFound
+		}
+		v := b.Get([]byte(id.String()))
+		if v == nil {
+			return ErrIncidentNotFound
+		}
+		return json.Unmarshal(v, &incident)
+	}); err != nil {
+		return model.Incident{}, fmt.Errorf("view transaction error: %w", err)
+	}
+	return incident, nil
+}
+
+func (db *DB) FindIncidents(_ context.Context, filter IncidentFilterFn) ([]model.Incident, error) {
+	incidents := []model.Incident{}
+	if err := db.sDB.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(incidentBucket))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(_, v []byte) error {
+			var incident model.Incident
+			if err := json.Unmarshal(v, &incident); err != nil {
+				return fmt.Errorf("incident unmarshal error: %w", err)
+			}
+			if filter == nil || filter(incident) {
+				incidents = append(incidents, incident)
+			}
+			return nil
+		})
+	}); err != nil {
+		return nil, fmt.Errorf("view transaction error: %w", err)
+	}
+	return incidents, nil
+}
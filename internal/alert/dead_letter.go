@@ -0,0 +1,123 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	alertDb "github.com/go-sod/sod/internal/alert/database"
+	"github.com/go-sod/sod/internal/alert/model"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrDeadLetterNotFound = alertDb.ErrDeadLetterNotFound
+	ErrSinkNotFound       = errors.New("alert sink not found")
+	ErrDeliveryFailed     = errors.New("alert delivery failed")
+	// ErrUnrouted is the error of the dead letter of the alert without the route
+	ErrUnrouted = errors.New("no route matches the entity")
+)
+
+// DeadLetterer manages the alerts not delivered after all retries
+type DeadLetterer interface {
+	// DeadLetters returns the dead letters of the entity, or all of them if the entity is empty
+	DeadLetters(ctx context.Context, entityID string) ([]model.DeadLetter, error)
+	DeadLetter(ctx context.Context, id uuid.UUID) (model.DeadLetter, error)
+	// ReplayDeadLetter sends the alert to the sink once more and removes the dead letter on success
+	ReplayDeadLetter(ctx context.Context, id uuid.UUID) error
+	// PurgeDeadLetters removes the dead letters of the entity, or all of them if the entity is empty
+	PurgeDeadLetters(ctx context.Context, entityID string) (int, error)
+	// DeleteDeadLetter removes the dead letter
+	DeleteDeadLetter(ctx context.Context, id uuid.UUID) error
+}
+
+func (m *manager) DeadLetters(ctx context.Context, entityID string) ([]model.DeadLetter, error) {
+	var filter alertDb.DeadLetterFilterFn
+	if entityID != "" {
+		filter = func(deadLetter model.DeadLetter) bool {
+			return deadLetter.Alert.EntityID == entityID
+		}
+	}
+	return m.alertDB.FindDeadLetters(ctx, filter)
+}
+
+func (m *manager) DeadLetter(ctx context.Context, id uuid.UUID) (model.DeadLetter, error) {
+	return m.alertDB.FindDeadLetter(ctx, id)
+}
+
+func (m *manager) ReplayDeadLetter(ctx context.Context, id uuid.UUID) error {
+	deadLetter, err := m.alertDB.FindDeadLetter(ctx, id)
+	if err != nil {
+		return err
+	}
+	if deadLetter.Reason == ReasonUnrouted {
+		return m.replayUnrouted(ctx, deadLetter)
+	}
+	sink, ok := m.sinks[deadLetter.Sink]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrSinkNotFound, deadLetter.Sink)
+	}
+	err = m.deliver(ctx, sink, deadLetter.Alert)
+	m.record(model.NewDelivery(deadLetter.Alert, sink.Name(), 1, err))
+	if err != nil {
+		deadLetter.Attempts++
+		deadLetter.LastError = err.Error()
+		deadLetter.FailedAt = time.Now()
+		if err := m.alertDB.StoreDeadLetter(ctx, deadLetter); err != nil {
+			return fmt.Errorf("unable store dead letter: %w", err)
+		}
+		return fmt.Errorf("%w: %v", ErrDeliveryFailed, err)
+	}
+	return m.alertDB.DeleteDeadLetters(ctx, id)
+}
+
+// replayUnrouted sends the unrouted alert to the sinks of the current routes,
+// the alert not delivered to the sink is moved to the dead letters of the sink
+func (m *manager) replayUnrouted(ctx context.Context, deadLetter model.DeadLetter) error {
+	sinks := m.router.route(deadLetter.Alert.EntityID)
+	if len(sinks) == 0 {
+		return fmt.Errorf("%w: %v", ErrSinkNotFound, ErrUnrouted)
+	}
+	var failed []string
+	for _, sink := range sinks {
+		err := m.deliver(ctx, sink, deadLetter.Alert)
+		m.record(model.NewDelivery(deadLetter.Alert, sink.Name(), 1, err))
+		if err == nil {
+			continue
+		}
+		if err := m.alertDB.StoreDeadLetter(ctx, model.NewDeadLetter(deadLetter.Alert, sink.Name(), 1, err)); err != nil {
+			return fmt.Errorf("unable store dead letter: %w", err)
+		}
+		failed = append(failed, sink.Name())
+	}
+	if err := m.alertDB.DeleteDeadLetters(ctx, deadLetter.ID); err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%w: moved to the dead letters of %v", ErrDeliveryFailed, failed)
+	}
+	return nil
+}
+
+func (m *manager) PurgeDeadLetters(ctx context.Context, entityID string) (int, error) {
+	deadLetters, err := m.DeadLetters(ctx, entityID)
+	if err != nil {
+		return 0, err
+	}
+	ids := make([]uuid.UUID, len(deadLetters))
+	for i := range deadLetters {
+		ids[i] = deadLetters[i].ID
+	}
+	if err := m.alertDB.DeleteDeadLetters(ctx, ids...); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+func (m *manager) DeleteDeadLetter(ctx context.Context, id uuid.UUID) error {
+	if _, err := m.alertDB.FindDeadLetter(ctx, id); err != nil {
+		return err
+	}
+	return m.alertDB.DeleteDeadLetters(ctx, id)
+}
@@ -0,0 +1,153 @@
+package alert
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-sod/sod/internal/alert/model"
+	"github.com/go-sod/sod/internal/httputil"
+	"github.com/go-sod/sod/internal/logging"
+	"github.com/google/uuid"
+)
+
+// NewDeadLetterHandler returns the handler managing the dead letters
+//
+// GET lists the dead letters, POST replays them and DELETE purges them.
+// The request is limited to one dead letter with ?id= or to the entity dead letters with ?entity=
+func NewDeadLetterHandler(deadLetterer DeadLetterer) (http.Handler, error) {
+	return &deadLetterHandler{deadLetterer: deadLetterer}, nil
+}
+
+type deadLetterHandler struct {
+	deadLetterer DeadLetterer
+}
+
+type replayResponse struct {
+	Replayed int      `json:"replayed"`
+	Failed   int      `json:"failed"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+type purgeResponse struct {
+	Purged int `json:"purged"`
+}
+
+func (h *deadLetterHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		h.list(w, r)
+	case "POST":
+		h.replay(w, r)
+	case "DELETE":
+		h.purge(w, r)
+	default:
+		logger := logging.FromContext(r.Context())
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		logger.Debugf(`{"error": "method %v is not allowed"}`, r.Method)
+		_, _ = fmt.Fprintf(w, `{"error": "method %v is not allowed"}`, r.Method)
+	}
+}
+
+func (h *deadLetterHandler) list(w http.ResponseWriter, r *http.Request) {
+	deadLetters, err := h.deadLetters(r)
+	if err != nil {
+		h.respError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, deadLetters)
+}
+
+func (h *deadLetterHandler) replay(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	deadLetters, err := h.deadLetters(r)
+	if err != nil {
+		h.respError(w, r, err)
+		return
+	}
+	resp := replayResponse{}
+	for i := range deadLetters {
+		if err := h.deadLetterer.ReplayDeadLetter(ctx, deadLetters[i].ID); err != nil {
+			if !errors.Is(err, ErrDeliveryFailed) && !errors.Is(err, ErrSinkNotFound) {
+				h.respError(w, r, err)
+				return
+			}
+			resp.Failed++
+			resp.Errors = append(resp.Errors, fmt.Sprintf("%s: %v", deadLetters[i].ID, err))
+			continue
+		}
+		resp.Replayed++
+	}
+	status := http.StatusOK
+	if resp.Failed > 0 {
+		status = http.StatusBadGateway
+	}
+	writeJSON(w, r, status, resp)
+}
+
+func (h *deadLetterHandler) purge(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if v := r.URL.Query().Get("id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			httputil.RespBadRequestErrorf(ctx, w, `{"error": "invalid id: %v"}`, err)
+			return
+		}
+		if err := h.deadLetterer.DeleteDeadLetter(ctx, id); err != nil {
+			h.respError(w, r, err)
+			return
+		}
+		writeJSON(w, r, http.StatusOK, purgeResponse{Purged: 1})
+		return
+	}
+	n, err := h.deadLetterer.PurgeDeadLetters(ctx, r.URL.Query().Get("entity"))
+	if err != nil {
+		h.respError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, purgeResponse{Purged: n})
+}
+
+// deadLetters returns the dead letters selected by the id or the entity parameters
+func (h *deadLetterHandler) deadLetters(r *http.Request) ([]model.DeadLetter, error) {
+	ctx := r.Context()
+	v := r.URL.Query().Get("id")
+	if v == "" {
+		return h.deadLetterer.DeadLetters(ctx, r.URL.Query().Get("entity"))
+	}
+	id, err := uuid.Parse(v)
+	if err != nil {
+		return nil, errInvalidID
+	}
+	deadLetter, err := h.deadLetterer.DeadLetter(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return []model.DeadLetter{deadLetter}, nil
+}
+
+var errInvalidID = errors.New("invalid dead letter id")
+
+func (h *deadLetterHandler) respError(w http.ResponseWriter, r *http.Request, err error) {
+	ctx := r.Context()
+	switch {
+	case errors.Is(err, errInvalidID):
+		httputil.RespBadRequestErrorf(ctx, w, `{"error": %q}`, err.Error())
+	case errors.Is(err, ErrDeadLetterNotFound):
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = fmt.Fprintf(w, `{"error": %q}`, err.Error())
+	default:
+		httputil.RespInternalErrorf(ctx, w, "dead letters error: %v", err)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		httputil.RespInternalErrorf(r.Context(), w, "failed to encode output json %v", err)
+		return
+	}
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, "%s", bytes)
+}
@@ -0,0 +1,25 @@
+package alert
+
+import (
+	"context"
+	"time"
+
+	alertDb "github.com/go-sod/sod/internal/alert/database"
+	"github.com/go-sod/sod/internal/alert/model"
+)
+
+const historyCleanupInterval = 10 * time.Minute
+
+var ErrInvalidCursor = alertDb.ErrInvalidCursor
+
+type HistoryQuery = alertDb.HistoryQuery
+
+// HistoryReader returns the results of the alert deliveries kept for the history retention
+type HistoryReader interface {
+	// History returns the page of the deliveries from the newest one and the cursor of the next page
+	History(ctx context.Context, q HistoryQuery) ([]model.Delivery, string, error)
+}
+
+func (m *manager) History(ctx context.Context, q HistoryQuery) ([]model.Delivery, string, error) {
+	return m.alertDB.FindDeliveries(ctx, q)
+}
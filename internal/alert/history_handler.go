@@ -0,0 +1,93 @@
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-sod/sod/internal/alert/model"
+	"github.com/go-sod/sod/internal/httputil"
+	"github.com/go-sod/sod/internal/logging"
+)
+
+const (
+	defaultHistoryLimit = 100
+	maxHistoryLimit     = 1000
+)
+
+// NewHistoryHandler returns the handler of the alert deliveries history
+//
+// GET filters the deliveries by ?entity=, ?status=delivered|failed and the RFC3339 ?from= and ?to=,
+// the page of ?limit= deliveries is continued with ?cursor= from the previous response
+func NewHistoryHandler(historyReader HistoryReader) (http.Handler, error) {
+	return &historyHandler{historyReader: historyReader}, nil
+}
+
+type historyHandler struct {
+	historyReader HistoryReader
+}
+
+type historyResponse struct {
+	Items      []model.Delivery `json:"items"`
+	NextCursor string           `json:"nextCursor,omitempty"`
+}
+
+func (h *historyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if r.Method != "GET" {
+		logger := logging.FromContext(ctx)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		logger.Debugf(`{"error": "method %v is not allowed"}`, r.Method)
+		_, _ = fmt.Fprintf(w, `{"error": "method %v is not allowed"}`, r.Method)
+		return
+	}
+
+	q, err := parseHistoryQuery(r)
+	if err != nil {
+		httputil.RespBadRequestErrorf(ctx, w, `{"error": %q}`, err.Error())
+		return
+	}
+
+	deliveries, nextCursor, err := h.historyReader.History(ctx, q)
+	if err != nil {
+		if errors.Is(err, ErrInvalidCursor) {
+			httputil.RespBadRequestErrorf(ctx, w, `{"error": %q}`, err.Error())
+			return
+		}
+		httputil.RespInternalErrorf(ctx, w, "alert history error: %v", err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, historyResponse{Items: deliveries, NextCursor: nextCursor})
+}
+
+func parseHistoryQuery(r *http.Request) (HistoryQuery, error) {
+	values := r.URL.Query()
+	q := HistoryQuery{
+		EntityID: values.Get("entity"),
+		Status:   model.DeliveryStatus(values.Get("status")),
+		Cursor:   values.Get("cursor"),
+		Limit:    defaultHistoryLimit,
+	}
+	if q.Status != "" && q.Status != model.DeliveryStatusDelivered && q.Status != model.DeliveryStatusFailed {
+		return q, fmt.Errorf("unknown status %s", q.Status)
+	}
+	for name, t := range map[string]*time.Time{"from": &q.From, "to": &q.To} {
+		if v := values.Get(name); v != "" {
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return q, fmt.Errorf("invalid %s: %w", name, err)
+			}
+			*t = parsed
+		}
+	}
+	if v := values.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > maxHistoryLimit {
+			return q, fmt.Errorf("limit must be in [1, %d]", maxHistoryLimit)
+		}
+		q.Limit = limit
+	}
+	return q, nil
+}
@@ -0,0 +1,161 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	alertDb "github.com/go-sod/sod/internal/alert/database"
+	"github.com/go-sod/sod/internal/alert/model"
+	"github.com/go-sod/sod/internal/logging"
+	metricModel "github.com/go-sod/sod/internal/metric/model"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrIncidentNotFound = alertDb.ErrIncidentNotFound
+	ErrIncidentResolved = errors.New("incident is already resolved")
+)
+
+// IncidentManager manages the incidents of the entities
+type IncidentManager interface {
+	// Incidents returns the incidents of the entity with the status, the empty values do not filter
+	Incidents(ctx context.Context, entityID string, status model.Status) ([]model.Incident, error)
+	Incident(ctx context.Context, id uuid.UUID) (model.Incident, error)
+	// Acknowledge stops the notifications of the new outliers of the incident, the resolved alert is still sent
+	Acknowledge(ctx context.Context, id uuid.UUID, by string) (model.Incident, error)
+	// Silence stops the notifications of the incident for the duration, the zero duration removes the silence
+	Silence(ctx context.Context, id uuid.UUID, d time.Duration) (model.Incident, error)
+}
+
+func (m *manager) Incidents(ctx context.Context, entityID string, status model.Status) ([]model.Incident, error) {
+	incidents, err := m.alertDB.FindIncidents(ctx, func(incident model.Incident) bool {
+		return (entityID == "" || incident.EntityID == entityID) && (status == "" || incident.Status == status)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// the counters of the open incidents are stored on the state changes only
+	m.mtx.RLock()
+	for i := range incidents {
+		if open, ok := m.incidents[incidents[i].EntityID]; ok && open.ID == incidents[i].ID {
+			incidents[i] = *open
+		}
+	}
+	m.mtx.RUnlock()
+	sort.Slice(incidents, func(i, j int) bool {
+		return incidents[i].OpenedAt.After(incidents[j].OpenedAt)
+	})
+	return incidents, nil
+}
+
+func (m *manager) Incident(ctx context.Context, id uuid.UUID) (model.Incident, error) {
+	m.mtx.RLock()
+	for _, open := range m.incidents {
+		if open.ID == id {
+			incident := *open
+			m.mtx.RUnlock()
+			return incident, nil
+		}
+	}
+	m.mtx.RUnlock()
+	return m.alertDB.FindIncident(ctx, id)
+}
+
+func (m *manager) Acknowledge(ctx context.Context, id uuid.UUID, by string) (model.Incident, error) {
+	return m.updateIncident(ctx, id, func(incident *model.Incident) {
+		now := time.Now()
+		incident.AcknowledgedAt = &now
+		incident.AcknowledgedBy = by
+	})
+}
+
+func (m *manager) Silence(ctx context.Context, id uuid.UUID, d time.Duration) (model.Incident, error) {
+	return m.updateIncident(ctx, id, func(incident *model.Incident) {
+		if d <= 0 {
+			incident.SilencedUntil = nil
+			return
+		}
+		until := time.Now().Add(d)
+		incident.SilencedUntil = &until
+	})
+}
+
+func (m *manager) updateIncident(ctx context.Context, id uuid.UUID, fn func(*model.Incident)) (model.Incident, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	for _, open := range m.incidents {
+		if open.ID == id {
+			fn(open)
+			if err := m.alertDB.StoreIncident(ctx, *open); err != nil {
+				return model.Incident{}, fmt.Errorf("unable store incident: %w", err)
+			}
+			return *open, nil
+		}
+	}
+	if _, err := m.alertDB.FindIncident(ctx, id); err != nil {
+		return model.Incident{}, err
+	}
+	return model.Incident{}, ErrIncidentResolved
+}
+
+func (m *manager) Observe(metric metricModel.Metric) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	incident, ok := m.incidents[metric.EntityID]
+	if !ok {
+		return
+	}
+	if metric.Outlier {
+		m.normalRuns[metric.EntityID] = 0
+		return
+	}
+	m.normalRuns[metric.EntityID]++
+	if m.normalRuns[metric.EntityID] < m.opts.resolveAfter {
+		return
+	}
+
+	resolvedAt := metric.CreatedAt
+	incident.Status = model.StatusResolved
+	incident.ResolvedAt = &resolvedAt
+	delete(m.incidents, metric.EntityID)
+	delete(m.normalRuns, metric.EntityID)
+	if err := m.alertDB.StoreIncident(context.Background(), *incident); err != nil {
+		logging.FromContext(context.Background()).Errorf("unable store incident: %v", err)
+	}
+	if !incident.Silenced(time.Now()) {
+		m.queue[metric.EntityID] = append(m.queue[metric.EntityID], model.NewResolvedAlert(*incident))
+	}
+}
+
+// open returns the open incident of the entity, the new incident is opened by the outlier,
+// must be called under the lock
+func (m *manager) open(metric metricModel.Metric) *model.Incident {
+	if incident, ok := m.incidents[metric.EntityID]; ok {
+		return incident
+	}
+	incident := model.NewIncident(metric.EntityID, metric.CreatedAt)
+	m.incidents[metric.EntityID] = &incident
+	if err := m.alertDB.StoreIncident(context.Background(), incident); err != nil {
+		logging.FromContext(context.Background()).Errorf("unable store incident: %v", err)
+	}
+	return &incident
+}
+
+func (m *manager) loadIncidents(ctx context.Context) error {
+	incidents, err := m.alertDB.FindIncidents(ctx, func(incident model.Incident) bool {
+		return incident.Status == model.StatusFiring
+	})
+	if err != nil {
+		return fmt.Errorf("unable load incidents: %w", err)
+	}
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	for i := range incidents {
+		incident := incidents[i]
+		m.incidents[incident.EntityID] = &incident
+	}
+	return nil
+}
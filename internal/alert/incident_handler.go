@@ -0,0 +1,127 @@
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-sod/sod/internal/alert/model"
+	"github.com/go-sod/sod/internal/httputil"
+	"github.com/go-sod/sod/internal/logging"
+	"github.com/google/uuid"
+)
+
+const incidentsPath = "/alerts/incidents"
+
+// NewIncidentHandler returns the handler of the incidents, it serves the incidents path and its subpaths
+//
+// GET /alerts/incidents lists the incidents filtered by ?entity= and ?status=, GET ?id= returns one of them,
+// POST /alerts/incidents/ack?id=&by= acknowledges the incident
+// and POST /alerts/incidents/silence?id=&for=1h silences it, for=0 removes the silence
+func NewIncidentHandler(incidentManager IncidentManager) (http.Handler, error) {
+	return &incidentHandler{incidentManager: incidentManager}, nil
+}
+
+type incidentHandler struct {
+	incidentManager IncidentManager
+}
+
+func (h *incidentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	action := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, incidentsPath), "/")
+	switch {
+	case r.Method == "GET" && action == "":
+		h.list(w, r)
+	case r.Method == "POST" && action == "ack":
+		h.acknowledge(w, r)
+	case r.Method == "POST" && action == "silence":
+		h.silence(w, r)
+	case action != "" && action != "ack" && action != "silence":
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = fmt.Fprintf(w, `{"error": "path %s not found"}`, r.URL.Path)
+	default:
+		logger := logging.FromContext(r.Context())
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		logger.Debugf(`{"error": "method %v is not allowed"}`, r.Method)
+		_, _ = fmt.Fprintf(w, `{"error": "method %v is not allowed"}`, r.Method)
+	}
+}
+
+func (h *incidentHandler) list(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+	if query.Get("id") != "" {
+		id, err := uuid.Parse(query.Get("id"))
+		if err != nil {
+			httputil.RespBadRequestErrorf(ctx, w, `{"error": "invalid id: %v"}`, err)
+			return
+		}
+		incident, err := h.incidentManager.Incident(ctx, id)
+		if err != nil {
+			h.respError(w, r, err)
+			return
+		}
+		writeJSON(w, r, http.StatusOK, incident)
+		return
+	}
+	status := model.Status(query.Get("status"))
+	if status != "" && status != model.StatusFiring && status != model.StatusResolved {
+		httputil.RespBadRequestErrorf(ctx, w, `{"error": "unknown status %s"}`, status)
+		return
+	}
+	incidents, err := h.incidentManager.Incidents(ctx, query.Get("entity"), status)
+	if err != nil {
+		h.respError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, incidents)
+}
+
+func (h *incidentHandler) acknowledge(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := uuid.Parse(r.URL.Query().Get("id"))
+	if err != nil {
+		httputil.RespBadRequestErrorf(ctx, w, `{"error": "invalid id: %v"}`, err)
+		return
+	}
+	incident, err := h.incidentManager.Acknowledge(ctx, id, r.URL.Query().Get("by"))
+	if err != nil {
+		h.respError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, incident)
+}
+
+func (h *incidentHandler) silence(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := uuid.Parse(r.URL.Query().Get("id"))
+	if err != nil {
+		httputil.RespBadRequestErrorf(ctx, w, `{"error": "invalid id: %v"}`, err)
+		return
+	}
+	d, err := time.ParseDuration(r.URL.Query().Get("for"))
+	if err != nil {
+		httputil.RespBadRequestErrorf(ctx, w, `{"error": "invalid silence duration: %v"}`, err)
+		return
+	}
+	incident, err := h.incidentManager.Silence(ctx, id, d)
+	if err != nil {
+		h.respError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, incident)
+}
+
+func (h *incidentHandler) respError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrIncidentNotFound):
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = fmt.Fprintf(w, `{"error": %q}`, err.Error())
+	case errors.Is(err, ErrIncidentResolved):
+		w.WriteHeader(http.StatusConflict)
+		_, _ = fmt.Fprintf(w, `{"error": %q}`, err.Error())
+	default:
+		httputil.RespInternalErrorf(r.Context(), w, "incidents error: %v", err)
+	}
+}
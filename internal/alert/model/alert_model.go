@@ -7,18 +7,39 @@ import (
 	"github.com/google/uuid"
 )
 
+type Status string
+
+const (
+	StatusFiring   Status = "firing"
+	StatusResolved Status = "resolved"
+)
+
 func NewAlert(entityID string, metrics []model.Metric) Alert {
 	return Alert{
 		ID:        uuid.New(),
 		EntityID:  entityID,
+		Status:    StatusFiring,
 		Metrics:   metrics,
 		CreatedAt: time.Now(),
 	}
 }
 
+// NewResolvedAlert returns the alert notifying that the incident is closed
+func NewResolvedAlert(incident Incident) Alert {
+	return Alert{
+		ID:         uuid.New(),
+		EntityID:   incident.EntityID,
+		IncidentID: incident.ID,
+		Status:     StatusResolved,
+		CreatedAt:  time.Now(),
+	}
+}
+
 type Alert struct {
-	ID        uuid.UUID      `json:"id"`
-	EntityID  string         `json:"entityId"`
-	Metrics   []model.Metric `json:"metrics"`
-	CreatedAt time.Time      `json:"createdAt"`
+	ID         uuid.UUID      `json:"id"`
+	EntityID   string         `json:"entityId"`
+	IncidentID uuid.UUID      `json:"incidentId"`
+	Status     Status         `json:"status"`
+	Metrics    []model.Metric `json:"metrics"`
+	CreatedAt  time.Time      `json:"createdAt"`
 }
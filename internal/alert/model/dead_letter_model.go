@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func NewDeadLetter(alert Alert, sink string, attempts int, err error) DeadLetter {
+	return DeadLetter{
+		ID:        uuid.New(),
+		Alert:     alert,
+		Sink:      sink,
+		Attempts:  attempts,
+		LastError: err.Error(),
+		FailedAt:  time.Now(),
+	}
+}
+
+// DeadLetter is the alert not delivered to the target after all retries
+// or the alert of the entity without the route, then the sink is empty and the reason is set
+type DeadLetter struct {
+	ID        uuid.UUID `json:"id"`
+	Alert     Alert     `json:"alert"`
+	Sink      string    `json:"sink"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"lastError"`
+	FailedAt  time.Time `json:"failedAt"`
+	Reason    string    `json:"reason,omitempty"`
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type DeliveryStatus string
+
+const (
+	DeliveryStatusDelivered DeliveryStatus = "delivered"
+	DeliveryStatusFailed    DeliveryStatus = "failed"
+)
+
+func NewDelivery(alert Alert, sink string, attempts int, err error) Delivery {
+	d := Delivery{
+		ID:        uuid.New(),
+		Alert:     alert,
+		Sink:      sink,
+		Status:    DeliveryStatusDelivered,
+		Attempts:  attempts,
+		CreatedAt: time.Now(),
+	}
+	if err != nil {
+		d.Status = DeliveryStatusFailed
+		d.Error = err.Error()
+	}
+	return d
+}
+
+// Delivery is the result of sending the alert to the target
+type Delivery struct {
+	ID        uuid.UUID      `json:"id"`
+	Alert     Alert          `json:"alert"`
+	Sink      string         `json:"sink"`
+	Status    DeliveryStatus `json:"status"`
+	Attempts  int            `json:"attempts"`
+	Error     string         `json:"error,omitempty"`
+	CreatedAt time.Time      `json:"createdAt"`
+}
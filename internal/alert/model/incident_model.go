@@ -0,0 +1,38 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func NewIncident(entityID string, openedAt time.Time) Incident {
+	return Incident{
+		ID:       uuid.New(),
+		EntityID: entityID,
+		Status:   StatusFiring,
+		OpenedAt: openedAt,
+	}
+}
+
+// Incident is the run of the outliers of the entity, it is open until the run of the normal points
+type Incident struct {
+	ID             uuid.UUID  `json:"id"`
+	EntityID       string     `json:"entityId"`
+	Status         Status     `json:"status"`
+	Outliers       int        `json:"outliers"`
+	OpenedAt       time.Time  `json:"openedAt"`
+	LastOutlierAt  time.Time  `json:"lastOutlierAt"`
+	ResolvedAt     *time.Time `json:"resolvedAt,omitempty"`
+	AcknowledgedAt *time.Time `json:"acknowledgedAt,omitempty"`
+	AcknowledgedBy string     `json:"acknowledgedBy,omitempty"`
+	SilencedUntil  *time.Time `json:"silencedUntil,omitempty"`
+}
+
+func (i Incident) Acknowledged() bool {
+	return i.AcknowledgedAt != nil
+}
+
+func (i Incident) Silenced(now time.Time) bool {
+	return i.SilencedUntil != nil && now.Before(*i.SilencedUntil)
+}
@@ -0,0 +1,79 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/textproto"
+	"time"
+)
+
+// retryPolicy defines how many times and how often the failed delivery is repeated
+type retryPolicy struct {
+	maxRetries     int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+// backoff returns the delay before the next attempt, the delay doubles after every attempt up to the max backoff,
+// the half of the delay is random so the retries of the different alerts are spread out
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	d := p.initialBackoff
+	for i := 1; i < attempt && d < p.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.maxBackoff {
+		d = p.maxBackoff
+	}
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+	return half + time.Duration(rand.Int63n(int64(half)+1)) // nolint:gosec
+}
+
+// retry calls fn until it succeeds, fails with the permanent error or runs out of retries,
+// returns the number of attempts made
+func retry(ctx context.Context, policy retryPolicy, fn func() error) (int, error) {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt > policy.maxRetries || !retryable(err) {
+			return attempt, err
+		}
+		timer := time.NewTimer(policy.backoff(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return attempt, fmt.Errorf("retry interrupted: %w", ctx.Err())
+		}
+	}
+}
+
+type statusError struct {
+	code int
+	body []byte
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("response status %d: %s", e.code, e.body)
+}
+
+// retryable reports whether the failed delivery may succeed later,
+// the client errors are permanent except for the timeouts and the rate limiting,
+// the smtp errors are permanent with the 5xx codes
+func retryable(err error) bool {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return protoErr.Code < 500
+	}
+	var statusErr *statusError
+	if !errors.As(err, &statusErr) {
+		return true
+	}
+	return statusErr.code >= http.StatusInternalServerError ||
+		statusErr.code == http.StatusRequestTimeout ||
+		statusErr.code == http.StatusTooManyRequests
+}
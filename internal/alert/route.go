@@ -0,0 +1,151 @@
+package alert
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+type Routes []Route
+
+func (rs *Routes) Decode(value string) error {
+	routes := []Route{}
+	if err := json.Unmarshal([]byte(value), &routes); err != nil {
+		return err
+	}
+	*rs = routes
+	return nil
+}
+
+// Route sends the alerts of the matching entities to the sinks.
+// The entity is matched by the glob Entity or the Regex, the Default route is used for the entities without other routes.
+// From and To override the sender and the recipients of the mail sinks of the route
+type Route struct {
+	Entity  string   `json:"entity,omitempty"`
+	Regex   string   `json:"regex,omitempty"`
+	Default bool     `json:"default,omitempty"`
+	Sinks   []string `json:"sinks"`
+	From    string   `json:"from,omitempty"`
+	To      []string `json:"to,omitempty"`
+
+	re *regexp.Regexp
+}
+
+func (r *Route) compile() error {
+	if len(r.Sinks) == 0 {
+		return fmt.Errorf("route without sinks")
+	}
+	switch {
+	case r.Default:
+		if r.Entity != "" || r.Regex != "" {
+			return fmt.Errorf("default route can not match entities")
+		}
+	case r.Regex != "":
+		if r.Entity != "" {
+			return fmt.Errorf("route with both entity %s and regex %s", r.Entity, r.Regex)
+		}
+		re, err := regexp.Compile(r.Regex)
+		if err != nil {
+			return fmt.Errorf("route regex %s: %w", r.Regex, err)
+		}
+		r.re = re
+	case r.Entity != "":
+		if _, err := path.Match(r.Entity, ""); err != nil {
+			return fmt.Errorf("route entity pattern %s: %w", r.Entity, err)
+		}
+	default:
+		return fmt.Errorf("route matches nothing, define entity, regex or default")
+	}
+	return nil
+}
+
+func (r *Route) matches(entityID string) bool {
+	if r.re != nil {
+		return r.re.MatchString(entityID)
+	}
+	ok, _ := path.Match(r.Entity, entityID)
+	return ok
+}
+
+// newRouter adds the sinks with the addresses of the routes to sinks
+func newRouter(routes Routes, sinks map[string]Sink) (*router, error) {
+	rt := &router{cache: map[string][]Sink{}, sinks: sinks, bases: map[string]string{}}
+	for i := range routes {
+		route := routes[i]
+		if err := route.compile(); err != nil {
+			return nil, err
+		}
+		names := make([]string, 0, len(route.Sinks))
+		for _, name := range route.Sinks {
+			sink, ok := sinks[name]
+			if !ok {
+				return nil, fmt.Errorf("route refers to unknown sink %s", name)
+			}
+			if a, ok := sink.(addresser); ok && (route.From != "" || len(route.To) > 0) {
+				// the name is stable between the restarts for the replay of the dead letters
+				derived := fmt.Sprintf("%s[%s>%s]", name, route.From, strings.Join(route.To, ","))
+				if _, ok := sinks[derived]; !ok {
+					sinks[derived] = a.withAddresses(derived, route.From, route.To)
+					rt.bases[derived] = name
+				}
+				name = derived
+			}
+			names = append(names, name)
+		}
+		route.Sinks = names
+		if route.Default {
+			rt.defaults = append(rt.defaults, route.Sinks...)
+			continue
+		}
+		rt.routes = append(rt.routes, route)
+	}
+	return rt, nil
+}
+
+// router resolves the sinks of the entities
+type router struct {
+	mtx      sync.RWMutex
+	routes   []Route
+	defaults []string
+	sinks    map[string]Sink
+	// the configured sinks of the sinks with the addresses of the routes
+	bases map[string]string
+	// the sinks of the seen entities, the routes do not change
+	cache map[string][]Sink
+}
+
+// route returns the sinks of all routes matching the entity, every sink is returned once
+func (rt *router) route(entityID string) []Sink {
+	rt.mtx.RLock()
+	sinks, ok := rt.cache[entityID]
+	rt.mtx.RUnlock()
+	if ok {
+		return sinks
+	}
+
+	var names []string
+	for i := range rt.routes {
+		if rt.routes[i].matches(entityID) {
+			names = append(names, rt.routes[i].Sinks...)
+		}
+	}
+	if len(names) == 0 {
+		names = rt.defaults
+	}
+	seen := map[string]struct{}{}
+	for _, name := range names {
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		sinks = append(sinks, rt.sinks[name])
+	}
+
+	rt.mtx.Lock()
+	rt.cache[entityID] = sinks
+	rt.mtx.Unlock()
+	return sinks
+}
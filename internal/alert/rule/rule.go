@@ -0,0 +1,228 @@
+package rule
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-sod/sod/internal/geom"
+	"github.com/go-sod/sod/internal/metric/model"
+	"github.com/go-sod/sod/internal/telemetry"
+	"github.com/go-sod/sod/internal/timeutil"
+)
+
+// AnyEntity is the entity id of the rule applied to the entities without their own rule
+const AnyEntity = "*"
+
+// DefaultDedupWindow is the time the notified outliers are kept for the deduplication
+const DefaultDedupWindow = time.Hour
+
+const (
+	ReasonWindow   = "window"
+	ReasonCooldown = "cooldown"
+	ReasonDedup    = "dedup"
+)
+
+type Rules []Rule
+
+func (rs *Rules) Decode(value string) error {
+	rules := []Rule{}
+	if err := json.Unmarshal([]byte(value), &rules); err != nil {
+		return err
+	}
+	for _, r := range rules {
+		if err := r.validate(); err != nil {
+			return err
+		}
+	}
+	*rs = rules
+	return nil
+}
+
+// Rule gates the outliers of the entity before they are notified
+type Rule struct {
+	EntityID string `json:"entityId"`
+	// MinOutliers is the number of outliers within the window required to fire
+	MinOutliers int `json:"minOutliers"`
+	// WindowPoints is the window as the number of the last points of the entity
+	WindowPoints int `json:"windowPoints,omitempty"`
+	// WindowTime is the window as the time range of the last points of the entity,
+	// without both windows MinOutliers consecutive outliers are required
+	WindowTime timeutil.Duration `json:"windowTime,omitempty"`
+	// Cooldown suppresses the outliers after firing
+	Cooldown timeutil.Duration `json:"cooldown,omitempty"`
+	// DedupDistance suppresses the outliers closer than the distance to the already notified ones
+	DedupDistance float64 `json:"dedupDistance,omitempty"`
+	// DedupWindow is the time the notified outliers are kept for the deduplication
+	DedupWindow timeutil.Duration `json:"dedupWindow,omitempty"`
+}
+
+func (r Rule) validate() error {
+	if r.EntityID == "" {
+		return fmt.Errorf("alert rule entity id is not defined")
+	}
+	if r.MinOutliers < 0 || r.WindowPoints < 0 || r.WindowTime.Duration < 0 ||
+		r.Cooldown.Duration < 0 || r.DedupDistance < 0 || r.DedupWindow.Duration < 0 {
+		return fmt.Errorf("alert rule of entity %s has negative values", r.EntityID)
+	}
+	if r.WindowPoints > 0 && r.MinOutliers > r.WindowPoints {
+		return fmt.Errorf("alert rule of entity %s: min outliers %d exceed window points %d",
+			r.EntityID, r.MinOutliers, r.WindowPoints)
+	}
+	return nil
+}
+
+type point struct {
+	seq       uint64
+	createdAt time.Time
+}
+
+type notified struct {
+	vec       geom.Point
+	createdAt time.Time
+}
+
+type state struct {
+	seq uint64
+	// the points within the window
+	window []point
+	// the outliers within the window waiting for firing
+	pending  []model.Metric
+	seqs     []uint64
+	firedAt  time.Time
+	notified []notified
+}
+
+// NewEvaluator returns the evaluator of the rules, the entities without rules notify every outlier
+func NewEvaluator(rules Rules) *Evaluator {
+	e := &Evaluator{
+		rules:  map[string]Rule{},
+		states: map[string]*state{},
+	}
+	for _, r := range rules {
+		e.rules[r.EntityID] = r
+	}
+	return e
+}
+
+type Evaluator struct {
+	mtx    sync.Mutex
+	rules  map[string]Rule
+	states map[string]*state
+}
+
+func (e *Evaluator) rule(entityID string) (Rule, bool) {
+	if r, ok := e.rules[entityID]; ok {
+		return r, true
+	}
+	r, ok := e.rules[AnyEntity]
+	return r, ok
+}
+
+// Evaluate accepts every processed metric of the entity and returns the outliers to notify
+func (e *Evaluator) Evaluate(metric model.Metric) []model.Metric {
+	r, ok := e.rule(metric.EntityID)
+	if !ok {
+		if metric.Outlier {
+			return []model.Metric{metric}
+		}
+		return nil
+	}
+
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	s, ok := e.states[metric.EntityID]
+	if !ok {
+		s = &state{}
+		e.states[metric.EntityID] = s
+	}
+
+	s.seq++
+	s.window = append(s.window, point{seq: s.seq, createdAt: metric.CreatedAt})
+	s.trim(r, metric.Outlier)
+
+	if !metric.Outlier {
+		return nil
+	}
+
+	if s.duplicate(r, metric) {
+		telemetry.AlertsSuppressedTotal.WithLabelValues(metric.EntityID, ReasonDedup).Inc()
+		return nil
+	}
+
+	if r.Cooldown.Duration > 0 && !s.firedAt.IsZero() && metric.CreatedAt.Sub(s.firedAt) < r.Cooldown.Duration {
+		telemetry.AlertsSuppressedTotal.WithLabelValues(metric.EntityID, ReasonCooldown).Inc()
+		return nil
+	}
+
+	s.pending = append(s.pending, metric)
+	s.seqs = append(s.seqs, s.seq)
+	if len(s.pending) < r.MinOutliers {
+		telemetry.AlertsSuppressedTotal.WithLabelValues(metric.EntityID, ReasonWindow).Inc()
+		return nil
+	}
+
+	fired := s.pending
+	s.pending, s.seqs = nil, nil
+	s.firedAt = metric.CreatedAt
+	if r.DedupDistance > 0 {
+		for i := range fired {
+			s.notified = append(s.notified, notified{vec: fired[i].CheckedVec, createdAt: fired[i].CreatedAt})
+		}
+	}
+	return fired
+}
+
+// trim removes the points and the pending outliers out of the window
+func (s *state) trim(r Rule, outlier bool) {
+	if r.WindowPoints == 0 && r.WindowTime.Duration == 0 {
+		// without both windows the normal point breaks the run of the consecutive outliers
+		s.window = s.window[len(s.window)-1:]
+		if !outlier {
+			s.pending, s.seqs = nil, nil
+		}
+		return
+	}
+	last := s.window[len(s.window)-1]
+	if r.WindowPoints > 0 && len(s.window) > r.WindowPoints {
+		s.window = s.window[len(s.window)-r.WindowPoints:]
+	}
+	if r.WindowTime.Duration > 0 {
+		i := 0
+		for i < len(s.window) && last.createdAt.Sub(s.window[i].createdAt) > r.WindowTime.Duration {
+			i++
+		}
+		s.window = s.window[i:]
+	}
+	i := 0
+	for i < len(s.seqs) && s.seqs[i] < s.window[0].seq {
+		i++
+	}
+	s.pending, s.seqs = s.pending[i:], s.seqs[i:]
+}
+
+// duplicate reports whether the outlier is close to the notified one
+func (s *state) duplicate(r Rule, metric model.Metric) bool {
+	if r.DedupDistance <= 0 {
+		return false
+	}
+	window := r.DedupWindow.Duration
+	if window == 0 {
+		window = DefaultDedupWindow
+	}
+	kept := s.notified[:0]
+	for _, n := range s.notified {
+		if metric.CreatedAt.Sub(n.createdAt) <= window {
+			kept = append(kept, n)
+		}
+	}
+	s.notified = kept
+	for _, n := range s.notified {
+		d, err := geom.EuclideanDistance(metric.CheckedVec, n.vec)
+		if err == nil && d <= r.DedupDistance {
+			return true
+		}
+	}
+	return false
+}
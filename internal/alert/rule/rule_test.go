@@ -0,0 +1,92 @@
+package rule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-sod/sod/internal/geom"
+	"github.com/go-sod/sod/internal/metric/model"
+	"github.com/go-sod/sod/internal/timeutil"
+)
+
+func TestEvaluator_Evaluate(t *testing.T) {
+	t.Parallel()
+	start := time.Now()
+	// o - outlier, n - normal point, the points are one second apart
+	points := func(pattern string, vec func(i int) geom.Point) []model.Metric {
+		metrics := make([]model.Metric, len(pattern))
+		for i := range pattern {
+			metrics[i] = model.Metric{
+				EntityID:   "test",
+				Outlier:    pattern[i] == 'o',
+				CheckedVec: vec(i),
+				CreatedAt:  start.Add(time.Duration(i) * time.Second),
+			}
+		}
+		return metrics
+	}
+	distinct := func(i int) geom.Point { return geom.Point{float64(i * 10)} }
+	same := func(i int) geom.Point { return geom.Point{1} }
+	tests := []struct {
+		name     string
+		rules    Rules
+		metrics  []model.Metric
+		expected int
+	}{
+		{
+			name:     "positive_without_rules",
+			metrics:  points("onoon", distinct),
+			expected: 3,
+		},
+		{
+			name:     "positive_consecutive",
+			rules:    Rules{{EntityID: "test", MinOutliers: 3}},
+			metrics:  points("oonooonoo", distinct),
+			expected: 3,
+		},
+		{
+			name:     "positive_window_points",
+			rules:    Rules{{EntityID: "test", MinOutliers: 2, WindowPoints: 3}},
+			metrics:  points("onnonon", distinct),
+			expected: 2,
+		},
+		{
+			name:     "positive_window_time",
+			rules:    Rules{{EntityID: AnyEntity, MinOutliers: 2, WindowTime: timeutil.Duration{Duration: 2 * time.Second}}},
+			metrics:  points("onnonnnno", distinct),
+			expected: 0,
+		},
+		{
+			name:     "positive_cooldown",
+			rules:    Rules{{EntityID: "test", MinOutliers: 1, Cooldown: timeutil.Duration{Duration: 3 * time.Second}}},
+			metrics:  points("ooooo", distinct),
+			expected: 2,
+		},
+		{
+			name:     "positive_dedup",
+			rules:    Rules{{EntityID: "test", MinOutliers: 1, DedupDistance: 0.5}},
+			metrics:  points("onono", same),
+			expected: 1,
+		},
+		{
+			name:     "negative_other_entity_rule",
+			rules:    Rules{{EntityID: "other", MinOutliers: 5}},
+			metrics:  points("ooo", distinct),
+			expected: 3,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			e := NewEvaluator(test.rules)
+			notified := 0
+			for _, metric := range test.metrics {
+				notified += len(e.Evaluate(metric))
+			}
+			if notified != test.expected {
+				t.Errorf("notified outliers, got: %d, expected: %d", notified, test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-sod/sod/internal/alert/model"
+	"github.com/go-sod/sod/internal/httputil"
+)
+
+// Sink delivers the alerts to the receiver
+type Sink interface {
+	// Name identifies the sink in the routes, the dead letters and the history
+	Name() string
+	Send(ctx context.Context, alert model.Alert) error
+}
+
+type SinkType string
+
+const (
+	SinkTypeWebhook SinkType = "WEBHOOK"
+	SinkTypeSMTP    SinkType = "SMTP"
+)
+
+type SinkConfigs []SinkConfig
+
+func (cs *SinkConfigs) Decode(value string) error {
+	configs := []SinkConfig{}
+	if err := json.Unmarshal([]byte(value), &configs); err != nil {
+		return err
+	}
+	*cs = configs
+	return nil
+}
+
+// SinkConfig describes the sink, the fields except of the common ones depend on the type
+type SinkConfig struct {
+	Name  string       `json:"name"`
+	Type  SinkType     `json:"type"`
+	Retry *RetryConfig `json:"retry,omitempty"`
+
+	// WEBHOOK
+	URL        string                    `json:"url,omitempty"`
+	HTTPConfig httputil.HTTPClientConfig `json:"httpConfig"`
+	// Secret signs the requests, see the pkg/webhook
+	Secret string `json:"secret,omitempty"`
+	// Template is the text/template of the body, the default body is the alert json or the text digest of the mail
+	Template    string            `json:"template,omitempty"`
+	ContentType string            `json:"contentType,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+
+	// SMTP
+	SMTP *SMTPConfig `json:"smtp,omitempty"`
+	From string      `json:"from,omitempty"`
+	To   []string    `json:"to,omitempty"`
+	// Subject is the text/template of the mail subject
+	Subject string `json:"subject,omitempty"`
+}
+
+// addresser is the sink with the sender and the recipients overridden by the routes
+type addresser interface {
+	withAddresses(name, from string, to []string) Sink
+}
+
+// newSink returns the sink of the type, the empty type is the webhook
+func newSink(cfg SinkConfig, requestTimeout time.Duration) (Sink, error) {
+	if cfg.Name == "" {
+		return nil, fmt.Errorf("sink name is not defined")
+	}
+	switch cfg.Type {
+	case SinkTypeWebhook, "":
+		return newWebhookSink(cfg, requestTimeout)
+	case SinkTypeSMTP:
+		return newSMTPSink(cfg, requestTimeout)
+	default:
+		return nil, fmt.Errorf("unknown type %s of sink %s", cfg.Type, cfg.Name)
+	}
+}
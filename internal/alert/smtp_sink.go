@@ -0,0 +1,202 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"hash/fnv"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/go-sod/sod/internal/alert/model"
+)
+
+const (
+	defaultSMTPSubject = `[SOD] {{ .EntityID }} {{ .Status }}{{ with .Outliers }}: {{ len . }} outliers{{ end }}`
+	defaultSMTPBody    = `Entity: {{ .EntityID }}
+Incident: {{ .IncidentID }}
+Status: {{ .Status }}
+{{ range .Outliers }}
+Time:        {{ formatTime .CreatedAt "2006-01-02 15:04:05 MST" }}
+Outlier:     {{ vector .OutlierVec }}
+Last normal: {{ vector .NormalVec }}
+Score:       {{ score .Score 3 }} (threshold {{ score .Threshold 3 }}, confidence {{ score .Confidence 3 }})
+{{- with .Extra }}
+Extra:       {{ extra . }}{{ end }}
+{{ end }}`
+)
+
+// SMTPConfig is the mail server of the SMTP sink
+type SMTPConfig struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+	// StartTLS requires the server to support STARTTLS, otherwise it is used when the server offers it
+	StartTLS           bool   `json:"startTls,omitempty"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+	Username           string `json:"username,omitempty"`
+	Password           string `json:"password,omitempty"`
+}
+
+func newSMTPSink(cfg SinkConfig, requestTimeout time.Duration) (*smtpSink, error) {
+	if cfg.SMTP == nil || cfg.SMTP.Host == "" {
+		return nil, fmt.Errorf("smtp server of sink %s is not defined", cfg.Name)
+	}
+	if cfg.From == "" || len(cfg.To) == 0 {
+		return nil, fmt.Errorf("sender and recipients of sink %s are not defined", cfg.Name)
+	}
+	subject, body := cfg.Subject, cfg.Template
+	if subject == "" {
+		subject = defaultSMTPSubject
+	}
+	if body == "" {
+		body = defaultSMTPBody
+	}
+	s := &smtpSink{
+		name:           cfg.Name,
+		server:         *cfg.SMTP,
+		from:           cfg.From,
+		to:             cfg.To,
+		requestTimeout: requestTimeout,
+	}
+	if s.server.Port == 0 {
+		s.server.Port = 25
+	}
+	var err error
+	if s.subject, err = newTemplate(cfg.Name+" subject", subject); err != nil {
+		return nil, err
+	}
+	if s.body, err = newTemplate(cfg.Name+" body", body); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// smtpSink mails the digest of the alert outliers
+type smtpSink struct {
+	name           string
+	server         SMTPConfig
+	from           string
+	to             []string
+	subject        *template.Template
+	body           *template.Template
+	requestTimeout time.Duration
+}
+
+func (s *smtpSink) Name() string {
+	return s.name
+}
+
+// withAddresses returns the copy of the sink with the sender and the recipients of the route
+func (s *smtpSink) withAddresses(name, from string, to []string) Sink {
+	c := *s
+	c.name = name
+	if from != "" {
+		c.from = from
+	}
+	if len(to) > 0 {
+		c.to = to
+	}
+	return &c
+}
+
+func (s *smtpSink) Send(ctx context.Context, alert model.Alert) error {
+	msg, err := s.message(alert)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.requestTimeout)
+	defer cancel()
+	addr := net.JoinHostPort(s.server.Host, strconv.Itoa(s.server.Port))
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("dial smtp server: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, s.server.Host)
+	if err != nil {
+		return fmt.Errorf("smtp greeting: %w", err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{
+			ServerName:         s.server.Host,
+			InsecureSkipVerify: s.server.InsecureSkipVerify, // nolint:gosec
+		}); err != nil {
+			return fmt.Errorf("smtp starttls: %w", err)
+		}
+	} else if s.server.StartTLS {
+		return fmt.Errorf("smtp server %s does not support STARTTLS", addr)
+	}
+	if s.server.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.server.Username, s.server.Password, s.server.Host)); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+	if err := c.Mail(s.from); err != nil {
+		return fmt.Errorf("smtp mail from: %w", err)
+	}
+	for _, to := range s.to {
+		if err := c.Rcpt(to); err != nil {
+			return fmt.Errorf("smtp rcpt to %s: %w", to, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("smtp write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp message: %w", err)
+	}
+	return c.Quit()
+}
+
+func (s *smtpSink) message(alert model.Alert) ([]byte, error) {
+	subject, err := render(s.subject, alert)
+	if err != nil {
+		return nil, err
+	}
+	body, err := render(s.body, alert)
+	if err != nil {
+		return nil, err
+	}
+	var msg bytes.Buffer
+	headers := [][2]string{
+		{"From", s.from},
+		{"To", strings.Join(s.to, ", ")},
+		{"Subject", mime.QEncoding.Encode("utf-8", strings.TrimSpace(string(subject)))},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"Message-ID", s.messageID(alert)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "text/plain; charset=utf-8"},
+		{"Content-Transfer-Encoding", "8bit"},
+	}
+	for _, h := range headers {
+		fmt.Fprintf(&msg, "%s: %s\r\n", h[0], h[1])
+	}
+	msg.WriteString("\r\n")
+	msg.Write(body)
+	return msg.Bytes(), nil
+}
+
+// messageID returns the id of the message of the alert, the sink is hashed
+// as its name derived from the route may have the characters not allowed in the id
+func (s *smtpSink) messageID(alert model.Alert) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(s.name))
+	return fmt.Sprintf("<%s.%08x@sod>", alert.ID, h.Sum32())
+}
@@ -0,0 +1,89 @@
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/go-sod/sod/internal/alert/model"
+)
+
+// templateData is the alert passed to the templates of the sinks
+type templateData struct {
+	EntityID   string
+	IncidentID string
+	Status     model.Status
+	CreatedAt  time.Time
+	Outliers   []data
+}
+
+func newTemplateData(alert model.Alert) templateData {
+	return templateData{
+		EntityID:   alert.EntityID,
+		IncidentID: alert.IncidentID.String(),
+		Status:     alert.Status,
+		CreatedAt:  alert.CreatedAt,
+		Outliers:   makeRequest(alert).Data,
+	}
+}
+
+var templateFuncs = template.FuncMap{
+	// json encodes the value, e.g. the string inside of the json template is {{ json .EntityID }}
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	// score formats the score with the precision
+	"score": func(v float64, precision int) string {
+		return strconv.FormatFloat(v, 'f', precision, 64)
+	},
+	// vector formats the vector as "[1.5, 2, 3]"
+	"vector": func(v []float64) string {
+		values := make([]string, len(v))
+		for i := range v {
+			values[i] = strconv.FormatFloat(v[i], 'g', -1, 64)
+		}
+		return "[" + strings.Join(values, ", ") + "]"
+	},
+	// extra returns the string extra as is and encodes the other extra values to json
+	"extra": func(v interface{}) (string, error) {
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	// extraField returns the field of the json object extra, or nil
+	"extraField": func(v interface{}, name string) interface{} {
+		if fields, ok := v.(map[string]interface{}); ok {
+			return fields[name]
+		}
+		return nil
+	},
+	"formatTime": func(t time.Time, layout string) string {
+		return t.Format(layout)
+	},
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+func newTemplate(name, text string) (*template.Template, error) {
+	t, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse template %s: %w", name, err)
+	}
+	return t, nil
+}
+
+func render(t *template.Template, alert model.Alert) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, newTemplateData(alert)); err != nil {
+		return nil, fmt.Errorf("execute template %s: %w", t.Name(), err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,170 @@
+package alert
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"text/template"
+	"time"
+
+	"github.com/go-sod/sod/internal/alert/model"
+	"github.com/go-sod/sod/internal/httputil"
+	"github.com/go-sod/sod/pkg/webhook"
+)
+
+const UserAgent = "SOD/0.1"
+
+type data struct {
+	NormalVec  []float64   `json:"norm"`
+	OutlierVec []float64   `json:"outlier"`
+	Score      float64     `json:"score"`
+	Threshold  float64     `json:"threshold"`
+	Confidence float64     `json:"confidence"`
+	CreatedAt  time.Time   `json:"createdAt"`
+	Extra      interface{} `json:"extra"`
+}
+
+type request struct {
+	EntityID   string       `json:"entityId"`
+	IncidentID string       `json:"incidentId"`
+	Status     model.Status `json:"status"`
+	Data       []data       `json:"data"`
+}
+
+func makeRequest(alert model.Alert) request {
+	outliers := make([]data, len(alert.Metrics))
+	for i, metric := range alert.Metrics {
+		outliers[i] = data{
+			NormalVec:  metric.NormVec,
+			OutlierVec: metric.CheckedVec,
+			Score:      metric.Score,
+			Threshold:  metric.Threshold,
+			Confidence: metric.Confidence,
+			CreatedAt:  metric.CreatedAt,
+			Extra:      metric.Extra,
+		}
+	}
+	return request{
+		EntityID:   alert.EntityID,
+		IncidentID: alert.IncidentID.String(),
+		Status:     alert.Status,
+		Data:       outliers,
+	}
+}
+
+func newWebhookSink(cfg SinkConfig, requestTimeout time.Duration) (*webhookSink, error) {
+	link, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("url of sink %s: %w", cfg.Name, err)
+	}
+	client, err := httputil.NewClientFromConfig(cfg.HTTPConfig, true)
+	if err != nil {
+		return nil, fmt.Errorf("unable crate client for sink %s: %w", cfg.Name, err)
+	}
+	s := &webhookSink{
+		name:           cfg.Name,
+		url:            link.String(),
+		secret:         []byte(cfg.Secret),
+		client:         client,
+		requestTimeout: requestTimeout,
+		contentType:    cfg.ContentType,
+		headers:        cfg.Headers,
+	}
+	if s.contentType == "" {
+		s.contentType = "application/json"
+	}
+	if cfg.Template != "" {
+		if s.template, err = newTemplate(cfg.Name, cfg.Template); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// webhookSink POSTs the alert json or the rendered template to the url
+type webhookSink struct {
+	name           string
+	url            string
+	secret         []byte
+	client         *http.Client
+	requestTimeout time.Duration
+	template       *template.Template
+	contentType    string
+	headers        map[string]string
+}
+
+func (s *webhookSink) Name() string {
+	return s.name
+}
+
+func (s *webhookSink) Send(ctx context.Context, alert model.Alert) error {
+	ctx, cancel := context.WithTimeout(ctx, s.requestTimeout)
+	defer cancel()
+
+	body, err := s.body(alert)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request error: %w", err)
+	}
+
+	req.Header.Set("Content-Type", s.contentType)
+	req.Header.Add("User-Agent", UserAgent)
+	req.Header.Add("Accept-Encoding", "gzip")
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	if len(s.secret) > 0 {
+		webhook.SignRequest(req, s.secret, alert.ID.String(), body)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending request error: %w", err)
+	}
+
+	defer resp.Body.Close()
+
+	var reader io.ReadCloser
+	switch resp.Header.Get("Content-Encoding") {
+	case "gzip":
+		reader, err = gzip.NewReader(resp.Body)
+		if err != nil {
+			return fmt.Errorf("unable create gzip.NewReader: %w", err)
+		}
+		defer reader.Close()
+	default:
+		reader = resp.Body
+	}
+
+	respBody, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return &statusError{code: resp.StatusCode, body: respBody}
+	}
+
+	return nil
+}
+
+func (s *webhookSink) body(alert model.Alert) ([]byte, error) {
+	if s.template != nil {
+		return render(s.template, alert)
+	}
+	body, err := json.Marshal(makeRequest(alert))
+	if err != nil {
+		return nil, fmt.Errorf("unable encode json data: %w", err)
+	}
+	return body, nil
+}
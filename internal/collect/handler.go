@@ -3,9 +3,11 @@ package collect
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"sort"
+	"strconv"
 	"time"
 
 	"github.com/go-sod/sod/internal/dispatcher"
@@ -20,13 +22,37 @@ const maxBodyBytes = 64 * 1024 * 1024
 type request struct {
 	EntityID string `json:"entity"`
 	Data     []struct {
+		// optional client id, the retried points with the same id are skipped
+		ID        string      `json:"id"`
 		Vec       []float64   `json:"vector"`
 		Extra     interface{} `json:"extra"`
 		CreatedAt time.Time   `json:"createdAt"`
 	} `json:"data"`
 }
 
-func NewHandler(cfg *Config, outlier dispatcher.Collector) (http.Handler, error) {
+// SyncHeader is the header of the synchronous collection, the same as the query parameter sync=true
+const SyncHeader = "X-Sod-Sync"
+
+type syncResponse struct {
+	EntityID string       `json:"entity"`
+	Data     []verdictDTO `json:"data"`
+}
+
+type verdictDTO struct {
+	ID         string      `json:"id"`
+	Outlier    bool        `json:"outlier"`
+	Classified bool        `json:"classified"`
+	Score      float64     `json:"score"`
+	Threshold  float64     `json:"threshold"`
+	Confidence float64     `json:"confidence"`
+	Vec        []float64   `json:"vector"`
+	Extra      interface{} `json:"extra"`
+	CreatedAt  time.Time   `json:"createdAt"`
+	Duplicate  bool        `json:"duplicate,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+func NewHandler(cfg *Config, outlier dispatcher.SyncCollector) (http.Handler, error) {
 	s := &handler{
 		outlier: outlier,
 		cfg:     cfg,
@@ -35,7 +61,7 @@ func NewHandler(cfg *Config, outlier dispatcher.Collector) (http.Handler, error)
 }
 
 type handler struct {
-	outlier dispatcher.Collector
+	outlier dispatcher.SyncCollector
 	cfg     *Config
 }
 
@@ -68,21 +94,118 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if isSync(r) {
+		h.collectSync(ctx, w, req)
+		return
+	}
+
 	defer func() {
 		logger.Infof("Collected value for bucket %s", req.EntityID)
 	}()
-	go func() {
-		sort.Slice(req.Data, func(i, j int) bool {
-			return req.Data[i].CreatedAt.Before(req.Data[j].CreatedAt)
-		})
-		for _, dat := range req.Data {
-			if err := h.outlier.Collect(
-				model.NewMetric(req.EntityID, geom.NewPoint(dat.Vec), dat.CreatedAt, dat.Extra),
-			); err != nil {
-				logger.Errorf("error sending to collect service: %v", err)
+	sort.Slice(req.Data, func(i, j int) bool {
+		return req.Data[i].CreatedAt.Before(req.Data[j].CreatedAt)
+	})
+	// the queues are bounded, the request fails fast when the queue of the entity is full
+	accepted, duplicates := 0, 0
+	for _, dat := range req.Data {
+		if err := h.outlier.Collect(newMetric(req.EntityID, dat.ID, dat.Vec, dat.CreatedAt, dat.Extra)); err != nil {
+			if errors.Is(err, dispatcher.ErrDuplicate) {
+				duplicates++
+				continue
 			}
+			if errors.Is(err, dispatcher.ErrQueueFull) {
+				httputil.RespTooManyRequestsErrorf(ctx, w, `{"error": "queue of entity %s is full", "accepted": %d}`, req.EntityID, accepted)
+				return
+			}
+			httputil.RespServiceUnavailableErrorf(ctx, w, `{"error": %q, "accepted": %d}`, err.Error(), accepted)
+			return
 		}
-	}()
+		accepted++
+	}
 	w.WriteHeader(http.StatusOK)
-	_, _ = fmt.Fprintf(w, `{"status": "ok"}`)
+	_, _ = fmt.Fprintf(w, `{"status": "ok", "accepted": %d, "duplicates": %d}`, accepted, duplicates)
+}
+
+// newMetric returns the metric, the id of the metric with the client id is derived from it
+func newMetric(entityID, id string, vec []float64, createdAt time.Time, extra interface{}) model.Metric {
+	metric := model.NewMetric(entityID, geom.NewPoint(vec), createdAt, extra)
+	if id != "" {
+		return metric.WithKey(id)
+	}
+	return metric
+}
+
+func isSync(r *http.Request) bool {
+	v := r.URL.Query().Get("sync")
+	if v == "" {
+		v = r.Header.Get(SyncHeader)
+	}
+	sync, _ := strconv.ParseBool(v)
+	return sync
+}
+
+// collectSync waits for the verdicts of the points, the points are collected in the order of the time
+// and the verdicts are returned in the order of the request.
+// The request fails when every point not skipped as duplicate failed, with 429 when the queue is full or 503 otherwise,
+// the body keeps the verdicts of the points
+func (h *handler) collectSync(ctx context.Context, w http.ResponseWriter, req request) {
+	order := make([]int, len(req.Data))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return req.Data[order[i]].CreatedAt.Before(req.Data[order[j]].CreatedAt)
+	})
+	metrics := make([]model.Metric, len(order))
+	for i, idx := range order {
+		dat := req.Data[idx]
+		metrics[i] = newMetric(req.EntityID, dat.ID, dat.Vec, dat.CreatedAt, dat.Extra)
+	}
+
+	resp := syncResponse{EntityID: req.EntityID, Data: make([]verdictDTO, len(metrics))}
+	failed, duplicates, overflow := 0, 0, false
+	for i, v := range h.outlier.CollectSync(ctx, metrics...) {
+		dto := verdictDTO{
+			ID:         v.Metric.ID.String(),
+			Outlier:    v.Metric.Outlier,
+			Classified: v.Classified,
+			Score:      v.Metric.Score,
+			Threshold:  v.Metric.Threshold,
+			Confidence: v.Metric.Confidence,
+			Vec:        v.Metric.CheckedVec.Points(),
+			Extra:      v.Metric.Extra,
+			CreatedAt:  v.Metric.CreatedAt,
+		}
+		if v.Err != nil {
+			dto.Duplicate = errors.Is(v.Err, dispatcher.ErrDuplicate)
+			dto.Error = v.Err.Error()
+			switch {
+			case dto.Duplicate:
+				duplicates++
+			case errors.Is(v.Err, dispatcher.ErrQueueFull), errors.Is(v.Err, dispatcher.ErrDropped):
+				overflow = true
+				failed++
+			default:
+				failed++
+			}
+		}
+		resp.Data[order[i]] = dto
+	}
+
+	bytes, err := json.Marshal(resp)
+	if err != nil {
+		httputil.RespInternalErrorf(ctx, w, "failed to encode output json %v", err)
+		return
+	}
+	status := http.StatusOK
+	if failed > 0 && failed+duplicates == len(metrics) {
+		status = http.StatusServiceUnavailable
+		if overflow {
+			status = http.StatusTooManyRequests
+			w.Header().Set("Retry-After", "1")
+		}
+		logging.FromContext(ctx).Debugf("no point of entity %s is accepted, %d failed", req.EntityID, failed)
+	}
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, "%s", bytes)
 }
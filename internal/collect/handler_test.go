@@ -0,0 +1,217 @@
+package collect
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sod/sod/internal/dispatcher"
+	"github.com/go-sod/sod/internal/metric/model"
+)
+
+// collector returns the errors of the points by the first coordinate of the vector
+type collector struct {
+	errs      map[float64]error
+	collected []model.Metric
+}
+
+func (c *collector) Collect(in ...model.Metric) error {
+	for _, metric := range in {
+		if err := c.errs[metric.CheckedVec[0]]; err != nil {
+			return err
+		}
+		c.collected = append(c.collected, metric)
+	}
+	return nil
+}
+
+func (c *collector) CollectSync(_ context.Context, in ...model.Metric) []dispatcher.Verdict {
+	verdicts := make([]dispatcher.Verdict, len(in))
+	for i, metric := range in {
+		if err := c.errs[metric.CheckedVec[0]]; err != nil {
+			verdicts[i] = dispatcher.Verdict{Metric: metric, Err: err}
+			continue
+		}
+		c.collected = append(c.collected, metric)
+		metric.Outlier, metric.Score = metric.CheckedVec[0] > 10, metric.CheckedVec[0]
+		verdicts[i] = dispatcher.Verdict{Metric: metric, Classified: true}
+	}
+	return verdicts
+}
+
+// body returns the request of the points with the vectors created a second apart in the reverse order
+func body(vectors ...float64) string {
+	data := make([]string, len(vectors))
+	start := time.Date(2020, 10, 20, 0, 0, 0, 0, time.UTC)
+	for i, v := range vectors {
+		createdAt := start.Add(time.Duration(len(vectors)-i) * time.Second).Format(time.RFC3339)
+		data[i] = fmt.Sprintf(`{"vector": [%v], "createdAt": %q}`, v, createdAt)
+	}
+	return fmt.Sprintf(`{"entity": "weather", "data": [%s]}`, strings.Join(data, ","))
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+	duplicate := fmt.Errorf("test: %w", dispatcher.ErrDuplicate)
+	tests := []struct {
+		name               string
+		body               string
+		errs               map[float64]error
+		expectedStatus     int
+		expectedAccepted   int
+		expectedDuplicates int
+	}{
+		{
+			name:             "positive_collect",
+			body:             body(1, 2, 3),
+			expectedStatus:   http.StatusOK,
+			expectedAccepted: 3,
+		},
+		{
+			name:               "positive_collect_duplicates",
+			body:               body(1, 2, 3),
+			errs:               map[float64]error{2: duplicate},
+			expectedStatus:     http.StatusOK,
+			expectedAccepted:   2,
+			expectedDuplicates: 1,
+		},
+		{
+			name:             "negative_queue_full_partial_accept",
+			body:             body(1, 2, 3),
+			errs:             map[float64]error{2: dispatcher.ErrQueueFull},
+			expectedStatus:   http.StatusTooManyRequests,
+			expectedAccepted: 1,
+		},
+		{
+			name:           "negative_unavailable",
+			body:           body(1),
+			errs:           map[float64]error{1: errors.New("test-err")},
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			c := &collector{errs: test.errs}
+			h, _ := NewHandler(&Config{RequestTimeout: time.Second}, c)
+			req := httptest.NewRequest(http.MethodPost, "/collect", strings.NewReader(test.body))
+			req.Header.Set("content-type", "application/json")
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if w.Code != test.expectedStatus {
+				t.Fatalf("status, got: %d (%s), expected: %d", w.Code, w.Body.String(), test.expectedStatus)
+			}
+			if test.expectedStatus == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+				t.Errorf("Retry-After is not set")
+			}
+			expected := fmt.Sprintf(`"accepted": %d`, test.expectedAccepted)
+			if !strings.Contains(w.Body.String(), expected) {
+				t.Errorf("body, got: %s, expected to contain: %s", w.Body.String(), expected)
+			}
+			if w.Code == http.StatusOK && !strings.Contains(w.Body.String(), fmt.Sprintf(`"duplicates": %d`, test.expectedDuplicates)) {
+				t.Errorf("body, got: %s, expected duplicates: %d", w.Body.String(), test.expectedDuplicates)
+			}
+			// the points are collected in the order of the time
+			for i := 1; i < len(c.collected); i++ {
+				if c.collected[i].CreatedAt.Before(c.collected[i-1].CreatedAt) {
+					t.Errorf("collected point %d is older than the previous one", i)
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_Sync(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name           string
+		vectors        []float64
+		errs           map[float64]error
+		expectedStatus int
+		// the errors and the duplicate flags of the verdicts in the order of the request
+		expectedErrs       []bool
+		expectedDuplicates []bool
+	}{
+		{
+			name:               "positive_sync",
+			vectors:            []float64{1, 20},
+			expectedStatus:     http.StatusOK,
+			expectedErrs:       []bool{false, false},
+			expectedDuplicates: []bool{false, false},
+		},
+		{
+			name:               "positive_sync_partial_failure",
+			vectors:            []float64{1, 2},
+			errs:               map[float64]error{2: dispatcher.ErrQueueFull},
+			expectedStatus:     http.StatusOK,
+			expectedErrs:       []bool{false, true},
+			expectedDuplicates: []bool{false, false},
+		},
+		{
+			name:               "positive_sync_duplicates",
+			vectors:            []float64{1, 2},
+			errs:               map[float64]error{1: dispatcher.ErrDuplicate, 2: dispatcher.ErrDuplicate},
+			expectedStatus:     http.StatusOK,
+			expectedErrs:       []bool{true, true},
+			expectedDuplicates: []bool{true, true},
+		},
+		{
+			name:               "negative_sync_queue_full",
+			vectors:            []float64{1, 2, 3},
+			errs:               map[float64]error{1: dispatcher.ErrQueueFull, 2: dispatcher.ErrDropped, 3: dispatcher.ErrDuplicate},
+			expectedStatus:     http.StatusTooManyRequests,
+			expectedErrs:       []bool{true, true, true},
+			expectedDuplicates: []bool{false, false, true},
+		},
+		{
+			name:               "negative_sync_unavailable",
+			vectors:            []float64{1, 2},
+			errs:               map[float64]error{1: context.DeadlineExceeded, 2: errors.New("test-err")},
+			expectedStatus:     http.StatusServiceUnavailable,
+			expectedErrs:       []bool{true, true},
+			expectedDuplicates: []bool{false, false},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			h, _ := NewHandler(&Config{RequestTimeout: time.Second}, &collector{errs: test.errs})
+			req := httptest.NewRequest(http.MethodPost, "/collect?sync=true", strings.NewReader(body(test.vectors...)))
+			req.Header.Set("content-type", "application/json")
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if w.Code != test.expectedStatus {
+				t.Errorf("status, got: %d, expected: %d", w.Code, test.expectedStatus)
+			}
+
+			// the verdicts are kept in the body of the failed request too
+			var resp syncResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response %s: %v", w.Body.String(), err)
+			}
+			if len(resp.Data) != len(test.vectors) {
+				t.Fatalf("verdicts, got: %d, expected: %d", len(resp.Data), len(test.vectors))
+			}
+			for i, v := range resp.Data {
+				if len(v.Vec) > 0 && v.Vec[0] != test.vectors[i] {
+					t.Errorf("verdict %d is out of the request order, got vector: %v", i, v.Vec)
+				}
+				if (v.Error != "") != test.expectedErrs[i] || v.Duplicate != test.expectedDuplicates[i] {
+					t.Errorf("verdict %d, got error: %q, duplicate: %v, expected error: %v, duplicate: %v",
+						i, v.Error, v.Duplicate, test.expectedErrs[i], test.expectedDuplicates[i])
+				}
+				if v.Error == "" && (!v.Classified || v.Outlier != (test.vectors[i] > 10)) {
+					t.Errorf("verdict %d, got: %+v", i, v)
+				}
+			}
+		})
+	}
+}
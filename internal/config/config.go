@@ -7,8 +7,11 @@ import (
 	"github.com/go-sod/sod/internal/dispatcher"
 	"github.com/go-sod/sod/internal/predict"
 	"github.com/go-sod/sod/internal/predictor"
+	"github.com/go-sod/sod/internal/remotewrite"
+	"github.com/go-sod/sod/internal/rpc"
 	"github.com/go-sod/sod/internal/scrape"
 	"github.com/go-sod/sod/internal/setup"
+	"github.com/go-sod/sod/internal/stream"
 )
 
 var (
@@ -34,6 +37,9 @@ type Config struct {
 	Scrape      scrape.Config
 	Predictor   predictor.Config
 	Alert       alert.Config
+	RemoteWrite remotewrite.Config
+	Stream      stream.Config
+	GRPC        rpc.Config
 }
 
 func (c Config) SvcMode() string {
@@ -2,6 +2,9 @@ package dispatcher
 
 import (
 	"time"
+
+	"github.com/go-sod/sod/internal/alert/rule"
+	"github.com/go-sod/sod/pkg/iqueue"
 )
 
 type Config struct {
@@ -21,4 +24,20 @@ type Config struct {
 	AllowAppendData bool `envconfig:"SOD_OUTLIER_ALLOW_APPEND_DATA" default:"true"`
 	// Allow adding outliers to the dataset
 	AllowAppendOutlier bool `envconfig:"SOD_OUTLIER_ALLOW_APPEND_OUTLIER" default:"true"`
+	// Rules gating the outliers of the entities before the notification
+	AlertRules rule.Rules `envconfig:"SOD_ALERT_RULES"`
+	// Maximum number of the queued metrics of each entity, the queues are unbounded with 0
+	QueueCapacity int `envconfig:"SOD_QUEUE_CAPACITY" default:"10000"`
+	// Behavior of the full queue: BLOCK, DROP_OLDEST, DROP_NEWEST or REJECT
+	QueueOverflow iqueue.Overflow `envconfig:"SOD_QUEUE_OVERFLOW" default:"BLOCK"`
+	// Maximum waiting time of the collection with the BLOCK overflow, the collection is rejected after it
+	QueueBlockTimeout time.Duration `envconfig:"SOD_QUEUE_BLOCK_TIMEOUT" default:"1s"`
+	// Number of the workers processing the queues, the entities are sharded across the workers, NumCPU*2 with 0
+	Workers int `envconfig:"SOD_WORKERS"`
+	// Dedup key of the metrics without the client id: NONE or CONTENT, built from the creation time and the vector
+	DedupKey DedupKey `envconfig:"SOD_DEDUP_KEY" default:"NONE"`
+	// Allowed lateness of the metrics of each entity, the metrics are reordered within it, 0 disables the reordering
+	AllowedLateness time.Duration `envconfig:"SOD_OUTLIER_ALLOWED_LATENESS" default:"0s"`
+	// Behavior with the metrics older than the watermark: DROP or BUCKET, storing them to the late bucket of the entity
+	LatePolicy LatePolicy `envconfig:"SOD_LATE_POLICY" default:"DROP"`
 }
@@ -8,6 +8,7 @@ import (
 
 	"github.com/go-sod/sod/internal/logging"
 	"github.com/go-sod/sod/internal/metric/model"
+	"github.com/go-sod/sod/internal/telemetry"
 )
 
 // Scheduler options
@@ -15,7 +16,9 @@ type dbSchedulerConfig struct {
 	maxItemsStored int
 	maxStorageTime time.Duration
 	rebuildDBTime  time.Duration
-	deps           pullDependencies
+	// storage limits of the entity overriding the global ones
+	retentionFn func(entityID string) (int, time.Duration)
+	deps        pullDependencies
 }
 
 // return *dbScheduler with dbSchedulerConfig options
@@ -29,13 +32,22 @@ type dbScheduler struct {
 	opts dbSchedulerConfig
 }
 
+// retention returns the maximum number of elements and the retention period of the entity
+func (s *dbScheduler) retention(entityID string) (int, time.Duration) {
+	if s.opts.retentionFn == nil {
+		return s.opts.maxItemsStored, s.opts.maxStorageTime
+	}
+	return s.opts.retentionFn(entityID)
+}
+
 // @TODO not optimal for memory usage
 // processOutdatedMetrics retrieves all metrics for the specified entity, filters, leaving the oldest metrics,
 // and performs bulk deletion.
 func (s *dbScheduler) processOutdatedMetrics(entityID string) error {
+	_, maxStorageTime := s.retention(entityID)
 	metrics, err := s.opts.deps.fetchMetricsByEntity(entityID, func(metric model.Metric) bool {
 		// only processed and metrics with a creation date later than specified in the settings
-		return metric.Status == model.StatusProcessed && time.Since(metric.CreatedAt) > s.opts.maxStorageTime
+		return metric.Status == model.StatusProcessed && time.Since(metric.CreatedAt) > maxStorageTime
 	})
 	if err != nil {
 		return fmt.Errorf("unable find metrics by entity %s: %w", entityID, err)
@@ -44,6 +56,7 @@ func (s *dbScheduler) processOutdatedMetrics(entityID string) error {
 	if err := s.opts.deps.deleteMetricsFn(context.Background(), metrics); err != nil {
 		return fmt.Errorf("unable delete resizable metrics entity %s: %w", entityID, err)
 	}
+	telemetry.DBDeletedMetricsTotal.WithLabelValues(telemetry.ReasonOutdated).Add(float64(len(metrics)))
 	return nil
 }
 
@@ -51,6 +64,7 @@ func (s *dbScheduler) processOutdatedMetrics(entityID string) error {
 // processOverSizeMetrics retrieves all metrics for the specified entity, sorts by date added,
 // and deletes the oldest ones.
 func (s *dbScheduler) processOverSizeMetrics(entityID string) error {
+	maxItemsStored, _ := s.retention(entityID)
 	metrics, err := s.opts.deps.fetchMetricsByEntity(entityID, func(metric model.Metric) bool {
 		return metric.Status == model.StatusProcessed // only the processed values
 	})
@@ -63,10 +77,14 @@ func (s *dbScheduler) processOverSizeMetrics(entityID string) error {
 		return metrics[i].CreatedAt.UnixNano() < metrics[j].CreatedAt.UnixNano()
 	})
 
+	if len(metrics) <= maxItemsStored {
+		return nil
+	}
 	// Deleting a slice from the first n sorted metrics
-	if err := s.opts.deps.deleteMetricsFn(context.Background(), metrics[:len(metrics)-s.opts.maxItemsStored]); err != nil {
+	if err := s.opts.deps.deleteMetricsFn(context.Background(), metrics[:len(metrics)-maxItemsStored]); err != nil {
 		return fmt.Errorf("unable delete resizable metrics entity %s: %w", entityID, err)
 	}
+	telemetry.DBDeletedMetricsTotal.WithLabelValues(telemetry.ReasonOversize).Add(float64(len(metrics) - maxItemsStored))
 	return nil
 }
 
@@ -78,6 +96,10 @@ func (s *dbScheduler) rebuildOutdated() error {
 		return fmt.Errorf("unable to fetch metric keys: %w", err)
 	}
 	for i := range keys {
+		// the entity configured without the retention period is skipped
+		if _, maxStorageTime := s.retention(keys[i]); s.opts.retentionFn != nil && maxStorageTime <= 0 {
+			continue
+		}
 		if err := s.processOutdatedMetrics(keys[i]); err != nil {
 			return fmt.Errorf("unable process metrics: %w", err)
 		}
@@ -100,7 +122,7 @@ func (s *dbScheduler) rebuildSize() error {
 		}
 		// If the number of elements in the entity is greater than the one specified in the configuration,
 		// then run the processOverSizeMetrics
-		if length > s.opts.maxItemsStored {
+		if maxItemsStored, _ := s.retention(keys[i]); maxItemsStored > 0 && length > maxItemsStored {
 			if err := s.processOverSizeMetrics(keys[i]); err != nil {
 				return fmt.Errorf("unable process metrics: %w", err)
 			}
@@ -110,6 +132,24 @@ func (s *dbScheduler) rebuildSize() error {
 	return nil
 }
 
+// rebuildKeys deletes the dedup keys of each entity by the retention of the entity
+func (s *dbScheduler) rebuildKeys() error {
+	keys, err := s.opts.deps.fetchKeys()
+	if err != nil {
+		return fmt.Errorf("unable fetch keys: %w", err)
+	}
+	for i := range keys {
+		maxItemsStored, maxStorageTime := s.retention(keys[i])
+		if maxItemsStored <= 0 && maxStorageTime <= 0 {
+			continue
+		}
+		if _, err := s.opts.deps.trimKeys(keys[i], maxItemsStored, maxStorageTime); err != nil {
+			return fmt.Errorf("unable trim dedup keys of entity %s: %w", keys[i], err)
+		}
+	}
+	return nil
+}
+
 // Scheduler for running data cleanup functions in the DB
 func (s *dbScheduler) schedule(ctx context.Context) {
 	logger := logging.FromContext(ctx)
@@ -126,18 +166,23 @@ func (s *dbScheduler) schedule(ctx context.Context) {
 		case <-ticker.C:
 			// if the configuration specifies the maximum size of data to store
 			// then you need to check the amount of data stored in the storage.
-			if s.opts.maxItemsStored > 0 {
+			if s.opts.maxItemsStored > 0 || s.opts.retentionFn != nil {
 				if err := s.rebuildSize(); err != nil {
 					logger.Errorf("unable db rebuild size: %v", err)
 				}
 			}
 			// if the configuration specifies the maximum data storage time
 			// then you need to check the time when metrics were created in the storage.
-			if s.opts.maxStorageTime > 0 {
+			if s.opts.maxStorageTime > 0 || s.opts.retentionFn != nil {
 				if err := s.rebuildOutdated(); err != nil {
 					logger.Errorf("unable db rebuild outdated: %v", err)
 				}
 			}
+			if s.opts.maxItemsStored > 0 || s.opts.maxStorageTime > 0 || s.opts.retentionFn != nil {
+				if err := s.rebuildKeys(); err != nil {
+					logger.Errorf("unable db rebuild dedup keys: %v", err)
+				}
+			}
 		case <-ctx.Done():
 			return
 		}
@@ -9,6 +9,7 @@ import (
 	"github.com/go-sod/sod/internal/geom"
 	metricDb "github.com/go-sod/sod/internal/metric/database"
 	"github.com/go-sod/sod/internal/metric/model"
+	"github.com/google/uuid"
 )
 
 func TestRebuildSize(t *testing.T) {
@@ -396,6 +397,9 @@ func TestSchedule(t *testing.T) {
 					deleteMetricsFn: func(ctx context.Context, metrics []model.Metric) error {
 						return nil
 					},
+					trimKeys: func(string, int, time.Duration) (int, error) {
+						return 0, nil
+					},
 				},
 			}}
 			ctx, cancel := context.WithTimeout(context.Background(), scheduler.opts.rebuildDBTime*2)
@@ -405,3 +409,87 @@ func TestSchedule(t *testing.T) {
 		})
 	}
 }
+
+func TestMetricDB_Keys(t *testing.T) {
+	t.Parallel()
+	db := metricDb.New(newTestDB(t))
+	// the scheduler finds the entities of all appended metrics
+	for _, entityID := range []string{"first", "second"} {
+		if err := db.AppendMany(context.Background(), []model.Metric{
+			model.NewMetric(entityID, geom.Point{1}, time.Now(), nil),
+		}); err != nil {
+			t.Fatalf("append metrics: %v", err)
+		}
+	}
+	keys, err := db.Keys()
+	if err != nil {
+		t.Fatalf("keys: %v", err)
+	}
+	if len(keys) != 2 {
+		t.Errorf("keys, got: %v, expected: [first second]", keys)
+	}
+}
+
+func TestMetricDB_TrimKeys(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	tests := []struct {
+		name           string
+		maxItemsStored int
+		maxStorageTime time.Duration
+		// the age of the keyed metrics in hours
+		ages     []int
+		expected []bool
+	}{
+		{
+			name:           "positive_max_items",
+			maxItemsStored: 2,
+			ages:           []int{3, 1, 2, 0},
+			expected:       []bool{false, true, false, true},
+		},
+		{
+			name:           "positive_max_storage_time",
+			maxStorageTime: 90 * time.Minute,
+			ages:           []int{3, 1, 2, 0},
+			expected:       []bool{false, true, false, true},
+		},
+		{
+			name:     "negative_no_limits",
+			ages:     []int{3, 1},
+			expected: []bool{true, true},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			db := metricDb.New(newTestDB(t))
+			metrics := make([]model.Metric, len(test.ages))
+			ids := make([]uuid.UUID, len(test.ages))
+			for i, age := range test.ages {
+				metric := model.NewMetric("test-entity", geom.Point{float64(i)}, now.Add(-time.Duration(age)*time.Hour), nil)
+				metrics[i] = metric.WithKey(metric.ContentKey())
+				ids[i] = metrics[i].ID
+			}
+			// the keys are kept after the metrics are deleted
+			if err := db.AppendMany(context.Background(), metrics); err != nil {
+				t.Fatalf("append metrics: %v", err)
+			}
+			if err := db.DeleteMany(context.Background(), metrics); err != nil {
+				t.Fatalf("delete metrics: %v", err)
+			}
+			if _, err := db.TrimKeys("test-entity", test.maxItemsStored, test.maxStorageTime); err != nil {
+				t.Fatalf("trim keys: %v", err)
+			}
+			exist, err := db.ExistKeys("test-entity", ids)
+			if err != nil {
+				t.Fatalf("exist keys: %v", err)
+			}
+			for i := range ids {
+				if exist[ids[i]] != test.expected[i] {
+					t.Errorf("key %d exists, got: %v, expected: %v", i, exist[ids[i]], test.expected[i])
+				}
+			}
+		})
+	}
+}
@@ -10,10 +10,11 @@ import (
 	"github.com/go-sod/sod/internal/logging"
 	metricDb "github.com/go-sod/sod/internal/metric/database"
 	"github.com/go-sod/sod/internal/metric/model"
+	"github.com/go-sod/sod/internal/telemetry"
 )
 
-func newDBTxExecutor(db *database.DB, opts dbTxExecutorOptions, shutdownCh chan<- error) *dbTxExecutor {
-	return &dbTxExecutor{metricDB: metricDb.New(db), opts: opts, shutdownCh: shutdownCh}
+func newDBTxExecutor(db *database.DB, opts dbTxExecutorOptions) *dbTxExecutor {
+	return &dbTxExecutor{metricDB: metricDb.New(db), opts: opts}
 }
 
 // dbTxExecutorOptions Returns the structure with configuration options
@@ -21,28 +22,38 @@ type dbTxExecutorOptions struct {
 	flushSize int
 	flushTime time.Duration
 	deps      pullDependencies
+	// onFlush is called with the data after the flush
+	onFlush func(...model.Metric)
 }
 
 // A structure that represents the database transaction execution service.
 // Accumulates a queue of data and inserts it in bulk into persistent storage.
 type dbTxExecutor struct {
 	mtx sync.RWMutex
+	// Flushes are serialized, the later state of the metric is not overwritten by the earlier one
+	flushMtx sync.Mutex
 
 	opts     dbTxExecutorOptions
 	metricDB *metricDb.DB
 	//  Buffer that accumulates metric data for adding
-	buf        []model.Metric
-	shutdownCh chan<- error
+	buf []model.Metric
+	// Channels receiving the result of the flush of the buffer
+	waiters []chan<- error
 }
 
 // Urgently inserts all data from the buffer into persistent storage or returns an error
 func (tx *dbTxExecutor) shutdown() error {
+	tx.flushMtx.Lock()
+	defer tx.flushMtx.Unlock()
 	tx.mtx.Lock()
-	if err := tx.opts.deps.appendMetricsFn(context.Background(), tx.buf); err != nil {
+	defer tx.mtx.Unlock()
+	err := tx.opts.deps.appendMetricsFn(context.Background(), tx.buf)
+	tx.flushed(tx.buf)
+	notify(tx.waiters, err)
+	tx.buf, tx.waiters = tx.buf[:0], nil
+	if err != nil {
 		return fmt.Errorf("txExecutor: write many operation failed: %w", err)
 	}
-	tx.buf = tx.buf[:0]
-	tx.mtx.Unlock()
 	return nil
 }
 
@@ -57,25 +68,64 @@ func (tx *dbTxExecutor) write(ctx context.Context, data model.Metric) {
 	tx.buf = append(tx.buf, data)
 	bufLen := len(tx.buf)
 	tx.mtx.Unlock()
+	telemetry.DBTxBufferLength.Set(float64(bufLen))
 
 	if bufLen >= tx.opts.flushSize {
-		go tx.flush(ctx)
+		go tx.flush(ctx) // nolint:errcheck
 	}
 }
 
-// Bulk adds data to persistent storage and clears the buffer
-func (tx *dbTxExecutor) flush(ctx context.Context) {
+// writeSync adds data to the buffer and flushes it at once,
+// the channel receives the result of the flush containing the data
+func (tx *dbTxExecutor) writeSync(ctx context.Context, data model.Metric) <-chan error {
+	done := make(chan error, 1)
+	tx.mtx.Lock()
+	tx.buf = append(tx.buf, data)
+	tx.waiters = append(tx.waiters, done)
+	bufLen := len(tx.buf)
+	tx.mtx.Unlock()
+	telemetry.DBTxBufferLength.Set(float64(bufLen))
+
+	go tx.flush(ctx) // nolint:errcheck
+	return done
+}
+
+// Bulk adds data to persistent storage and clears the buffer, the error of the write is logged and returned
+func (tx *dbTxExecutor) flush(ctx context.Context) error {
 	logger := logging.FromContext(ctx)
+	tx.flushMtx.Lock()
+	defer tx.flushMtx.Unlock()
 
 	tx.mtx.Lock()
 	tmpBuf := make([]model.Metric, len(tx.buf))
 	copy(tmpBuf, tx.buf)
 	tx.buf = tx.buf[:0]
+	waiters := tx.waiters
+	tx.waiters = nil
 	tx.mtx.Unlock()
+	telemetry.DBTxBufferLength.Set(0)
 	// call appendMetricsFn
-	if err := tx.opts.deps.appendMetricsFn(context.Background(), tmpBuf); err != nil {
+	start := time.Now()
+	err := tx.opts.deps.appendMetricsFn(context.Background(), tmpBuf)
+	if err != nil {
 		logger.Errorf("txExecutor: flush operation failed: %v", err)
 	}
+	tx.flushed(tmpBuf)
+	notify(waiters, err)
+	telemetry.DBFlushDuration.Observe(time.Since(start).Seconds())
+	return err
+}
+
+func (tx *dbTxExecutor) flushed(data []model.Metric) {
+	if tx.opts.onFlush != nil {
+		tx.opts.onFlush(data...)
+	}
+}
+
+func notify(waiters []chan<- error, err error) {
+	for _, done := range waiters {
+		done <- err
+	}
 }
 
 func (tx *dbTxExecutor) len() int {
@@ -85,16 +135,15 @@ func (tx *dbTxExecutor) len() int {
 	return len(tx.buf)
 }
 
-// Every n seconds, data from the buffer must be inserted into the database
+// Every n seconds, data from the buffer must be inserted into the database.
+// The final flush is left to the shutdown of the manager, the buffer gets the rest of the queues after the ctx is done
 func (tx *dbTxExecutor) flusher(ctx context.Context) {
-	defer func() {
-		tx.shutdownCh <- tx.shutdown()
-	}()
 	ticker := time.NewTicker(tx.opts.flushTime)
+	defer ticker.Stop()
 	for {
 		select {
 		case <-ticker.C:
-			tx.flush(ctx)
+			_ = tx.flush(ctx)
 		case <-ctx.Done():
 			return
 		}
@@ -0,0 +1,84 @@
+package dispatcher
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-sod/sod/internal/metric/model"
+	"github.com/go-sod/sod/internal/telemetry"
+	"github.com/google/uuid"
+)
+
+// ErrDuplicate is returned for the metric with the dedup key collected already
+var ErrDuplicate = errors.New("duplicate metric")
+
+// DedupKey is the dedup key of the metrics collected without the client id
+type DedupKey string
+
+const (
+	// DedupKeyNone deduplicates only the metrics with the client id
+	DedupKeyNone DedupKey = "NONE"
+	// DedupKeyContent builds the dedup key of the metric from the entity, the creation time and the hash of the vector
+	DedupKeyContent DedupKey = "CONTENT"
+)
+
+func (k DedupKey) Validate() error {
+	switch k {
+	case DedupKeyNone, DedupKeyContent:
+		return nil
+	default:
+		return fmt.Errorf("unknown dedup key %s", k)
+	}
+}
+
+// keyed returns the metric with the dedup key, the client key is kept
+func (d *manager) keyed(metric model.Metric) model.Metric {
+	if metric.Key == "" && d.opts.dedupKey == DedupKeyContent {
+		return metric.WithKey(metric.ContentKey())
+	}
+	return metric
+}
+
+// claim marks the keyed metric as pending until it is flushed,
+// the metric is duplicate when it is pending or its key is stored already, even if the metric is deleted
+func (d *manager) claim(metric model.Metric) error {
+	if metric.Key == "" {
+		return nil
+	}
+	d.dedupMtx.Lock()
+	_, ok := d.pending[metric.ID]
+	if !ok {
+		d.pending[metric.ID] = struct{}{}
+	}
+	d.dedupMtx.Unlock()
+	if ok {
+		return d.duplicate(metric)
+	}
+
+	exist, err := d.opts.deps.existKeys(metric.EntityID, []uuid.UUID{metric.ID})
+	if err != nil {
+		d.release(metric)
+		return fmt.Errorf("unable check duplicate: %w", err)
+	}
+	if exist[metric.ID] {
+		d.release(metric)
+		return d.duplicate(metric)
+	}
+	return nil
+}
+
+func (d *manager) duplicate(metric model.Metric) error {
+	telemetry.DuplicatesTotal.WithLabelValues(metric.EntityID).Inc()
+	return fmt.Errorf("entity %s, key %s: %w", metric.EntityID, metric.Key, ErrDuplicate)
+}
+
+// release removes the flushed or discarded metrics from the pending ones
+func (d *manager) release(metrics ...model.Metric) {
+	d.dedupMtx.Lock()
+	defer d.dedupMtx.Unlock()
+	for i := range metrics {
+		if metrics[i].Key != "" {
+			delete(d.pending, metrics[i].ID)
+		}
+	}
+}
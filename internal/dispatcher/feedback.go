@@ -0,0 +1,126 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	feedbackModel "github.com/go-sod/sod/internal/feedback/model"
+	"github.com/go-sod/sod/internal/metric/model"
+	"github.com/go-sod/sod/internal/telemetry"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrMetricNotFound is returned when the labeled metric is not stored
+	ErrMetricNotFound = errors.New("metric not found")
+	// ErrMetricNotProcessed is returned when the labeled metric is not classified yet
+	ErrMetricNotProcessed = errors.New("metric is not processed yet")
+	// ErrInvalidLabel is returned for the unknown label
+	ErrInvalidLabel = errors.New("invalid label")
+	// ErrFeedbackDisabled is returned when the processed metrics are not stored, so they can not be labeled
+	ErrFeedbackDisabled = errors.New("feedback is disabled, the append of the data is not allowed")
+)
+
+// Label stores the label of the metric and updates the dataset of the entity.
+// The excluded metric labeled normal joins the predictor and is stored as included,
+// the metric labeled anomaly is stored as excluded and the predictor is rebuilt without it,
+// so the label can be corrected later.
+// The labeling is serialized with the processing of the entity and the buffered metrics are flushed before the lookup,
+// so the label gets the latest state of the metric
+func (d *manager) Label(
+	ctx context.Context,
+	entityID string,
+	metricID uuid.UUID,
+	label feedbackModel.Label,
+) (feedbackModel.Feedback, error) {
+	if err := label.Validate(); err != nil {
+		return feedbackModel.Feedback{}, fmt.Errorf("%w: %v", ErrInvalidLabel, err)
+	}
+	if !d.opts.allowAppendData {
+		return feedbackModel.Feedback{}, fmt.Errorf("entity %s, metric %s: %w", entityID, metricID, ErrFeedbackDisabled)
+	}
+	b := d.reorderBuffer(entityID)
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if err := d.dbTxExecutor.flush(ctx); err != nil {
+		return feedbackModel.Feedback{}, fmt.Errorf("unable flush metrics: %w", err)
+	}
+	metric, ok, err := d.metricDB.FindByID(entityID, metricID)
+	if err != nil {
+		return feedbackModel.Feedback{}, fmt.Errorf("unable find metric: %w", err)
+	}
+	if !ok {
+		return feedbackModel.Feedback{}, fmt.Errorf("entity %s, metric %s: %w", entityID, metricID, ErrMetricNotFound)
+	}
+	if !metric.IsProcessed() {
+		return feedbackModel.Feedback{}, fmt.Errorf("entity %s, metric %s: %w", entityID, metricID, ErrMetricNotProcessed)
+	}
+
+	switch label {
+	case feedbackModel.LabelNormal:
+		if err := d.labelNormal(ctx, metric); err != nil {
+			return feedbackModel.Feedback{}, err
+		}
+	case feedbackModel.LabelAnomaly:
+		if err := d.labelAnomaly(ctx, metric); err != nil {
+			return feedbackModel.Feedback{}, err
+		}
+	}
+
+	f := feedbackModel.Feedback{
+		EntityID:  entityID,
+		MetricID:  metricID,
+		Label:     label,
+		Outlier:   metric.Outlier,
+		Score:     metric.Score,
+		CreatedAt: metric.CreatedAt,
+		LabeledAt: time.Now(),
+	}
+	if err := d.feedbackDB.Store(ctx, f); err != nil {
+		return feedbackModel.Feedback{}, fmt.Errorf("unable store label: %w", err)
+	}
+	telemetry.FeedbackTotal.WithLabelValues(entityID, string(label)).Inc()
+	return f, nil
+}
+
+func (d *manager) labelNormal(ctx context.Context, metric model.Metric) error {
+	if metric.InDataset() {
+		return nil
+	}
+	entityPredictor, err := d.entityPredictor(metric.EntityID)
+	if err != nil {
+		return err
+	}
+	metric.Excluded = false
+	entityPredictor.Append(&metric)
+	if err := d.metricDB.Store(ctx, metric); err != nil {
+		return fmt.Errorf("unable store metric: %w", err)
+	}
+	return nil
+}
+
+func (d *manager) labelAnomaly(ctx context.Context, metric model.Metric) error {
+	if !metric.InDataset() {
+		return nil
+	}
+	metric.Excluded = true
+	if err := d.metricDB.Store(ctx, metric); err != nil {
+		return fmt.Errorf("unable store metric: %w", err)
+	}
+	newPredictor, err := d.predictorProvideFn(d.settings(metric.EntityID))
+	if err != nil {
+		return fmt.Errorf("can not create predictor instance: %w", err)
+	}
+	return d.rebuild(ctx, metric.EntityID, newPredictor)
+}
+
+// Precision returns the precision of the entity by the labels of the metrics created within [from, to)
+func (d *manager) Precision(entityID string, from, to time.Time) (feedbackModel.Precision, error) {
+	labels, err := d.feedbackDB.FindByEntity(entityID, from, to)
+	if err != nil {
+		return feedbackModel.Precision{}, fmt.Errorf("unable find labels: %w", err)
+	}
+	return feedbackModel.NewPrecision(entityID, labels), nil
+}
@@ -0,0 +1,264 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-sod/sod/internal/alert"
+	feedbackModel "github.com/go-sod/sod/internal/feedback/model"
+	"github.com/go-sod/sod/internal/geom"
+	"github.com/go-sod/sod/internal/metric/model"
+	"github.com/go-sod/sod/internal/predictor"
+	"github.com/go-sod/sod/internal/predictor/mocks"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestManager_Label(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		outlier  bool
+		excluded bool
+		status   model.Status
+		// the processed state of the metric waits in the buffer of the executor
+		buffered bool
+		// the processed metrics are not stored
+		appendDisabled   bool
+		label            feedbackModel.Label
+		expectedErr      error
+		expectedAppended int
+		expectedRebuilt  int
+		expectedExcluded bool
+	}{
+		{
+			name:             "positive_false_positive_joins_dataset",
+			outlier:          true,
+			excluded:         true,
+			status:           model.StatusProcessed,
+			label:            feedbackModel.LabelNormal,
+			expectedAppended: 1,
+		},
+		{
+			name:    "positive_false_positive_in_dataset",
+			outlier: true,
+			status:  model.StatusProcessed,
+			label:   feedbackModel.LabelNormal,
+		},
+		{
+			name:             "positive_missed_anomaly_excluded",
+			status:           model.StatusProcessed,
+			label:            feedbackModel.LabelAnomaly,
+			expectedRebuilt:  1,
+			expectedExcluded: true,
+		},
+		{
+			name:             "positive_true_positive_not_in_dataset",
+			outlier:          true,
+			excluded:         true,
+			status:           model.StatusProcessed,
+			label:            feedbackModel.LabelAnomaly,
+			expectedExcluded: true,
+		},
+		{
+			name:             "positive_buffered_processed",
+			outlier:          true,
+			excluded:         true,
+			status:           model.StatusProcessed,
+			buffered:         true,
+			label:            feedbackModel.LabelNormal,
+			expectedAppended: 1,
+		},
+		{
+			name:           "negative_append_disabled",
+			status:         model.StatusProcessed,
+			appendDisabled: true,
+			label:          feedbackModel.LabelNormal,
+			expectedErr:    ErrFeedbackDisabled,
+		},
+		{
+			name:        "negative_not_processed",
+			status:      model.StatusNew,
+			label:       feedbackModel.LabelNormal,
+			expectedErr: ErrMetricNotProcessed,
+		},
+		{
+			name:        "negative_unknown_label",
+			status:      model.StatusProcessed,
+			label:       "maybe",
+			expectedErr: ErrInvalidLabel,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			db := newTestDB(t)
+			shutdownCh := make(chan error, 1)
+			notifier, _ := alert.New(db, shutdownCh)
+
+			pred := &mocks.Predictor{}
+			pred.On("Build", mock.Anything).Return()
+			pred.On("Append", mock.Anything).Return()
+			m, err := New(db, func(predictor.Settings) (predictor.Predictor, error) {
+				return pred, nil
+			}, notifier, shutdownCh, WithAllowAppendData(!test.appendDisabled))
+			if err != nil {
+				t.Fatalf("new manager: %v", err)
+			}
+
+			metric := model.NewMetric("test-entity", geom.Point{1, 1}, time.Now(), nil)
+			metric.Outlier, metric.Excluded, metric.Status = test.outlier, test.excluded, test.status
+			stored := metric
+			if test.buffered {
+				stored.Status = model.StatusNew
+				m.dbTxExecutor.write(context.Background(), metric)
+			}
+			if err := m.metricDB.Store(context.Background(), stored); err != nil {
+				t.Fatalf("store metric: %v", err)
+			}
+
+			f, err := m.Label(context.Background(), "test-entity", metric.ID, test.label)
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("compute Label, got: %v, expected: %v", err, test.expectedErr)
+			}
+			if err == nil && (f.MetricID != metric.ID || f.Outlier != test.outlier || f.Label != test.label) {
+				t.Errorf("feedback, got: %+v", f)
+			}
+			if appended := len(callsOf(pred, "Append")); appended != test.expectedAppended {
+				t.Errorf("appended to predictor, got: %d, expected: %d", appended, test.expectedAppended)
+			}
+			if rebuilt := len(callsOf(pred, "Build")); rebuilt != test.expectedRebuilt {
+				t.Errorf("rebuilt predictor, got: %d, expected: %d", rebuilt, test.expectedRebuilt)
+			}
+			stored, ok, _ := m.metricDB.FindByID("test-entity", metric.ID)
+			if !ok || stored.Excluded != test.expectedExcluded {
+				t.Errorf("metric stored: %v, excluded, got: %v, expected: %v", ok, stored.Excluded, test.expectedExcluded)
+			}
+		})
+	}
+
+	db := newTestDB(t)
+	notifier, _ := alert.New(db, make(chan error, 1))
+	m, _ := New(db, func(predictor.Settings) (predictor.Predictor, error) {
+		return &mocks.Predictor{}, nil
+	}, notifier, make(chan error, 1), WithAllowAppendData(true))
+	if _, err := m.Label(context.Background(), "test-entity", uuid.New(), feedbackModel.LabelNormal); !errors.Is(err, ErrMetricNotFound) {
+		t.Errorf("label of unknown metric, got: %v, expected: %v", err, ErrMetricNotFound)
+	}
+}
+
+func TestManager_Precision(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	notifier, _ := alert.New(db, make(chan error, 1))
+	pred := &mocks.Predictor{}
+	pred.On("Build", mock.Anything).Return()
+	pred.On("Append", mock.Anything).Return()
+	m, _ := New(db, func(predictor.Settings) (predictor.Predictor, error) {
+		return pred, nil
+	}, notifier, make(chan error, 1), WithAllowAppendData(true))
+
+	start := time.Now().Add(-time.Hour)
+	labels := []struct {
+		outlier bool
+		label   feedbackModel.Label
+	}{
+		{outlier: true, label: feedbackModel.LabelAnomaly},
+		{outlier: true, label: feedbackModel.LabelAnomaly},
+		{outlier: true, label: feedbackModel.LabelNormal},
+		{outlier: false, label: feedbackModel.LabelAnomaly},
+	}
+	for i, l := range labels {
+		metric := model.NewMetric("test-entity", geom.Point{1}, start.Add(time.Duration(i)*time.Minute), nil)
+		metric.Outlier, metric.Status = l.outlier, model.StatusProcessed
+		if err := m.metricDB.Store(context.Background(), metric); err != nil {
+			t.Fatalf("store metric: %v", err)
+		}
+		if _, err := m.Label(context.Background(), "test-entity", metric.ID, l.label); err != nil {
+			t.Fatalf("label %d: %v", i, err)
+		}
+	}
+
+	p, err := m.Precision("test-entity", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("compute Precision: %v", err)
+	}
+	if p.Labeled != 4 || p.TruePositives != 2 || p.FalsePositives != 1 || p.FalseNegatives != 1 {
+		t.Errorf("precision, got: %+v", p)
+	}
+	if p.Precision == nil || *p.Precision != 2.0/3 || p.Recall == nil || *p.Recall != 2.0/3 {
+		t.Errorf("precision and recall, got: %v, %v", p.Precision, p.Recall)
+	}
+
+	// the window holds only the first metric
+	p, err = m.Precision("test-entity", start, start.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("compute Precision: %v", err)
+	}
+	if p.Labeled != 1 || p.TruePositives != 1 {
+		t.Errorf("precision of window, got: %+v", p)
+	}
+}
+
+func TestManager_LabelRebuild(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	notifier, _ := alert.New(db, make(chan error, 1))
+	var provided []*mocks.Predictor
+	m, _ := New(db, func(predictor.Settings) (predictor.Predictor, error) {
+		pred := &mocks.Predictor{}
+		pred.On("Build", mock.Anything).Return()
+		pred.On("Append", mock.Anything).Return()
+		provided = append(provided, pred)
+		return pred, nil
+	}, notifier, make(chan error, 1), WithAllowAppendData(true))
+
+	// the normal metrics are in the dataset, the outlier is excluded
+	start := time.Now().Add(-time.Hour)
+	metrics := make([]model.Metric, 3)
+	for i := range metrics {
+		metrics[i] = model.NewMetric("test-entity", geom.Point{float64(i)}, start.Add(time.Duration(i)*time.Minute), nil)
+		metrics[i].Status = model.StatusProcessed
+	}
+	metrics[1].Outlier, metrics[1].Excluded = true, true
+	for i := range metrics {
+		if err := m.metricDB.Store(context.Background(), metrics[i]); err != nil {
+			t.Fatalf("store metric: %v", err)
+		}
+	}
+
+	if _, err := m.Label(context.Background(), "test-entity", metrics[0].ID, feedbackModel.LabelAnomaly); err != nil {
+		t.Fatalf("compute Label: %v", err)
+	}
+	if len(provided) != 1 {
+		t.Fatalf("provided predictors, got: %d, expected: 1", len(provided))
+	}
+	builds := callsOf(provided[0], "Build")
+	if len(builds) != 1 || len(builds[0].Arguments) != 1 || builds[0].Arguments[0].(model.Metric).ID != metrics[2].ID {
+		t.Errorf("rebuilt predictor, got builds: %v, expected the normal metric only", builds)
+	}
+
+	// the label of the metric is corrected, the metric joins the dataset again
+	if _, err := m.Label(context.Background(), "test-entity", metrics[0].ID, feedbackModel.LabelNormal); err != nil {
+		t.Fatalf("compute Label: %v", err)
+	}
+	if appended := len(callsOf(provided[0], "Append")); appended != 1 {
+		t.Errorf("appended to predictor, got: %d, expected: 1", appended)
+	}
+	if stored, _, _ := m.metricDB.FindByID("test-entity", metrics[0].ID); stored.Excluded {
+		t.Errorf("relabeled metric is excluded")
+	}
+}
+
+func callsOf(pred *mocks.Predictor, method string) []mock.Call {
+	var calls []mock.Call
+	for _, c := range pred.Calls {
+		if c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
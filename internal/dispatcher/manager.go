@@ -2,18 +2,45 @@ package dispatcher
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"runtime"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/go-sod/sod/internal/alert"
+	"github.com/go-sod/sod/internal/alert/rule"
 	"github.com/go-sod/sod/internal/database"
+	entityDb "github.com/go-sod/sod/internal/entity/database"
+	entityModel "github.com/go-sod/sod/internal/entity/model"
+	feedbackDb "github.com/go-sod/sod/internal/feedback/database"
+	feedbackModel "github.com/go-sod/sod/internal/feedback/model"
 	"github.com/go-sod/sod/internal/logging"
 	metricDb "github.com/go-sod/sod/internal/metric/database"
 	"github.com/go-sod/sod/internal/metric/model"
 	"github.com/go-sod/sod/internal/predictor"
+	"github.com/go-sod/sod/internal/stream"
+	"github.com/go-sod/sod/internal/telemetry"
 	"github.com/go-sod/sod/pkg/iqueue"
+	"github.com/google/uuid"
+)
+
+// ErrPredictorNotFound is returned when no predictor has been created for the entity yet
+var ErrPredictorNotFound = errors.New("predictor not found")
+
+var (
+	// ErrQueueFull is returned by Collect when the queue of the entity is full
+	ErrQueueFull = iqueue.ErrFull
+	// ErrDropped is the error of the verdict of the metric dropped by the overflow policy
+	ErrDropped = errors.New("metric dropped by the full queue")
+)
+
+var (
+	// ErrEntityConfigNotFound is returned when the entity has no own configuration
+	ErrEntityConfigNotFound = errors.New("entity config not found")
+	// ErrInvalidEntityConfig is returned when the predictor can not be created with the entity configuration
+	ErrInvalidEntityConfig = errors.New("invalid entity config")
 )
 
 // Contract for returning the Manager instance
@@ -23,10 +50,18 @@ type ProvideFn func(alert.Manager, chan<- error) (Manager, error)
 // This interface defines the behavior of the background service.
 type Manager interface {
 	CollectPredictor
+	SyncCollector
+	Configurator
+	Labeler
+	stream.Subscriber
 	// Start method of the service
 	Run(context.Context) error
 	// Method for stopping the service
 	Stop()
+	// The method returns the number of data points in the predictor of each entity
+	DatasetSizes() map[string]int
+	// The method returns the number of the queued metrics of each entity
+	QueueDepths() map[string]int
 }
 
 // Collector defines the behavior of the service for data storage and analysis
@@ -35,10 +70,48 @@ type Collector interface {
 	Collect(in ...model.Metric) error
 }
 
+// SyncCollector defines the behavior of the service reporting the verdicts of the collected data
+type SyncCollector interface {
+	Collector
+	// The method writes the data to the queue and waits until every point is classified and persisted
+	CollectSync(ctx context.Context, in ...model.Metric) []Verdict
+}
+
+// Verdict is the result of the synchronous collection of the metric
+type Verdict struct {
+	// The metric with the outlier flag and the score
+	Metric model.Metric
+	// Classified is false for the metrics appended to the dataset before the predictor has enough data
+	Classified bool
+	Err        error
+}
+
 // The interface defines the behavior of the service only for predictions
 type Predictor interface {
 	// The method determines whether the data is an outlier
 	Predict(entityID string, in predictor.DataPoint) (*predictor.Conclusion, error)
+	// The method returns the decision threshold used by the entity predictor
+	Threshold(entityID string) (float64, error)
+}
+
+// Configurator defines the behavior of the service managing the detector configuration of the entities
+type Configurator interface {
+	// The method returns the configurations of all configured entities
+	EntityConfigs() []entityModel.Config
+	// The method returns the configuration of the entity
+	EntityConfig(entityID string) (entityModel.Config, error)
+	// The method stores the configuration and rebuilds the entity predictor
+	SetEntityConfig(ctx context.Context, cfg entityModel.Config) error
+	// The method deletes the configuration and rebuilds the entity predictor with the global one
+	DeleteEntityConfig(ctx context.Context, entityID string) error
+}
+
+// Labeler defines the behavior of the service taking the feedback on the classified metrics
+type Labeler interface {
+	// The method stores the label of the metric and updates the dataset of the entity by it
+	Label(ctx context.Context, entityID string, metricID uuid.UUID, label feedbackModel.Label) (feedbackModel.Feedback, error)
+	// The method returns the precision of the entity by the labels of the metrics created within [from, to)
+	Precision(entityID string, from, to time.Time) (feedbackModel.Precision, error)
 }
 
 // Aggregation interface for Collector and Predictor interfaces
@@ -63,6 +136,14 @@ type (
 	fetchKeysFn func() ([]string, error)
 	// number of metrics by entity id
 	countByEntityFn func(string) (int, error)
+	// function for getting the ids of the keyed metrics of the entity stored already
+	existKeysFn func(string, []uuid.UUID) (map[uuid.UUID]bool, error)
+	// function to add the dedup keys of the metrics which are not stored
+	appendKeysFn func(context.Context, []model.Metric) error
+	// function for deleting the dedup keys of the entity by its retention
+	trimKeysFn func(string, int, time.Duration) (int, error)
+	// function to add the metrics older than the watermark to the side bucket
+	appendLateMetricsFn func(context.Context, []model.Metric) error
 )
 
 //  General structure for aggregation of dependency pulling functions
@@ -74,6 +155,10 @@ type pullDependencies struct {
 	appendMetricsFn      appendMetricsFn
 	fetchKeys            fetchKeysFn
 	countByEntity        countByEntityFn
+	existKeys            existKeysFn
+	appendKeys           appendKeysFn
+	trimKeys             trimKeysFn
+	appendLateMetricsFn  appendLateMetricsFn
 }
 
 type Options struct {
@@ -85,6 +170,14 @@ type Options struct {
 	dbFlushTime        time.Duration
 	dbFlushSize        int
 	rebuildDBTime      time.Duration
+	alertRules         rule.Rules
+	queueCapacity      int
+	queueOverflow      iqueue.Overflow
+	queueBlockTimeout  time.Duration
+	workers            int
+	dedupKey           DedupKey
+	allowedLateness    time.Duration
+	latePolicy         LatePolicy
 	deps               pullDependencies
 }
 
@@ -138,6 +231,62 @@ func WithAllowAppendOutlier(t bool) Option {
 	}
 }
 
+func WithAlertRules(rules rule.Rules) Option {
+	return func(o *manager) {
+		o.opts.alertRules = rules
+	}
+}
+
+// WithQueueCapacity bounds the queue of each entity, the queues are unbounded with 0
+func WithQueueCapacity(n int) Option {
+	return func(o *manager) {
+		o.opts.queueCapacity = n
+	}
+}
+
+// WithQueueOverflow sets the behavior of Collect when the queue of the entity is full
+func WithQueueOverflow(overflow iqueue.Overflow) Option {
+	return func(o *manager) {
+		o.opts.queueOverflow = overflow
+	}
+}
+
+// WithQueueBlockTimeout limits the waiting of Collect with the BLOCK overflow
+func WithQueueBlockTimeout(t time.Duration) Option {
+	return func(o *manager) {
+		o.opts.queueBlockTimeout = t
+	}
+}
+
+// WithWorkers sets the number of the workers processing the queues, NumCPU*2 with 0
+func WithWorkers(n int) Option {
+	return func(o *manager) {
+		o.opts.workers = n
+	}
+}
+
+// WithDedupKey sets the dedup key of the metrics collected without the client id
+func WithDedupKey(key DedupKey) Option {
+	return func(o *manager) {
+		o.opts.dedupKey = key
+	}
+}
+
+// WithAllowedLateness sets the allowed lateness of the metrics, the metrics are reordered within it,
+// the reordering is disabled with 0
+func WithAllowedLateness(t time.Duration) Option {
+	return func(o *manager) {
+		o.opts.allowedLateness = t
+	}
+}
+
+// WithLatePolicy sets the behavior with the metrics older than the watermark of the entity
+func WithLatePolicy(policy LatePolicy) Option {
+	return func(o *manager) {
+		o.opts.latePolicy = policy
+	}
+}
+
 // New return manager
 func New(
 	db *database.DB,
@@ -156,19 +305,53 @@ func New(
 
 	d := &manager{
 		metricDB:           metricDb.New(db),
-		collectCh:          make(chan model.Metric, 1),
+		entityDB:           entityDb.New(db),
+		feedbackDB:         feedbackDb.New(db),
+		configs:            map[string]entityModel.Config{},
 		shutDownCh:         shutdownCh,
 		predictorProvideFn: providePredictorFn,
 		predictors:         map[string]predictor.Predictor{},
 		queue:              map[string]*iqueue.Queue{},
 		normVectors:        map[string][]float64{},
 		notifier:           notifier,
+		stream:             stream.NewBroker(),
+		waiters:            map[uuid.UUID]chan Verdict{},
+		pending:            map[uuid.UUID]struct{}{},
+		buffers:            map[string]*reorderBuffer{},
 	}
 
 	for _, f := range opts {
 		f(d)
 	}
 
+	if d.opts.queueOverflow == "" {
+		d.opts.queueOverflow = iqueue.OverflowBlock
+	}
+	if err := d.opts.queueOverflow.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid queue config: %w", err)
+	}
+
+	if d.opts.dedupKey == "" {
+		d.opts.dedupKey = DedupKeyNone
+	}
+	if err := d.opts.dedupKey.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid dedup config: %w", err)
+	}
+
+	if d.opts.latePolicy == "" {
+		d.opts.latePolicy = LatePolicyDrop
+	}
+	if err := d.opts.latePolicy.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid late config: %w", err)
+	}
+
+	if d.opts.workers <= 0 {
+		d.opts.workers = runtime.NumCPU() * workerMul
+	}
+	d.pool = newWorkerPool(d.opts.workers)
+
+	d.alertRules = rule.NewEvaluator(d.opts.alertRules)
+
 	// structure containing functions for getting and adding metrics
 	d.opts.deps = pullDependencies{
 		fetchMetrics:         d.metricDB.FindAll,
@@ -178,6 +361,10 @@ func New(
 		appendMetricsFn:      d.metricDB.AppendMany,
 		fetchKeys:            d.metricDB.Keys,
 		countByEntity:        d.metricDB.CountByEntity,
+		existKeys:            d.metricDB.ExistKeys,
+		appendKeys:           d.metricDB.AppendKeys,
+		trimKeys:             d.metricDB.TrimKeys,
+		appendLateMetricsFn:  d.metricDB.AppendLate,
 	}
 
 	// Creating a new instance of newDBScheduler.
@@ -186,6 +373,7 @@ func New(
 		maxItemsStored: d.opts.maxItemsStored,
 		maxStorageTime: d.opts.maxStorageTime,
 		rebuildDBTime:  d.opts.rebuildDBTime,
+		retentionFn:    d.retention,
 	})
 
 	// Creates a new instance of dbTxExecutor
@@ -195,8 +383,8 @@ func New(
 			deps:      d.opts.deps,
 			flushTime: d.opts.dbFlushTime,
 			flushSize: d.opts.dbFlushSize,
+			onFlush:   d.release,
 		},
-		shutdownCh,
 	)
 
 	return d, nil
@@ -211,17 +399,33 @@ type manager struct {
 	opts Options
 	//  Main metric storage
 	metricDB *metricDb.DB
+	// Entity configuration storage
+	entityDB *entityDb.DB
+	// Storage of the labels of the metrics
+	feedbackDB *feedbackDb.DB
 	//  The notification manager
 	notifier alert.Manager
+	// Rules gating the outliers before the notification
+	alertRules *rule.Evaluator
+	// Stream of the classified points
+	stream *stream.Broker
+	// Channels of CollectSync waiting for the verdicts of the metrics
+	waitMtx sync.Mutex
+	waiters map[uuid.UUID]chan Verdict
+	// Ids of the keyed metrics accepted but not flushed yet
+	dedupMtx sync.Mutex
+	pending  map[uuid.UUID]struct{}
 	// The transaction manager in the store
 	dbTxExecutor *dbTxExecutor
 	// Managing data in storage
 	dbScheduler *dbScheduler
 
-	// Queue for new data to be processed
+	// Bounded queues of new data to be processed
 	queue map[string]*iqueue.Queue
-	// New data channel for processing
-	collectCh chan model.Metric
+	// The workers processing the queues sharded by the entity
+	pool *workerPool
+	// Buffers reordering the metrics of the entities within the allowed lateness
+	buffers map[string]*reorderBuffer
 	// Channel to shutdown the application
 	shutDownCh chan<- error
 
@@ -230,6 +434,9 @@ type manager struct {
 	predictorProvideFn predictor.ProvideFn
 	// Created predictors
 	predictors map[string]predictor.Predictor
+	// Entity configurations overriding the global predictor settings
+	configMtx sync.RWMutex
+	configs   map[string]entityModel.Config
 	// The last vector is not outlier
 	normVectors map[string][]float64
 
@@ -245,10 +452,26 @@ func (d *manager) Run(ctx context.Context) error {
 	c, cancel := context.WithCancel(context.Background())
 	d.cancelNotifier = cancel
 
-	go d.collector(ctx)
+	// the queues collected before Run are already scheduled to the workers
+	workersDone := d.pool.run(ctx, func(ctx context.Context, v interface{}) {
+		d.admit(ctx, v.(model.Metric))
+	})
+	go d.releaser(ctx)
+	go func() {
+		<-ctx.Done()
+		d.mtx.Lock()
+		d.closed = true
+		d.mtx.Unlock()
+		<-workersDone
+		d.shutDownCh <- d.shutdown(ctx)
+	}()
 	go d.dbTxExecutor.flusher(ctx)
 	go d.dbScheduler.schedule(ctx)
 
+	// Loading entity configurations before the predictors are created
+	if err := d.loadConfigs(ctx); err != nil {
+		return fmt.Errorf("can not start dispatcher manager: %w", err)
+	}
 	// Loading data from storage to memory
 	if err := d.bulkLoad(ctx); err != nil {
 		return fmt.Errorf("can not start dispatcher manager: %w", err)
@@ -266,6 +489,11 @@ func (d *manager) Stop() {
 	d.cancel()
 }
 
+// Subscribe returns the subscription to the classified points
+func (d *manager) Subscribe(filter stream.Filter, size int) *stream.Subscription {
+	return d.stream.Subscribe(filter, size)
+}
+
 // Predict returns a structure with the result of checking the transmitted data for deviations
 func (d *manager) Predict(entityID string, data predictor.DataPoint) (*predictor.Conclusion, error) {
 	d.mtx.Lock()
@@ -276,7 +504,7 @@ func (d *manager) Predict(entityID string, data predictor.DataPoint) (*predictor
 	//  If the predictor instance does not exist we return a new one from the factory
 	predictorFn, ok := d.predictors[entityID]
 	if !ok {
-		newPredictor, err := d.predictorProvideFn()
+		newPredictor, err := d.predictorProvideFn(d.settings(entityID))
 		if err != nil {
 			d.mtx.Unlock()
 			return nil, fmt.Errorf("can not create predictor instance: %w", err)
@@ -294,20 +522,365 @@ func (d *manager) Predict(entityID string, data predictor.DataPoint) (*predictor
 	return result, nil
 }
 
-// Collect adds data to the feed for saving to the queue
-func (d *manager) Collect(data ...model.Metric) error {
+// DatasetSizes returns the number of data points in the predictor of each entity
+func (d *manager) DatasetSizes() map[string]int {
 	d.mtx.RLock()
-	if d.closed {
-		d.mtx.RUnlock()
-		return fmt.Errorf("error send to collect, shutting down")
+	defer d.mtx.RUnlock()
+	sizes := make(map[string]int, len(d.predictors))
+	for entityID, entityPredictor := range d.predictors {
+		sizes[entityID] = entityPredictor.Len()
+	}
+	return sizes
+}
+
+// Threshold returns the decision threshold of the entity predictor
+func (d *manager) Threshold(entityID string) (float64, error) {
+	d.mtx.RLock()
+	entityPredictor, ok := d.predictors[entityID]
+	d.mtx.RUnlock()
+	if !ok {
+		return 0, fmt.Errorf("entity %s: %w", entityID, ErrPredictorNotFound)
+	}
+	thresholder, ok := entityPredictor.(predictor.Thresholder)
+	if !ok {
+		return 0, fmt.Errorf("predictor for entity %s does not provide threshold", entityID)
+	}
+	return thresholder.Threshold(), nil
+}
+
+// EntityConfigs returns the configurations of all configured entities ordered by entity id
+func (d *manager) EntityConfigs() []entityModel.Config {
+	d.configMtx.RLock()
+	configs := make([]entityModel.Config, 0, len(d.configs))
+	for _, cfg := range d.configs {
+		configs = append(configs, cfg)
+	}
+	d.configMtx.RUnlock()
+	sort.Slice(configs, func(i, j int) bool {
+		return configs[i].EntityID < configs[j].EntityID
+	})
+	return configs
+}
+
+// EntityConfig returns the configuration of the entity
+func (d *manager) EntityConfig(entityID string) (entityModel.Config, error) {
+	d.configMtx.RLock()
+	defer d.configMtx.RUnlock()
+	cfg, ok := d.configs[entityID]
+	if !ok {
+		return entityModel.Config{}, fmt.Errorf("entity %s: %w", entityID, ErrEntityConfigNotFound)
+	}
+	return cfg, nil
+}
+
+// SetEntityConfig validates and stores the configuration, the entity predictor is rebuilt with the stored data.
+// The changes of the configuration of the entity are serialized with its processing,
+// so the stored configuration and the predictor are not interleaved with the concurrent change
+func (d *manager) SetEntityConfig(ctx context.Context, cfg entityModel.Config) error {
+	if cfg.EntityID == "" {
+		return fmt.Errorf("entity is not defined: %w", ErrInvalidEntityConfig)
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidEntityConfig, err)
+	}
+	newPredictor, err := d.predictorProvideFn(cfg.Settings)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidEntityConfig, err)
+	}
+	b := d.reorderBuffer(cfg.EntityID)
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	cfg.UpdatedAt = time.Now()
+	if err := d.entityDB.Store(ctx, cfg); err != nil {
+		closePredictor(newPredictor)
+		return fmt.Errorf("unable store entity config: %w", err)
+	}
+	d.configMtx.Lock()
+	d.configs[cfg.EntityID] = cfg
+	d.configMtx.Unlock()
+	return d.rebuild(ctx, cfg.EntityID, newPredictor)
+}
+
+// DeleteEntityConfig deletes the configuration, the entity predictor is rebuilt with the global configuration
+func (d *manager) DeleteEntityConfig(ctx context.Context, entityID string) error {
+	b := d.reorderBuffer(entityID)
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if _, err := d.EntityConfig(entityID); err != nil {
+		return err
+	}
+	newPredictor, err := d.predictorProvideFn(predictor.Settings{})
+	if err != nil {
+		return fmt.Errorf("can not create predictor instance: %w", err)
+	}
+	if err := d.entityDB.Delete(ctx, entityID); err != nil {
+		closePredictor(newPredictor)
+		return fmt.Errorf("unable delete entity config: %w", err)
+	}
+	d.configMtx.Lock()
+	delete(d.configs, entityID)
+	d.configMtx.Unlock()
+	return d.rebuild(ctx, entityID, newPredictor)
+}
+
+// rebuildPredictor loads the processed metrics of the entity to the new predictor and replaces the current one.
+// The processing of the entity is paused until the swap and the buffered metrics are flushed before the fetch,
+// so the new predictor gets every metric appended to the current one
+func (d *manager) rebuildPredictor(ctx context.Context, entityID string, newPredictor predictor.Predictor) error {
+	b := d.reorderBuffer(entityID)
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return d.rebuild(ctx, entityID, newPredictor)
+}
+
+// rebuild is rebuildPredictor of the caller holding the lock of the reorder buffer of the entity
+func (d *manager) rebuild(ctx context.Context, entityID string, newPredictor predictor.Predictor) error {
+	if err := d.dbTxExecutor.flush(ctx); err != nil {
+		closePredictor(newPredictor)
+		return fmt.Errorf("unable flush metrics: %w", err)
+	}
+
+	metrics, err := d.opts.deps.fetchMetricsByEntity(entityID, func(metric model.Metric) bool {
+		return metric.InDataset()
+	})
+	if err != nil {
+		closePredictor(newPredictor)
+		return fmt.Errorf("unable find metrics by entity %s: %w", entityID, err)
+	}
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].CreatedAt.Before(metrics[j].CreatedAt)
+	})
+	list := make([]predictor.DataPoint, len(metrics))
+	for i := range metrics {
+		list[i] = metrics[i]
 	}
+	newPredictor.Build(list...)
+
+	d.mtx.Lock()
+	current, ok := d.predictors[entityID]
+	d.predictors[entityID] = newPredictor
+	d.mtx.Unlock()
+	if ok {
+		closePredictor(current)
+	}
+	return nil
+}
+
+// loadConfigs loading entity configurations from storage to memory
+func (d *manager) loadConfigs(ctx context.Context) error {
+	configs, err := d.entityDB.FindAll(ctx)
+	if err != nil {
+		return fmt.Errorf("error fetching entity configs: %w", err)
+	}
+	d.configMtx.Lock()
+	for _, cfg := range configs {
+		d.configs[cfg.EntityID] = cfg
+	}
+	d.configMtx.Unlock()
+	return nil
+}
+
+// settings returns the predictor settings of the entity, the zero settings keep the global configuration
+func (d *manager) settings(entityID string) predictor.Settings {
+	d.configMtx.RLock()
+	defer d.configMtx.RUnlock()
+	return d.configs[entityID].Settings
+}
+
+func (d *manager) skipItems(entityID string) int {
+	if s := d.settings(entityID); s.SkipItems != nil {
+		return *s.SkipItems
+	}
+	return d.opts.skipItems
+}
+
+// retention returns the storage limits of the entity
+func (d *manager) retention(entityID string) (int, time.Duration) {
+	maxItemsStored, maxStorageTime := d.opts.maxItemsStored, d.opts.maxStorageTime
+	s := d.settings(entityID)
+	if s.MaxItemsStored != nil {
+		maxItemsStored = *s.MaxItemsStored
+	}
+	if s.MaxStorageTime != nil {
+		maxStorageTime = s.MaxStorageTime.Duration
+	}
+	return maxItemsStored, maxStorageTime
+}
+
+func closePredictor(p predictor.Predictor) {
+	if c, ok := p.(predictor.Closer); ok {
+		c.Close()
+	}
+}
+
+// Collect adds data to the queues of the entities, the full queue applies the overflow policy.
+// The duplicates are skipped, the error wrapping ErrDuplicate is returned after the rest of data is collected
+func (d *manager) Collect(data ...model.Metric) error {
+	duplicates := 0
 	for i := range data {
-		d.collectCh <- data[i]
+		if err := d.collect(d.keyed(data[i])); err != nil {
+			if errors.Is(err, ErrDuplicate) {
+				duplicates++
+				continue
+			}
+			return err
+		}
+	}
+	if duplicates > 0 {
+		return fmt.Errorf("%d metrics skipped: %w", duplicates, ErrDuplicate)
+	}
+	return nil
+}
+
+// collect adds the metric to the queue unless it is duplicate
+func (d *manager) collect(metric model.Metric) error {
+	if err := d.claim(metric); err != nil {
+		return err
+	}
+	if err := d.enqueue(metric); err != nil {
+		d.release(metric)
+		return err
+	}
+	return nil
+}
+
+func (d *manager) enqueue(metric model.Metric) error {
+	q, err := d.entityQueue(metric.EntityID)
+	if err != nil {
+		return err
+	}
+	if err := q.Send(metric); err != nil {
+		if errors.Is(err, iqueue.ErrFull) {
+			telemetry.QueueOverflowsTotal.WithLabelValues(metric.EntityID, string(d.opts.queueOverflow)).Inc()
+			return fmt.Errorf("entity %s: %w", metric.EntityID, err)
+		}
+		return fmt.Errorf("error send to collect: %w", err)
 	}
-	d.mtx.RUnlock()
 	return nil
 }
 
+// entityQueue returns the queue of the entity, the queue is created on the first metric
+func (d *manager) entityQueue(entityID string) (*iqueue.Queue, error) {
+	d.mtx.RLock()
+	q, ok := d.queue[entityID]
+	closed := d.closed
+	d.mtx.RUnlock()
+	if closed {
+		return nil, fmt.Errorf("error send to collect, shutting down")
+	}
+	if ok {
+		return q, nil
+	}
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	if q, ok := d.queue[entityID]; ok {
+		return q, nil
+	}
+	s := d.pool.shard(entityID)
+	q = iqueue.New(
+		iqueue.WithCap(d.opts.queueCapacity),
+		iqueue.WithOverflow(d.opts.queueOverflow),
+		iqueue.WithBlockTimeout(d.opts.queueBlockTimeout),
+		iqueue.WithOnDrop(func(v interface{}) {
+			d.drop(v.(model.Metric))
+		}),
+		iqueue.WithOnSend(func() {
+			s.schedule(q)
+		}),
+	)
+	d.queue[entityID] = q
+	return q, nil
+}
+
+// drop reports the metric dropped by the overflow policy
+func (d *manager) drop(metric model.Metric) {
+	telemetry.QueueOverflowsTotal.WithLabelValues(metric.EntityID, string(d.opts.queueOverflow)).Inc()
+	d.release(metric)
+	if done, ok := d.waiter(metric.ID); ok {
+		done <- Verdict{Metric: metric, Err: ErrDropped}
+	}
+}
+
+// QueueDepths returns the number of the queued metrics of each entity
+func (d *manager) QueueDepths() map[string]int {
+	d.mtx.RLock()
+	defer d.mtx.RUnlock()
+	depths := make(map[string]int, len(d.queue))
+	for entityID, q := range d.queue {
+		depths[entityID] = q.Len()
+	}
+	return depths
+}
+
+// CollectSync adds data to the feed and waits until every metric is classified and persisted,
+// the verdicts are in the order of the data, the duplicates get ErrDuplicate
+func (d *manager) CollectSync(ctx context.Context, in ...model.Metric) []Verdict {
+	data := make([]model.Metric, len(in))
+	verdicts := make([]Verdict, len(data))
+	waiters := make([]chan Verdict, len(data))
+	duplicates := make([]bool, len(data))
+	d.waitMtx.Lock()
+	for i := range data {
+		data[i] = d.keyed(in[i])
+		waiters[i] = make(chan Verdict, 1)
+		// the metric with the same id is waited already by this or the other call
+		if _, ok := d.waiters[data[i].ID]; ok {
+			duplicates[i] = true
+			continue
+		}
+		d.waiters[data[i].ID] = waiters[i]
+	}
+	d.waitMtx.Unlock()
+
+	// the rejected metrics get the error at once, the others wait for the verdicts
+	for i := range data {
+		if duplicates[i] {
+			waiters[i] <- Verdict{Metric: data[i], Err: d.duplicate(data[i])}
+			continue
+		}
+		if err := d.collect(data[i]); err != nil {
+			d.dropWaiters(data[i:i+1], waiters[i:i+1])
+			waiters[i] <- Verdict{Metric: data[i], Err: err}
+		}
+	}
+
+	for i := range data {
+		select {
+		case verdicts[i] = <-waiters[i]:
+		case <-ctx.Done():
+			d.dropWaiters(data[i:], waiters[i:])
+			for j := i; j < len(data); j++ {
+				verdicts[j] = Verdict{Metric: data[j], Err: ctx.Err()}
+			}
+			return verdicts
+		}
+	}
+	return verdicts
+}
+
+// waiter returns the channel of CollectSync waiting for the metric
+func (d *manager) waiter(id uuid.UUID) (chan<- Verdict, bool) {
+	d.waitMtx.Lock()
+	defer d.waitMtx.Unlock()
+	ch, ok := d.waiters[id]
+	if ok {
+		delete(d.waiters, id)
+	}
+	return ch, ok
+}
+
+// dropWaiters deletes the channels of the metrics, the channels of the other calls waiting for the same ids are kept
+func (d *manager) dropWaiters(data []model.Metric, waiters []chan Verdict) {
+	d.waitMtx.Lock()
+	defer d.waitMtx.Unlock()
+	for i := range data {
+		if d.waiters[data[i].ID] == waiters[i] {
+			delete(d.waiters, data[i].ID)
+		}
+	}
+}
+
 // bulkLoad loading data from storage to memory
 func (d *manager) bulkLoad(ctx context.Context) error {
 	var newMetrics []model.Metric
@@ -323,8 +896,8 @@ func (d *manager) bulkLoad(ctx context.Context) error {
 		if _, ok := processedMetrics[dat.EntityID]; !ok {
 			processedMetrics[dat.EntityID] = []predictor.DataPoint{}
 		}
-		// divide metrics by the statuses "processed" and " new"
-		if dat.IsProcessed() {
+		// divide metrics by the statuses "processed" and " new", the excluded metrics are not loaded
+		if dat.InDataset() {
 			processedMetrics[dat.EntityID] = append(processedMetrics[dat.EntityID], dat)
 		}
 		if dat.IsNew() {
@@ -335,7 +908,7 @@ func (d *manager) bulkLoad(ctx context.Context) error {
 	for k, list := range processedMetrics {
 		loadPredictor, ok := d.predictors[k]
 		if !ok {
-			newPredictorFn, err := d.predictorProvideFn()
+			newPredictorFn, err := d.predictorProvideFn(d.settings(k))
 			if err != nil {
 				return fmt.Errorf("can not create predictor instance: %w", err)
 			}
@@ -345,82 +918,164 @@ func (d *manager) bulkLoad(ctx context.Context) error {
 		// bulk load data to the predictor
 		loadPredictor.Build(list...)
 	}
-	// metrics with the "new" status are sent to the queue for processing
+	// metrics with the "new" status are sent to the queue for processing,
+	// the metrics rejected by the full queues stay new until the next start
 	for i := range newMetrics {
-		d.collectCh <- newMetrics[i]
+		if err := d.enqueue(newMetrics[i]); err != nil {
+			logging.FromContext(ctx).Errorf("unable queue stored metric: %v", err)
+		}
 	}
 
 	return nil
 }
 
+func (d *manager) processLogged(ctx context.Context, metric model.Metric) {
+	if err := d.process(ctx, metric); err != nil {
+		logging.FromContext(ctx).Errorf("unable processed data: %v", err)
+	}
+}
+
+// process classifies the metric and reports the verdict to CollectSync waiting for the metric
 func (d *manager) process(ctx context.Context, metric model.Metric) error {
-	logger := logging.FromContext(ctx)
+	done, wait := d.waiter(metric.ID)
+	res, err := d.classify(ctx, metric, wait)
+	if err != nil {
+		// the metric is not stored, its retry is not duplicate
+		d.release(metric)
+	}
+	if wait {
+		go func() {
+			v := Verdict{Metric: res.metric, Classified: res.classified, Err: err}
+			if v.Err == nil && res.persisted != nil {
+				if persistErr := <-res.persisted; persistErr != nil {
+					v.Err = fmt.Errorf("unable persist: %w", persistErr)
+				}
+			}
+			done <- v
+		}()
+	}
+	return err
+}
+
+// classification is the result of classify, persisted receives the result of the write
+// when the caller waits for the persistence
+type classification struct {
+	metric     model.Metric
+	classified bool
+	persisted  <-chan error
+}
+
+// entityPredictor returns the predictor of the entity, the predictor is created on the first call
+func (d *manager) entityPredictor(entityID string) (predictor.Predictor, error) {
 	d.mtx.RLock()
-	entityPredictor, ok := d.predictors[metric.EntityID]
+	entityPredictor, ok := d.predictors[entityID]
 	d.mtx.RUnlock()
+	if ok {
+		return entityPredictor, nil
+	}
 
-	if !ok {
-		newPredictor, err := d.predictorProvideFn()
-		if err != nil {
-			d.mtx.Unlock()
-			return fmt.Errorf("can not create predictor instance: %w", err)
-		}
-		entityPredictor = newPredictor
-		d.mtx.Lock()
-		d.predictors[metric.EntityID] = newPredictor
+	newPredictor, err := d.predictorProvideFn(d.settings(entityID))
+	if err != nil {
+		return nil, fmt.Errorf("can not create predictor instance: %w", err)
+	}
+	// the predictor created or rebuilt meanwhile is kept
+	d.mtx.Lock()
+	if current, ok := d.predictors[entityID]; ok {
 		d.mtx.Unlock()
+		closePredictor(newPredictor)
+		return current, nil
 	}
+	d.predictors[entityID] = newPredictor
+	d.mtx.Unlock()
+	return newPredictor, nil
+}
 
-	if entityPredictor.Len() < d.opts.skipItems || entityPredictor.Len() < 3 {
+func (d *manager) classify(ctx context.Context, metric model.Metric, wait bool) (classification, error) {
+	logger := logging.FromContext(ctx)
+	res := classification{metric: metric}
+	entityPredictor, err := d.entityPredictor(metric.EntityID)
+	if err != nil {
+		return res, err
+	}
+
+	if entityPredictor.Len() < d.skipItems(metric.EntityID) || entityPredictor.Len() < 3 {
 		metric.Status = model.StatusProcessed
-		d.dbTxExecutor.write(ctx, metric)
+		res.metric = metric
+		res.persisted = d.write(ctx, metric, wait)
 		entityPredictor.Append(&metric)
-		return nil
+		return res, nil
 	}
 
 	metric.Status = model.StatusNew
 
-	d.dbTxExecutor.write(ctx, metric)
+	// the metric is not stored when the append of the data is disabled
+	if d.opts.allowAppendData {
+		d.dbTxExecutor.write(ctx, metric)
+	}
 
 	result, predictErr := entityPredictor.Predict(metric.Point())
 	if predictErr != nil {
 		if err := d.opts.deps.deleteMetric(context.Background(), metric); err != nil {
-			return fmt.Errorf("unable predict: %w", err)
+			return res, fmt.Errorf("unable predict: %w", err)
 		}
-		return fmt.Errorf("unable predict: %w", predictErr)
+		return res, fmt.Errorf("unable predict: %w", predictErr)
 	}
 
 	metric.Outlier = result.Outlier
+	metric.Score = result.Score
+	metric.Threshold = result.Threshold
+	metric.Confidence = result.Confidence
 
 	if result.Outlier {
 		logger.Infof("detect dispatcher, %v", result)
+		telemetry.OutliersTotal.WithLabelValues(metric.EntityID).Inc()
 		d.mtx.RLock()
 		if vec, ok := d.normVectors[metric.EntityID]; ok {
 			metric.NormVec = vec
 		}
 		d.mtx.RUnlock()
-		d.alert(metric)
 	} else {
 		d.mtx.Lock()
 		d.normVectors[metric.EntityID] = metric.NormVec
 		d.mtx.Unlock()
 	}
 
+	d.stream.Publish(metric)
+	d.observe(metric)
+	if notify := d.alertRules.Evaluate(metric); len(notify) > 0 {
+		d.alert(notify...)
+	}
+
+	res.metric, res.classified = metric, true
 	if !d.opts.allowAppendData {
-		if err := d.opts.deps.deleteMetric(ctx, metric); err != nil {
-			return fmt.Errorf("delete transaction error: %w", err)
+		// only the dedup key is kept to reject the retries of the metric
+		err := d.opts.deps.appendKeys(ctx, []model.Metric{metric})
+		d.release(metric)
+		if err != nil {
+			return res, fmt.Errorf("unable append dedup key: %w", err)
 		}
-		return nil
+		return res, nil
 	}
 
-	if (result.Outlier && d.opts.allowAppendOutlier) || !result.Outlier {
+	metric.Excluded = result.Outlier && !d.opts.allowAppendOutlier
+	if !metric.Excluded {
 		entityPredictor.Append(&metric)
 	}
 
 	metric.Status = model.StatusProcessed
+	res.metric = metric
 
-	d.dbTxExecutor.write(ctx, metric)
+	res.persisted = d.write(ctx, metric, wait)
 
+	return res, nil
+}
+
+// write buffers the metric, with wait the buffer is flushed at once and the result of the flush is returned
+func (d *manager) write(ctx context.Context, metric model.Metric, wait bool) <-chan error {
+	if wait {
+		return d.dbTxExecutor.writeSync(ctx, metric)
+	}
+	d.dbTxExecutor.write(ctx, metric)
 	return nil
 }
 
@@ -434,82 +1089,45 @@ func (d *manager) alert(in ...model.Metric) {
 	d.mtx.RUnlock()
 }
 
-func (d *manager) shutdown(ctx context.Context, q *iqueue.Queue) error {
-	for {
-		front := q.Queue().Front()
-		if front == nil {
-			if !d.recvShutdown() {
-				return fmt.Errorf("dispatcher shutdown: closed num receivers not equal created")
-			}
-			d.cancelNotifier()
-			break
-		}
-
-		if err := d.process(ctx, front.Value.(model.Metric)); err != nil {
-			return fmt.Errorf("dispatcher shutdown: unable processed data: %w", err)
-		}
-
-		q.Queue().Remove(front)
+func (d *manager) observe(metric model.Metric) {
+	d.mtx.RLock()
+	if !d.closed {
+		d.mtx.RUnlock()
+		d.notifier.Observe(metric)
+		return
 	}
-	return nil
+	d.mtx.RUnlock()
 }
 
-func (d *manager) recvShutdown() bool {
-	finishedNum, predictorsNum := 0, len(d.queue)
+// shutdown processes the rest of the queues and the reorder buffers after the workers are stopped,
+// then the buffer of the executor is flushed
+func (d *manager) shutdown(ctx context.Context) error {
+	d.mtx.RLock()
+	queues := make([]*iqueue.Queue, 0, len(d.queue))
 	for _, q := range d.queue {
-		if q.Queue().Len() == 0 {
-			finishedNum += 1
-		}
+		queues = append(queues, q)
 	}
+	d.mtx.RUnlock()
 
-	return finishedNum == predictorsNum
-}
-
-func (d *manager) receive(ctx context.Context, q *iqueue.Queue) {
-	logger := logging.FromContext(ctx)
-	defer func() {
-		d.shutDownCh <- d.shutdown(ctx, q)
-	}()
-
-	for {
-		select {
-		case recv := <-q.Receive():
-			if err := d.process(ctx, recv.(model.Metric)); err != nil {
-				logger.Errorf("unable processed data: %v", err)
-			}
-		case <-ctx.Done():
-			return
+	defer d.cancelNotifier()
+	for _, q := range queues {
+		q.Close()
+		for _, v := range q.Drain() {
+			d.admit(ctx, v.(model.Metric))
 		}
 	}
+	d.releaseBuffers(ctx, true)
+	d.closePredictors()
+	return d.dbTxExecutor.shutdown()
 }
 
-const workerMul = 2
-
-func (d *manager) worker(ctx context.Context, queue *iqueue.Queue, num int) {
-	for i := 0; i < num; i++ {
-		go d.receive(ctx, queue)
+// closePredictors closes the predictors of all entities after the processing is stopped
+func (d *manager) closePredictors() {
+	d.mtx.RLock()
+	defer d.mtx.RUnlock()
+	for _, p := range d.predictors {
+		closePredictor(p)
 	}
 }
 
-func (d *manager) collector(ctx context.Context) {
-	defer close(d.collectCh)
-	for {
-		select {
-		case in := <-d.collectCh:
-			q, ok := d.queue[in.EntityID]
-			if !ok {
-				queue := iqueue.New()
-				go queue.Loop()
-				d.worker(ctx, queue, runtime.NumCPU()*workerMul)
-				d.queue[in.EntityID] = queue
-				q = queue
-			}
-			q.Send(in)
-		case <-ctx.Done():
-			d.mtx.Lock()
-			d.closed = true
-			d.mtx.Unlock()
-			return
-		}
-	}
-}
+const workerMul = 2
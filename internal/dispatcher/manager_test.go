@@ -1,16 +1,27 @@
 package dispatcher
 
 import (
+	"context"
 	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/go-sod/sod/internal/alert"
 	"github.com/go-sod/sod/internal/database"
+	entityModel "github.com/go-sod/sod/internal/entity/model"
 	"github.com/go-sod/sod/internal/geom"
 	"github.com/go-sod/sod/internal/metric/model"
 	"github.com/go-sod/sod/internal/predictor"
 	"github.com/go-sod/sod/internal/predictor/mocks"
+	"github.com/go-sod/sod/internal/stream"
+	"github.com/go-sod/sod/pkg/iqueue"
+	"github.com/stretchr/testify/mock"
+	bolt "go.etcd.io/bbolt"
 )
 
 func TestManager_Predict(t *testing.T) {
@@ -53,7 +64,7 @@ func TestManager_Predict(t *testing.T) {
 			dataPoint.On("Point").Return(point)
 			pred.On("Predict", point).Return(test.conclusion, nil)
 
-			m, _ := New(test.db, func() (predictor.Predictor, error) {
+			m, _ := New(test.db, func(predictor.Settings) (predictor.Predictor, error) {
 				return pred, nil
 			}, notifier, test.shutdownCh)
 
@@ -116,7 +127,7 @@ func TestManager_Collect(t *testing.T) {
 			dataPoint.On("Point").Return(point)
 			pred.On("Collect", point).Return(test.conclusion, nil)
 
-			m, _ := New(test.db, func() (predictor.Predictor, error) {
+			m, _ := New(test.db, func(predictor.Settings) (predictor.Predictor, error) {
 				return pred, nil
 			}, notifier, test.shutdownCh)
 			m.closed = test.closed
@@ -125,9 +136,478 @@ func TestManager_Collect(t *testing.T) {
 				t.Errorf("compute Collect, got: %v, expected: %v", err, test.expectedErr)
 			}
 
-			if err == nil && len(m.collectCh) != 1 {
-				t.Errorf("compute Collect, got: %v, expected: %v", len(m.collectCh), test.expectedLen)
+			if depth := m.QueueDepths()[test.metric.EntityID]; err == nil && depth != 1 {
+				t.Errorf("compute Collect, got: %v, expected: %v", depth, test.expectedLen)
 			}
 		})
 	}
 }
+
+func TestManager_SetEntityConfig(t *testing.T) {
+	t.Parallel()
+	kNum, negative := 5, -1
+	tests := []struct {
+		name        string
+		cfg         entityModel.Config
+		provideErr  error
+		expectedErr error
+	}{
+		{
+			name: "positive_set_entity_config",
+			cfg:  entityModel.Config{EntityID: "test-entity", Settings: predictor.Settings{KNum: &kNum}},
+		},
+		{
+			name:        "negative_set_entity_config_without_entity",
+			cfg:         entityModel.Config{Settings: predictor.Settings{KNum: &kNum}},
+			expectedErr: ErrInvalidEntityConfig,
+		},
+		{
+			name:        "negative_set_entity_config_negative_limit",
+			cfg:         entityModel.Config{EntityID: "test-entity", Settings: predictor.Settings{SkipItems: &negative}},
+			expectedErr: ErrInvalidEntityConfig,
+		},
+		{
+			name:        "negative_set_entity_config_invalid",
+			cfg:         entityModel.Config{EntityID: "test-entity", Settings: predictor.Settings{KNum: &kNum}},
+			provideErr:  errors.New("test-err"),
+			expectedErr: ErrInvalidEntityConfig,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			db := newTestDB(t)
+			shutdownCh := make(chan error, 1)
+			notifier, _ := alert.New(db, shutdownCh)
+
+			var provided []predictor.Settings
+			m, _ := New(db, func(s predictor.Settings) (predictor.Predictor, error) {
+				provided = append(provided, s)
+				pred := &mocks.Predictor{}
+				pred.On("Build", mock.Anything).Return()
+				return pred, test.provideErr
+			}, notifier, shutdownCh)
+
+			err := m.SetEntityConfig(context.Background(), test.cfg)
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("compute SetEntityConfig, got: %v, expected: %v", err, test.expectedErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(provided) != 1 || *provided[0].KNum != kNum {
+				t.Errorf("predictor settings, got: %v, expected k num: %d", provided, kNum)
+			}
+			if _, ok := m.predictors[test.cfg.EntityID]; !ok {
+				t.Errorf("predictor of entity %s is not rebuilt", test.cfg.EntityID)
+			}
+
+			if err := m.DeleteEntityConfig(context.Background(), test.cfg.EntityID); err != nil {
+				t.Fatalf("compute DeleteEntityConfig: %v", err)
+			}
+			if _, err := m.EntityConfig(test.cfg.EntityID); !errors.Is(err, ErrEntityConfigNotFound) {
+				t.Errorf("compute EntityConfig, got: %v, expected: %v", err, ErrEntityConfigNotFound)
+			}
+			if len(provided) != 2 || provided[1].KNum != nil {
+				t.Errorf("predictor settings after delete, got: %v, expected global", provided)
+			}
+		})
+	}
+}
+
+func TestManager_RebuildPredictor(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	shutdownCh := make(chan error, 1024)
+	notifier, _ := alert.New(db, shutdownCh)
+
+	// the first predictor classifies the metrics, the second one is built by the config
+	var provided []*mocks.Predictor
+	m, err := New(db, func(predictor.Settings) (predictor.Predictor, error) {
+		pred := &mocks.Predictor{}
+		pred.On("Build", mock.Anything, mock.Anything, mock.Anything).Return()
+		pred.On("Len").Return(10)
+		pred.On("Append", mock.Anything).Return()
+		pred.On("Predict", mock.Anything).Return(&predictor.Conclusion{Score: 0.5, Threshold: 1}, nil)
+		provided = append(provided, pred)
+		return pred, nil
+	}, notifier, shutdownCh, WithDBFlushSize(100), WithDBFlushTime(time.Hour), WithAllowAppendData(true))
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := m.Run(ctx); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	sub := m.Subscribe(stream.Filter{}, 16)
+	defer sub.Close()
+
+	for i := 0; i < 3; i++ {
+		if err := m.Collect(model.NewMetric("test-entity", geom.Point{float64(i)}, time.Now(), nil)); err != nil {
+			t.Fatalf("collect: %v", err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case <-sub.C():
+		case <-time.After(5 * time.Second):
+			t.Fatalf("metric %d is not classified", i)
+		}
+	}
+
+	// the classified metrics are in the buffer of the executor until the flush time
+	if err := m.SetEntityConfig(ctx, entityModel.Config{EntityID: "test-entity"}); err != nil {
+		t.Fatalf("compute SetEntityConfig: %v", err)
+	}
+	if len(provided) != 2 {
+		t.Fatalf("provided predictors, got: %d, expected: 2", len(provided))
+	}
+	builds := callsOf(provided[1], "Build")
+	if len(builds) != 1 || len(builds[0].Arguments) != 3 {
+		t.Errorf("rebuilt predictor, got builds: %v, expected 3 metrics", builds)
+	}
+}
+
+// closingPredictor counts the calls of Close
+type closingPredictor struct {
+	*mocks.Predictor
+	closed int32
+}
+
+func (p *closingPredictor) Close() {
+	atomic.AddInt32(&p.closed, 1)
+}
+
+func TestManager_ClosePredictors(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	shutdownCh := make(chan error, 1)
+	notifier, _ := alert.New(db, shutdownCh)
+
+	const concurrent = 8
+	// the predictors of the concurrent calls are provided together
+	var ready sync.WaitGroup
+	ready.Add(concurrent)
+	var mtx sync.Mutex
+	var provided []*closingPredictor
+	m, err := New(db, func(predictor.Settings) (predictor.Predictor, error) {
+		pred := &closingPredictor{Predictor: &mocks.Predictor{}}
+		pred.On("Build", mock.Anything).Return()
+		mtx.Lock()
+		provided = append(provided, pred)
+		n := len(provided)
+		mtx.Unlock()
+		if n <= concurrent {
+			ready.Done()
+			ready.Wait()
+		}
+		return pred, nil
+	}, notifier, shutdownCh, WithDBFlushSize(100), WithDBFlushTime(time.Hour))
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := m.Run(ctx); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	// the predictors created concurrently are closed except the kept one
+	var wg sync.WaitGroup
+	for i := 0; i < concurrent; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := m.entityPredictor("test-entity"); err != nil {
+				t.Errorf("entity predictor: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	kept, _ := m.entityPredictor("test-entity")
+	// the replaced predictor is closed
+	if err := m.SetEntityConfig(ctx, entityModel.Config{EntityID: "test-entity"}); err != nil {
+		t.Fatalf("compute SetEntityConfig: %v", err)
+	}
+	rebuilt, _ := m.entityPredictor("test-entity")
+
+	cancel()
+	select {
+	case err := <-shutdownCh:
+		if err != nil {
+			t.Fatalf("shutdown: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("manager is not stopped")
+	}
+	// every predictor is closed once, the rebuilt one on the shutdown
+	mtx.Lock()
+	defer mtx.Unlock()
+	for i, pred := range provided {
+		if closed := atomic.LoadInt32(&pred.closed); closed != 1 {
+			t.Errorf("predictor %d (kept %v, rebuilt %v) closed, got: %d, expected: 1",
+				i, pred == kept, pred == rebuilt, closed)
+		}
+	}
+}
+
+func TestManager_CollectSync(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	notifier, _ := alert.New(db, make(chan error, 1))
+	shutdownCh := make(chan error, 1)
+
+	pred := &mocks.Predictor{}
+	pred.On("Build", mock.Anything).Return()
+	pred.On("Len").Return(10)
+	pred.On("Append", mock.Anything).Return()
+	pred.On("Predict", mock.Anything).Return(&predictor.Conclusion{Outlier: true, Score: 2, Threshold: 1}, nil)
+	m, err := New(db, func(predictor.Settings) (predictor.Predictor, error) {
+		return pred, nil
+	}, notifier, shutdownCh, WithDBFlushSize(100), WithDBFlushTime(time.Hour), WithAllowAppendData(true))
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := m.Run(ctx); err != nil {
+		cancel()
+		t.Fatalf("run: %v", err)
+	}
+	// the manager is stopped before the database is closed
+	t.Cleanup(func() {
+		cancel()
+		select {
+		case err := <-shutdownCh:
+			if err != nil {
+				t.Errorf("shutdown: %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("manager is not stopped")
+		}
+	})
+
+	metrics := []model.Metric{
+		model.NewMetric("test-entity", geom.NewPoint([]float64{1, 1}), time.Now(), nil),
+		model.NewMetric("test-entity", geom.NewPoint([]float64{2, 2}), time.Now(), nil),
+	}
+	reqCtx, reqCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer reqCancel()
+	verdicts := m.CollectSync(reqCtx, metrics...)
+	for i, v := range verdicts {
+		if v.Err != nil {
+			t.Fatalf("verdict %d: %v", i, v.Err)
+		}
+		if v.Metric.ID != metrics[i].ID || !v.Classified || !v.Metric.Outlier || v.Metric.Score != 2 {
+			t.Errorf("verdict %d, got: %+v", i, v)
+		}
+	}
+
+	// the verdicts are returned after the metrics are persisted, not after the flush time
+	stored, err := m.metricDB.FindByEntity("test-entity", nil)
+	if err != nil {
+		t.Fatalf("find metrics: %v", err)
+	}
+	if len(stored) != 2 {
+		t.Fatalf("stored metrics, got: %d, expected: 2", len(stored))
+	}
+	for _, metric := range stored {
+		if !metric.IsProcessed() || !metric.Outlier {
+			t.Errorf("stored metric, got: %+v", metric)
+		}
+	}
+
+	m.mtx.Lock()
+	m.closed = true
+	m.mtx.Unlock()
+	for _, v := range m.CollectSync(reqCtx, model.NewMetric("test-entity", geom.NewPoint([]float64{1, 1}), time.Now(), nil)) {
+		if v.Err == nil {
+			t.Errorf("collect after shutdown is accepted")
+		}
+	}
+}
+
+func TestManager_CollectDuplicates(t *testing.T) {
+	t.Parallel()
+	createdAt := time.Now()
+	newMetric := func(vec ...float64) model.Metric {
+		return model.NewMetric("test-entity", geom.NewPoint(vec), createdAt, nil)
+	}
+	tests := []struct {
+		name     string
+		dedupKey DedupKey
+		// the processed metrics are not stored
+		appendDisabled bool
+		// the metrics of the first and the retried request
+		first, retried []model.Metric
+		duplicates     []bool
+	}{
+		{
+			name:       "positive_client_key",
+			dedupKey:   DedupKeyNone,
+			first:      []model.Metric{newMetric(1, 1).WithKey("a"), newMetric(1, 1).WithKey("a")},
+			retried:    []model.Metric{newMetric(1, 1).WithKey("a"), newMetric(1, 1).WithKey("b")},
+			duplicates: []bool{false, true, true, false},
+		},
+		{
+			name:       "positive_content_key",
+			dedupKey:   DedupKeyContent,
+			first:      []model.Metric{newMetric(1, 1), newMetric(2, 2)},
+			retried:    []model.Metric{newMetric(2, 2), newMetric(3, 3)},
+			duplicates: []bool{false, false, true, false},
+		},
+		{
+			name:           "positive_content_key_append_disabled",
+			dedupKey:       DedupKeyContent,
+			appendDisabled: true,
+			first:          []model.Metric{newMetric(1, 1)},
+			retried:        []model.Metric{newMetric(1, 1), newMetric(2, 2)},
+			duplicates:     []bool{false, true, false},
+		},
+		{
+			name:       "negative_without_key",
+			dedupKey:   DedupKeyNone,
+			first:      []model.Metric{newMetric(1, 1)},
+			retried:    []model.Metric{newMetric(1, 1)},
+			duplicates: []bool{false, false},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			db := newTestDB(t)
+			shutdownCh := make(chan error, 1024)
+			notifier, _ := alert.New(db, shutdownCh)
+
+			pred := &mocks.Predictor{}
+			pred.On("Build", mock.Anything).Return()
+			pred.On("Len").Return(10)
+			pred.On("Append", mock.Anything).Return()
+			pred.On("Predict", mock.Anything).Return(&predictor.Conclusion{Score: 0.5, Threshold: 1}, nil)
+			m, err := New(db, func(predictor.Settings) (predictor.Predictor, error) {
+				return pred, nil
+			}, notifier, shutdownCh, WithDBFlushSize(100), WithDBFlushTime(time.Hour), WithAllowAppendData(!test.appendDisabled),
+				WithDedupKey(test.dedupKey))
+			if err != nil {
+				t.Fatalf("new manager: %v", err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if err := m.Run(ctx); err != nil {
+				t.Fatalf("run: %v", err)
+			}
+
+			// the retried request is checked against the persisted keys of the first one
+			reqCtx, reqCancel := context.WithTimeout(ctx, 5*time.Second)
+			defer reqCancel()
+			verdicts := m.CollectSync(reqCtx, test.first...)
+			if err := m.dbTxExecutor.flush(ctx); err != nil {
+				t.Fatalf("flush: %v", err)
+			}
+			verdicts = append(verdicts, m.CollectSync(reqCtx, test.retried...)...)
+			if err := m.dbTxExecutor.flush(ctx); err != nil {
+				t.Fatalf("flush: %v", err)
+			}
+			for i, v := range verdicts {
+				if duplicate := errors.Is(v.Err, ErrDuplicate); duplicate != test.duplicates[i] {
+					t.Errorf("verdict %d duplicate, got: %v (%v), expected: %v", i, duplicate, v.Err, test.duplicates[i])
+				}
+			}
+
+			expected := 0
+			for _, duplicate := range test.duplicates {
+				if !duplicate && !test.appendDisabled {
+					expected++
+				}
+			}
+			stored, err := m.metricDB.FindByEntity("test-entity", nil)
+			if err != nil {
+				t.Fatalf("find metrics: %v", err)
+			}
+			if len(stored) != expected {
+				t.Errorf("stored metrics, got: %d, expected: %d", len(stored), expected)
+			}
+		})
+	}
+}
+
+func TestManager_CollectOverflow(t *testing.T) {
+	t.Parallel()
+	newMetric := func() model.Metric {
+		return model.NewMetric("test-entity", geom.NewPoint([]float64{1, 1}), time.Now(), nil)
+	}
+	tests := []struct {
+		name     string
+		overflow iqueue.Overflow
+		expected error
+	}{
+		{
+			name:     "positive_drop_newest",
+			overflow: iqueue.OverflowDropNewest,
+			expected: ErrDropped,
+		},
+		{
+			name:     "negative_reject",
+			overflow: iqueue.OverflowReject,
+			expected: ErrQueueFull,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			db := newTestDB(t)
+			shutdownCh := make(chan error, 1)
+			notifier, _ := alert.New(db, shutdownCh)
+			// the manager is not running, the queued metrics stay in the queue
+			m, err := New(db, func(predictor.Settings) (predictor.Predictor, error) {
+				return &mocks.Predictor{}, nil
+			}, notifier, shutdownCh, WithQueueCapacity(2), WithQueueOverflow(test.overflow))
+			if err != nil {
+				t.Fatalf("new manager: %v", err)
+			}
+			if err := m.Collect(newMetric(), newMetric()); err != nil {
+				t.Fatalf("collect: %v", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			verdicts := m.CollectSync(ctx, newMetric())
+			if !errors.Is(verdicts[0].Err, test.expected) {
+				t.Errorf("verdict of full queue, got: %v, expected: %v", verdicts[0].Err, test.expected)
+			}
+			if depth := m.QueueDepths()["test-entity"]; depth != 2 {
+				t.Errorf("queue depth, got: %d, expected: 2", depth)
+			}
+		})
+	}
+
+	notifier, _ := alert.New(&database.DB{}, make(chan error, 1))
+	if _, err := New(&database.DB{}, func(predictor.Settings) (predictor.Predictor, error) {
+		return &mocks.Predictor{}, nil
+	}, notifier, make(chan error, 1), WithQueueOverflow("UNKNOWN")); err == nil {
+		t.Errorf("unknown overflow policy is accepted")
+	}
+}
+
+func newTestDB(t *testing.T) *database.DB {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "sod-dispatcher")
+	if err != nil {
+		t.Fatalf("create temp dir: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+	boltDB, err := bolt.Open(filepath.Join(dir, "test.db"), 0600, nil)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = boltDB.Close()
+	})
+	return &database.DB{DB: boltDB}
+}
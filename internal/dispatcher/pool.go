@@ -0,0 +1,132 @@
+package dispatcher
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+
+	"github.com/go-sod/sod/pkg/iqueue"
+)
+
+// workerBatch is the maximum number of the values processed from one queue
+// before the worker moves on to the next ready queue of its shard
+const workerBatch = 64
+
+// workerPool serves the queues by the fixed number of workers.
+// The queue of the entity is sharded to the worker by the hash of the entity id,
+// so the values of the entity are processed one by one in the order of the queue
+type workerPool struct {
+	shards []*shard
+}
+
+func newWorkerPool(size int) *workerPool {
+	p := &workerPool{shards: make([]*shard, size)}
+	for i := range p.shards {
+		p.shards[i] = &shard{
+			scheduled: map[*iqueue.Queue]bool{},
+			wake:      make(chan struct{}, 1),
+		}
+	}
+	return p
+}
+
+// shard returns the shard serving the entity
+func (p *workerPool) shard(entityID string) *shard {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(entityID))
+	return p.shards[h.Sum32()%uint32(len(p.shards))]
+}
+
+// run starts a worker per shard, the returned channel is closed when all workers are stopped by the context
+func (p *workerPool) run(ctx context.Context, process func(context.Context, interface{})) <-chan struct{} {
+	var wg sync.WaitGroup
+	wg.Add(len(p.shards))
+	for _, s := range p.shards {
+		go func(s *shard) {
+			defer wg.Done()
+			s.work(ctx, process)
+		}(s)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	return done
+}
+
+// shard is the set of the queues served by one worker, ready holds the queues with the pending values
+type shard struct {
+	mtx       sync.Mutex
+	ready     []*iqueue.Queue
+	scheduled map[*iqueue.Queue]bool
+	wake      chan struct{}
+}
+
+// schedule adds the queue to the ready queues unless it is already there
+func (s *shard) schedule(q *iqueue.Queue) {
+	s.mtx.Lock()
+	if s.scheduled[q] {
+		s.mtx.Unlock()
+		return
+	}
+	s.scheduled[q] = true
+	s.ready = append(s.ready, q)
+	s.mtx.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next waits for the ready queue, the queues left ready after the ctx is done are drained by the shutdown
+func (s *shard) next(ctx context.Context) (*iqueue.Queue, bool) {
+	for {
+		if ctx.Err() != nil {
+			return nil, false
+		}
+		s.mtx.Lock()
+		if len(s.ready) > 0 {
+			q := s.ready[0]
+			s.ready[0] = nil
+			s.ready = s.ready[1:]
+			s.mtx.Unlock()
+			return q, true
+		}
+		s.mtx.Unlock()
+
+		select {
+		case <-s.wake:
+		case <-ctx.Done():
+			return nil, false
+		}
+	}
+}
+
+// release unschedules the served queue, the queue is scheduled again when it got the values meanwhile
+func (s *shard) release(q *iqueue.Queue) {
+	s.mtx.Lock()
+	delete(s.scheduled, q)
+	s.mtx.Unlock()
+	if q.Len() > 0 {
+		s.schedule(q)
+	}
+}
+
+func (s *shard) work(ctx context.Context, process func(context.Context, interface{})) {
+	for {
+		q, ok := s.next(ctx)
+		if !ok {
+			return
+		}
+		for i := 0; i < workerBatch && ctx.Err() == nil; i++ {
+			v, ok := q.Pop()
+			if !ok {
+				break
+			}
+			process(ctx, v)
+		}
+		s.release(q)
+	}
+}
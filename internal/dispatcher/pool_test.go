@@ -0,0 +1,147 @@
+package dispatcher
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-sod/sod/pkg/iqueue"
+)
+
+func TestWorkerPool(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		workers  int
+		entities int
+		values   int
+	}{
+		{
+			name:     "positive_single_worker",
+			workers:  1,
+			entities: 10,
+			values:   100,
+		},
+		{
+			name:     "positive_more_entities_than_workers",
+			workers:  4,
+			entities: 200,
+			values:   150,
+		},
+	}
+	type value struct {
+		entityID string
+		seq      int
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			p := newWorkerPool(test.workers)
+
+			var (
+				mtx       sync.Mutex
+				wg        sync.WaitGroup
+				processed = map[string][]int{}
+				active    = map[string]int{}
+				workers   int
+				maxActive int
+			)
+			wg.Add(test.entities * test.values)
+			ctx, cancel := context.WithCancel(context.Background())
+			done := p.run(ctx, func(_ context.Context, v interface{}) {
+				defer wg.Done()
+				val := v.(value)
+				mtx.Lock()
+				active[val.entityID]++
+				workers++
+				if active[val.entityID] > 1 {
+					t.Errorf("entity %s is processed concurrently", val.entityID)
+				}
+				if workers > maxActive {
+					maxActive = workers
+				}
+				mtx.Unlock()
+
+				mtx.Lock()
+				processed[val.entityID] = append(processed[val.entityID], val.seq)
+				active[val.entityID]--
+				workers--
+				mtx.Unlock()
+			})
+
+			var producers sync.WaitGroup
+			for e := 0; e < test.entities; e++ {
+				entityID := fmt.Sprintf("entity-%d", e)
+				s := p.shard(entityID)
+				var q *iqueue.Queue
+				q = iqueue.New(iqueue.WithOnSend(func() {
+					s.schedule(q)
+				}))
+				producers.Add(1)
+				go func() {
+					defer producers.Done()
+					for i := 0; i < test.values; i++ {
+						if err := q.Send(value{entityID: entityID, seq: i}); err != nil {
+							t.Errorf("send: %v", err)
+						}
+					}
+				}()
+			}
+			producers.Wait()
+
+			processedAll := make(chan struct{})
+			go func() {
+				wg.Wait()
+				close(processedAll)
+			}()
+			select {
+			case <-processedAll:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("values are not processed")
+			}
+			cancel()
+			<-done
+
+			if maxActive > test.workers {
+				t.Errorf("active workers, got: %d, expected at most: %d", maxActive, test.workers)
+			}
+			for entityID, seqs := range processed {
+				for i := range seqs {
+					if seqs[i] != i {
+						t.Fatalf("order of entity %s, got: %d at %d", entityID, seqs[i], i)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestWorkerPool_Cancel(t *testing.T) {
+	t.Parallel()
+	p := newWorkerPool(1)
+	s := p.shard("test-entity")
+	var q *iqueue.Queue
+	q = iqueue.New(iqueue.WithOnSend(func() {
+		s.schedule(q)
+	}))
+	for i := 0; i < 3; i++ {
+		if err := q.Send(i); err != nil {
+			t.Fatalf("send: %v", err)
+		}
+	}
+
+	// the workers stop with the ready queue, the values are left to the shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	select {
+	case <-p.run(ctx, func(context.Context, interface{}) {}):
+	case <-time.After(5 * time.Second):
+		t.Fatalf("workers are not stopped")
+	}
+	if q.Len() != 3 {
+		t.Errorf("queue length, got: %d, expected: 3", q.Len())
+	}
+}
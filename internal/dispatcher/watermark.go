@@ -0,0 +1,221 @@
+package dispatcher
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-sod/sod/internal/logging"
+	"github.com/go-sod/sod/internal/metric/model"
+	"github.com/go-sod/sod/internal/telemetry"
+)
+
+// ErrLate is the error of the verdict of the metric older than the watermark of the entity
+var ErrLate = errors.New("metric is older than the watermark")
+
+// LatePolicy is the behavior of the dispatcher with the metrics older than the watermark
+type LatePolicy string
+
+const (
+	// LatePolicyDrop drops the late metrics
+	LatePolicyDrop LatePolicy = "DROP"
+	// LatePolicyBucket stores the late metrics to the side bucket of the entity without the classification
+	LatePolicyBucket LatePolicy = "BUCKET"
+)
+
+func (p LatePolicy) Validate() error {
+	switch p {
+	case LatePolicyDrop, LatePolicyBucket:
+		return nil
+	default:
+		return fmt.Errorf("unknown late policy %s", p)
+	}
+}
+
+// releaseInterval is the period of releasing the metrics waiting in the reorder buffers longer than the allowed lateness
+const releaseInterval = 100 * time.Millisecond
+
+// reorderBuffer holds the metrics of the entity within the allowed lateness
+// and releases them in the order of the creation time.
+// The watermark is the creation time of the newest metric minus the allowed lateness,
+// it never moves behind the released metrics
+type reorderBuffer struct {
+	mtx      sync.Mutex
+	metrics  bufferedMetrics
+	seq      uint64
+	maxSeen  time.Time
+	released time.Time
+}
+
+func (b *reorderBuffer) watermark(lateness time.Duration) time.Time {
+	w := b.maxSeen.Add(-lateness)
+	if w.Before(b.released) {
+		return b.released
+	}
+	return w
+}
+
+// push adds the metric to the buffer, the metric older than the watermark is not added
+func (b *reorderBuffer) push(metric model.Metric, lateness time.Duration, now time.Time) bool {
+	if metric.CreatedAt.Before(b.watermark(lateness)) {
+		return false
+	}
+	b.seq++
+	heap.Push(&b.metrics, bufferedMetric{metric: metric, arrived: now, seq: b.seq})
+	if metric.CreatedAt.After(b.maxSeen) {
+		b.maxSeen = metric.CreatedAt
+	}
+	return true
+}
+
+// pop returns the metrics behind the watermark and the metrics waiting longer than the allowed lateness
+func (b *reorderBuffer) pop(lateness time.Duration, now time.Time) []model.Metric {
+	var ready []model.Metric
+	for b.metrics.Len() > 0 {
+		next := b.metrics[0]
+		if next.metric.CreatedAt.After(b.watermark(lateness)) && now.Sub(next.arrived) < lateness {
+			break
+		}
+		heap.Pop(&b.metrics)
+		if next.metric.CreatedAt.After(b.released) {
+			b.released = next.metric.CreatedAt
+		}
+		ready = append(ready, next.metric)
+	}
+	return ready
+}
+
+type bufferedMetric struct {
+	metric  model.Metric
+	arrived time.Time
+	// the metrics with the same creation time are released in the order of arrival
+	seq uint64
+}
+
+// bufferedMetrics is the min-heap of the metrics by the creation time
+type bufferedMetrics []bufferedMetric
+
+func (h bufferedMetrics) Len() int { return len(h) }
+
+func (h bufferedMetrics) Less(i, j int) bool {
+	if h[i].metric.CreatedAt.Equal(h[j].metric.CreatedAt) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].metric.CreatedAt.Before(h[j].metric.CreatedAt)
+}
+
+func (h bufferedMetrics) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *bufferedMetrics) Push(x interface{}) { *h = append(*h, x.(bufferedMetric)) }
+
+func (h *bufferedMetrics) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	old[n-1] = bufferedMetric{}
+	*h = old[:n-1]
+	return x
+}
+
+// allowedLateness returns the allowed lateness of the entity, the reordering is disabled with 0
+func (d *manager) allowedLateness(entityID string) time.Duration {
+	if s := d.settings(entityID); s.AllowedLateness != nil {
+		return s.AllowedLateness.Duration
+	}
+	return d.opts.allowedLateness
+}
+
+// reorderBuffer returns the buffer of the entity, the buffer is created on the first call
+func (d *manager) reorderBuffer(entityID string) *reorderBuffer {
+	d.mtx.RLock()
+	b, ok := d.buffers[entityID]
+	d.mtx.RUnlock()
+	if ok {
+		return b
+	}
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	if b, ok := d.buffers[entityID]; ok {
+		return b
+	}
+	b = &reorderBuffer{}
+	d.buffers[entityID] = b
+	return b
+}
+
+// admit passes the metric to the classification through the reorder buffer of the entity,
+// the metrics of the entity are processed under the lock of the buffer, so they are processed in order
+// and the rebuild of the predictor pauses them
+func (d *manager) admit(ctx context.Context, metric model.Metric) {
+	lateness := d.allowedLateness(metric.EntityID)
+	b := d.reorderBuffer(metric.EntityID)
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	now := time.Now()
+	if lateness > 0 && !b.push(metric, lateness, now) {
+		d.late(ctx, metric)
+		return
+	}
+	// the buffer of the entity with the disabled reordering is flushed
+	ready := b.pop(lateness, now)
+	if lateness <= 0 {
+		ready = append(ready, metric)
+	}
+	for i := range ready {
+		d.processLogged(ctx, ready[i])
+	}
+}
+
+// releaseBuffers processes the metrics waiting in the reorder buffers longer than the allowed lateness,
+// all buffered metrics are processed with flush
+func (d *manager) releaseBuffers(ctx context.Context, flush bool) {
+	d.mtx.RLock()
+	buffers := make(map[string]*reorderBuffer, len(d.buffers))
+	for entityID, b := range d.buffers {
+		buffers[entityID] = b
+	}
+	d.mtx.RUnlock()
+
+	for entityID, b := range buffers {
+		lateness := d.allowedLateness(entityID)
+		b.mtx.Lock()
+		if flush {
+			lateness = 0
+		}
+		ready := b.pop(lateness, time.Now())
+		for i := range ready {
+			d.processLogged(ctx, ready[i])
+		}
+		b.mtx.Unlock()
+	}
+}
+
+func (d *manager) releaser(ctx context.Context) {
+	ticker := time.NewTicker(releaseInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			d.releaseBuffers(ctx, false)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// late drops the metric older than the watermark or stores it to the side bucket
+func (d *manager) late(ctx context.Context, metric model.Metric) {
+	telemetry.LateMetricsTotal.WithLabelValues(metric.EntityID, string(d.opts.latePolicy)).Inc()
+	if d.opts.latePolicy == LatePolicyBucket {
+		if err := d.opts.deps.appendLateMetricsFn(ctx, []model.Metric{metric}); err != nil {
+			logging.FromContext(ctx).Errorf("unable store late metric: %v", err)
+		}
+	}
+	d.release(metric)
+	if done, ok := d.waiter(metric.ID); ok {
+		done <- Verdict{Metric: metric, Err: fmt.Errorf("entity %s, created at %s: %w", metric.EntityID, metric.CreatedAt, ErrLate)}
+	}
+}
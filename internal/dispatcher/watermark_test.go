@@ -0,0 +1,208 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-sod/sod/internal/alert"
+	"github.com/go-sod/sod/internal/geom"
+	"github.com/go-sod/sod/internal/metric/model"
+	"github.com/go-sod/sod/internal/predictor"
+	"github.com/go-sod/sod/internal/predictor/mocks"
+	"github.com/go-sod/sod/internal/stream"
+	"github.com/stretchr/testify/mock"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestReorderBuffer(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		lateness time.Duration
+		// creation times of the pushed metrics in seconds
+		pushed []int
+		// wall time of the pop since the last push
+		waited           time.Duration
+		expectedLate     []int
+		expectedReleased []int
+	}{
+		{
+			name:             "positive_reordered_within_lateness",
+			lateness:         10 * time.Second,
+			pushed:           []int{5, 1, 3, 20},
+			expectedReleased: []int{1, 3, 5},
+		},
+		{
+			name:             "positive_released_by_wall_time",
+			lateness:         10 * time.Second,
+			pushed:           []int{5, 1, 3},
+			waited:           10 * time.Second,
+			expectedReleased: []int{1, 3, 5},
+		},
+		{
+			name:             "negative_older_than_watermark",
+			lateness:         10 * time.Second,
+			pushed:           []int{30, 15, 25, 19, 20},
+			expectedLate:     []int{15, 19},
+			expectedReleased: []int{20},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			b := &reorderBuffer{}
+			var late, released []int
+			for _, sec := range test.pushed {
+				metric := model.NewMetric("test-entity", geom.Point{1}, start.Add(time.Duration(sec)*time.Second), nil)
+				if !b.push(metric, test.lateness, start) {
+					late = append(late, sec)
+				}
+			}
+			for _, metric := range b.pop(test.lateness, start.Add(test.waited)) {
+				released = append(released, int(metric.CreatedAt.Sub(start)/time.Second))
+			}
+			if !equalInts(late, test.expectedLate) {
+				t.Errorf("late, got: %v, expected: %v", late, test.expectedLate)
+			}
+			if !equalInts(released, test.expectedReleased) {
+				t.Errorf("released, got: %v, expected: %v", released, test.expectedReleased)
+			}
+		})
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestManager_AllowedLateness(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	shutdownCh := make(chan error, 1024)
+	notifier, _ := alert.New(db, shutdownCh)
+
+	pred := &mocks.Predictor{}
+	pred.On("Build", mock.Anything).Return()
+	pred.On("Len").Return(10)
+	pred.On("Append", mock.Anything).Return()
+	pred.On("Predict", mock.Anything).Return(&predictor.Conclusion{Score: 0.5, Threshold: 1}, nil)
+	m, err := New(db, func(predictor.Settings) (predictor.Predictor, error) {
+		return pred, nil
+	}, notifier, shutdownCh, WithDBFlushSize(100), WithDBFlushTime(time.Hour), WithAllowedLateness(time.Minute),
+		WithLatePolicy(LatePolicyBucket))
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := m.Run(ctx); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	sub := m.Subscribe(stream.Filter{}, 16)
+	defer sub.Close()
+
+	start := time.Now().Add(-time.Hour)
+	newMetric := func(d time.Duration) model.Metric {
+		return model.NewMetric("test-entity", geom.Point{1}, start.Add(d), nil)
+	}
+	// the newest metric moves the watermark past the buffered ones
+	if err := m.Collect(newMetric(30*time.Second), newMetric(10*time.Second), newMetric(20*time.Second)); err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	if err := m.Collect(newMetric(2 * time.Minute)); err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	for _, expected := range []time.Duration{10 * time.Second, 20 * time.Second, 30 * time.Second} {
+		select {
+		case metric := <-sub.C():
+			if got := metric.CreatedAt.Sub(start); got != expected {
+				t.Errorf("classified metric, got: %v, expected: %v", got, expected)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("metric %v is not classified", expected)
+		}
+	}
+
+	reqCtx, reqCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer reqCancel()
+	late := newMetric(40 * time.Second)
+	if v := m.CollectSync(reqCtx, late)[0]; !errors.Is(v.Err, ErrLate) {
+		t.Fatalf("verdict of late metric, got: %v, expected: %v", v.Err, ErrLate)
+	}
+	if err := db.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("late:test-entity"))
+		if b == nil || b.Get([]byte(late.ID.String())) == nil {
+			return errors.New("late metric is not stored")
+		}
+		return nil
+	}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestManager_Shutdown(t *testing.T) {
+	t.Parallel()
+	db := newTestDB(t)
+	notifier, _ := alert.New(db, make(chan error, 1))
+
+	pred := &mocks.Predictor{}
+	pred.On("Build", mock.Anything).Return()
+	pred.On("Len").Return(10)
+	pred.On("Append", mock.Anything).Return()
+	pred.On("Predict", mock.Anything).Return(&predictor.Conclusion{Score: 0.5, Threshold: 1}, nil)
+	shutdownCh := make(chan error, 1)
+	m, err := New(db, func(predictor.Settings) (predictor.Predictor, error) {
+		return pred, nil
+	}, notifier, shutdownCh, WithDBFlushSize(100), WithDBFlushTime(time.Hour), WithAllowAppendData(true),
+		WithAllowedLateness(time.Hour))
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := m.Run(ctx); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	// the metrics wait in the queue or in the reorder buffer, the flush time is not reached
+	start := time.Now().Add(-time.Minute)
+	for i := 0; i < 3; i++ {
+		if err := m.Collect(model.NewMetric("test-entity", geom.Point{1}, start.Add(time.Duration(i)*time.Second), nil)); err != nil {
+			t.Fatalf("collect: %v", err)
+		}
+	}
+	cancel()
+	select {
+	case err := <-shutdownCh:
+		if err != nil {
+			t.Fatalf("shutdown: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("manager is not shut down")
+	}
+
+	// the shutdown is reported after the drained metrics are persisted
+	stored, err := m.metricDB.FindByEntity("test-entity", nil)
+	if err != nil {
+		t.Fatalf("find metrics: %v", err)
+	}
+	if len(stored) != 3 {
+		t.Fatalf("stored metrics, got: %d, expected: 3", len(stored))
+	}
+	for _, metric := range stored {
+		if !metric.IsProcessed() {
+			t.Errorf("stored metric, got: %+v", metric)
+		}
+	}
+}
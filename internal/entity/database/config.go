@@ -0,0 +1,75 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-sod/sod/internal/database"
+	"github.com/go-sod/sod/internal/entity/model"
+	bolt "go.etcd.io/bbolt"
+)
+
+const configBucket = "entity:config:"
+
+func New(db *database.DB) *DB {
+	return &DB{sDB: db}
+}
+
+type DB struct {
+	sDB *database.DB
+}
+
+func (db *DB) Store(_ context.Context, cfg model.Config) error {
+	bytes, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	if err := db.sDB.DB.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(configBucket))
+		if err != nil {
+			return fmt.Errorf("create bucket: %w", err)
+		}
+		if err := b.Put([]byte(cfg.EntityID), bytes); err != nil {
+			return fmt.Errorf("put to bucket error: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("update transaction error: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) Delete(_ context.Context, entityID string) error {
+	if err := db.sDB.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(configBucket))
+		if b == nil {
+			return nil
+		}
+		return b.Delete([]byte(entityID))
+	}); err != nil {
+		return fmt.Errorf("update transaction error: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) FindAll(_ context.Context) ([]model.Config, error) {
+	var configs []model.Config
+	if err := db.sDB.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(configBucket))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(_, v []byte) error {
+			var cfg model.Config
+			if err := json.Unmarshal(v, &cfg); err != nil {
+				return fmt.Errorf("entity config unmarshal error, %w", err)
+			}
+			configs = append(configs, cfg)
+			return nil
+		})
+	}); err != nil {
+		return nil, fmt.Errorf("view transaction error: %w", err)
+	}
+	return configs, nil
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-sod/sod/internal/database"
+	"github.com/go-sod/sod/internal/entity/model"
+	"github.com/go-sod/sod/internal/predictor"
+	bolt "go.etcd.io/bbolt"
+)
+
+func newTestDB(t *testing.T) *database.DB {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "sod-entity")
+	if err != nil {
+		t.Fatalf("create temp dir: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+	boltDB, err := bolt.Open(filepath.Join(dir, "test.db"), 0600, nil)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = boltDB.Close()
+	})
+	return &database.DB{DB: boltDB}
+}
+
+func TestDB(t *testing.T) {
+	t.Parallel()
+	db := New(newTestDB(t))
+	ctx := context.Background()
+
+	// the configs are not found before the first store
+	configs, err := db.FindAll(ctx)
+	if err != nil || len(configs) != 0 {
+		t.Fatalf("find all of empty db, got: %v, %v", configs, err)
+	}
+	if err := db.Delete(ctx, "unknown"); err != nil {
+		t.Fatalf("delete of empty db: %v", err)
+	}
+
+	kNum := 5
+	for _, cfg := range []model.Config{
+		{EntityID: "first", Settings: predictor.Settings{KNum: &kNum}},
+		{EntityID: "second"},
+		// the later config of the entity replaces the earlier one
+		{EntityID: "second", Settings: predictor.Settings{KNum: &kNum}},
+	} {
+		if err := db.Store(ctx, cfg); err != nil {
+			t.Fatalf("store: %v", err)
+		}
+	}
+	configs, err = db.FindAll(ctx)
+	if err != nil {
+		t.Fatalf("find all: %v", err)
+	}
+	if len(configs) != 2 {
+		t.Fatalf("configs, got: %v, expected: 2", configs)
+	}
+	for _, cfg := range configs {
+		if cfg.KNum == nil || *cfg.KNum != kNum {
+			t.Errorf("config of entity %s, got k num: %v, expected: %d", cfg.EntityID, cfg.KNum, kNum)
+		}
+	}
+
+	if err := db.Delete(ctx, "first"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	configs, err = db.FindAll(ctx)
+	if err != nil {
+		t.Fatalf("find all: %v", err)
+	}
+	if len(configs) != 1 || configs[0].EntityID != "second" {
+		t.Errorf("configs after delete, got: %v, expected: [second]", configs)
+	}
+}
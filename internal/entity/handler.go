@@ -0,0 +1,138 @@
+package entity
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-sod/sod/internal/dispatcher"
+	"github.com/go-sod/sod/internal/entity/model"
+	"github.com/go-sod/sod/internal/httputil"
+	"github.com/go-sod/sod/internal/logging"
+)
+
+const maxBodyBytes = 64 * 1024
+
+// NewConfigHandler returns the handler managing the detector configuration of the entities
+//
+// GET without the entity parameter lists all configurations, GET ?entity= returns the entity configuration,
+// PUT stores the configuration from the body and DELETE ?entity= restores the global configuration of the entity
+func NewConfigHandler(configurator dispatcher.Configurator) (http.Handler, error) {
+	return &configHandler{configurator: configurator}, nil
+}
+
+type configHandler struct {
+	configurator dispatcher.Configurator
+}
+
+func (h *configHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		h.get(w, r)
+	case "PUT", "POST":
+		h.put(w, r)
+	case "DELETE":
+		h.delete(w, r)
+	default:
+		logger := logging.FromContext(r.Context())
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		logger.Debugf(`{"error": "method %v is not allowed"}`, r.Method)
+		_, _ = fmt.Fprintf(w, `{"error": "method %v is not allowed"}`, r.Method)
+	}
+}
+
+func (h *configHandler) get(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	entityID := r.URL.Query().Get("entity")
+	if entityID == "" {
+		writeJSON(w, r, http.StatusOK, h.configurator.EntityConfigs())
+		return
+	}
+	cfg, err := h.configurator.EntityConfig(entityID)
+	if err != nil {
+		if errors.Is(err, dispatcher.ErrEntityConfigNotFound) {
+			respNotFound(w, entityID)
+			return
+		}
+		httputil.RespInternalErrorf(ctx, w, "entity config error: %v", err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, cfg)
+}
+
+func (h *configHandler) put(w http.ResponseWriter, r *http.Request) {
+	var cfg model.Config
+	ctx := r.Context()
+	logger := logging.FromContext(ctx)
+
+	if t := r.Header.Get("content-type"); len(t) < 16 || t[:16] != "application/json" {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		logger.Debug(fmt.Sprintf(`{"error": "%v"}`, "content-type is not application/json"))
+		_, _ = fmt.Fprintf(w, `{"error": "%v"}`, "content-type is not application/json")
+		return
+	}
+
+	defer r.Body.Close()
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+	if err := d.Decode(&cfg); err != nil {
+		httputil.DecodeErr(ctx, w, err)
+		return
+	}
+	if err := cfg.Validate(); err != nil {
+		httputil.RespBadRequestErrorf(ctx, w, `{"error": %q}`, err.Error())
+		return
+	}
+
+	if err := h.configurator.SetEntityConfig(ctx, cfg); err != nil {
+		if errors.Is(err, dispatcher.ErrInvalidEntityConfig) {
+			httputil.RespBadRequestErrorf(ctx, w, `{"error": %q}`, err.Error())
+			return
+		}
+		httputil.RespInternalErrorf(ctx, w, "store entity config error: %v", err)
+		return
+	}
+
+	stored, err := h.configurator.EntityConfig(cfg.EntityID)
+	if err != nil {
+		httputil.RespInternalErrorf(ctx, w, "entity config error: %v", err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, stored)
+}
+
+func (h *configHandler) delete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	entityID := r.URL.Query().Get("entity")
+	if entityID == "" {
+		httputil.RespBadRequestErrorf(ctx, w, `{"error": "entity is not defined"}`)
+		return
+	}
+	if err := h.configurator.DeleteEntityConfig(ctx, entityID); err != nil {
+		if errors.Is(err, dispatcher.ErrEntityConfigNotFound) {
+			respNotFound(w, entityID)
+			return
+		}
+		httputil.RespInternalErrorf(ctx, w, "delete entity config error: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func respNotFound(w http.ResponseWriter, entityID string) {
+	w.WriteHeader(http.StatusNotFound)
+	_, _ = fmt.Fprintf(w, `{"error": "config of entity %s not found"}`, entityID)
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		httputil.RespInternalErrorf(r.Context(), w, "failed to encode output json %v", err)
+		return
+	}
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, "%s", bytes)
+}
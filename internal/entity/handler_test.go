@@ -0,0 +1,165 @@
+package entity
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-sod/sod/internal/dispatcher"
+	"github.com/go-sod/sod/internal/entity/model"
+)
+
+type configurator struct {
+	configs map[string]model.Config
+	err     error
+}
+
+func (c *configurator) EntityConfigs() []model.Config {
+	list := make([]model.Config, 0, len(c.configs))
+	for _, cfg := range c.configs {
+		list = append(list, cfg)
+	}
+	return list
+}
+
+func (c *configurator) EntityConfig(entityID string) (model.Config, error) {
+	cfg, ok := c.configs[entityID]
+	if !ok {
+		return model.Config{}, dispatcher.ErrEntityConfigNotFound
+	}
+	return cfg, nil
+}
+
+func (c *configurator) SetEntityConfig(_ context.Context, cfg model.Config) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.configs[cfg.EntityID] = cfg
+	return nil
+}
+
+func (c *configurator) DeleteEntityConfig(_ context.Context, entityID string) error {
+	if _, ok := c.configs[entityID]; !ok {
+		return dispatcher.ErrEntityConfigNotFound
+	}
+	delete(c.configs, entityID)
+	return nil
+}
+
+func TestConfigHandler(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name           string
+		method         string
+		target         string
+		body           string
+		err            error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "positive_put",
+			method:         http.MethodPut,
+			target:         "/entities/config",
+			body:           `{"entityId": "weather", "kNum": 5, "skipItems": 0, "maxStorageTime": "1h"}`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"entityId":"weather","kNum":5,"skipItems":0,"maxStorageTime":"1h0m0s"`,
+		},
+		{
+			name:           "positive_get",
+			method:         http.MethodGet,
+			target:         "/entities/config?entity=stored",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"entityId":"stored"`,
+		},
+		{
+			name:           "positive_delete",
+			method:         http.MethodDelete,
+			target:         "/entities/config?entity=stored",
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "negative_put_negative_skip_items",
+			method:         http.MethodPut,
+			target:         "/entities/config",
+			body:           `{"entityId": "weather", "skipItems": -1}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "skipItems must not be negative",
+		},
+		{
+			name:           "negative_put_negative_max_items_stored",
+			method:         http.MethodPut,
+			target:         "/entities/config",
+			body:           `{"entityId": "weather", "maxItemsStored": -10}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "maxItemsStored must not be negative",
+		},
+		{
+			name:           "negative_put_negative_max_storage_time",
+			method:         http.MethodPut,
+			target:         "/entities/config",
+			body:           `{"entityId": "weather", "maxStorageTime": "-1h"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "maxStorageTime must not be negative",
+		},
+		{
+			name:           "negative_put_negative_allowed_lateness",
+			method:         http.MethodPut,
+			target:         "/entities/config",
+			body:           `{"entityId": "weather", "allowedLateness": "-5s"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "allowedLateness must not be negative",
+		},
+		{
+			name:           "negative_put_invalid_config",
+			method:         http.MethodPut,
+			target:         "/entities/config",
+			body:           `{"entityId": "weather"}`,
+			err:            dispatcher.ErrInvalidEntityConfig,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "negative_put_store_error",
+			method:         http.MethodPut,
+			target:         "/entities/config",
+			body:           `{"entityId": "weather"}`,
+			err:            errors.New("test-err"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "negative_get_not_found",
+			method:         http.MethodGet,
+			target:         "/entities/config?entity=unknown",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "negative_delete_without_entity",
+			method:         http.MethodDelete,
+			target:         "/entities/config",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			h, _ := NewConfigHandler(&configurator{
+				configs: map[string]model.Config{"stored": {EntityID: "stored"}},
+				err:     test.err,
+			})
+			req := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+			req.Header.Set("content-type", "application/json")
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if w.Code != test.expectedStatus {
+				t.Errorf("status, got: %d (%s), expected: %d", w.Code, w.Body.String(), test.expectedStatus)
+			}
+			if !strings.Contains(w.Body.String(), test.expectedBody) {
+				t.Errorf("body, got: %s, expected to contain: %s", w.Body.String(), test.expectedBody)
+			}
+		})
+	}
+}
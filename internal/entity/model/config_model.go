@@ -0,0 +1,32 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-sod/sod/internal/predictor"
+)
+
+// Config is the detector configuration of the entity overriding the global one
+type Config struct {
+	EntityID string `json:"entityId"`
+	predictor.Settings
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Validate checks the limits of the configuration, the undefined ones keep the global configuration
+func (c Config) Validate() error {
+	if c.SkipItems != nil && *c.SkipItems < 0 {
+		return errors.New("skipItems must not be negative")
+	}
+	if c.MaxItemsStored != nil && *c.MaxItemsStored < 0 {
+		return errors.New("maxItemsStored must not be negative")
+	}
+	if c.MaxStorageTime != nil && c.MaxStorageTime.Duration < 0 {
+		return errors.New("maxStorageTime must not be negative")
+	}
+	if c.AllowedLateness != nil && c.AllowedLateness.Duration < 0 {
+		return errors.New("allowedLateness must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-sod/sod/internal/database"
+	"github.com/go-sod/sod/internal/feedback/model"
+	bolt "go.etcd.io/bbolt"
+)
+
+// prefix is the prefix of the buckets of the labels of the entities, the labels are keyed by the metric id
+const prefix = "feedback:"
+
+func New(db *database.DB) *DB {
+	return &DB{sDB: db}
+}
+
+type DB struct {
+	sDB *database.DB
+}
+
+// Store stores the label, the previous label of the metric is replaced
+func (db *DB) Store(_ context.Context, f model.Feedback) error {
+	bytes, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	if err := db.sDB.DB.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(prefix + f.EntityID))
+		if err != nil {
+			return fmt.Errorf("create bucket: %w", err)
+		}
+		if err := b.Put([]byte(f.MetricID.String()), bytes); err != nil {
+			return fmt.Errorf("put to bucket error: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("update transaction error: %w", err)
+	}
+	return nil
+}
+
+// FindByEntity returns the labels of the metrics of the entity created within [from, to), the zero time is not bounded
+func (db *DB) FindByEntity(entityID string, from, to time.Time) ([]model.Feedback, error) {
+	var list []model.Feedback
+	if err := db.sDB.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(prefix + entityID))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(_, v []byte) error {
+			var f model.Feedback
+			if err := json.Unmarshal(v, &f); err != nil {
+				return fmt.Errorf("feedback unmarshal error, %w", err)
+			}
+			if (!from.IsZero() && f.CreatedAt.Before(from)) || (!to.IsZero() && !f.CreatedAt.Before(to)) {
+				return nil
+			}
+			list = append(list, f)
+			return nil
+		})
+	}); err != nil {
+		return nil, fmt.Errorf("view transaction error: %w", err)
+	}
+	return list, nil
+}
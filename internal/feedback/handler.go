@@ -0,0 +1,134 @@
+package feedback
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-sod/sod/internal/dispatcher"
+	"github.com/go-sod/sod/internal/feedback/model"
+	"github.com/go-sod/sod/internal/httputil"
+	"github.com/go-sod/sod/internal/logging"
+	"github.com/google/uuid"
+)
+
+const maxBodyBytes = 64 * 1024
+
+type request struct {
+	EntityID string      `json:"entity"`
+	MetricID uuid.UUID   `json:"id"`
+	Label    model.Label `json:"label"`
+}
+
+// NewHandler returns the handler of the feedback on the classified metrics
+//
+// POST labels the metric of the body as normal or anomaly,
+// GET ?entity=&from=&to= returns the precision of the entity by the labels of the metrics created within [from, to)
+func NewHandler(labeler dispatcher.Labeler) (http.Handler, error) {
+	return &handler{labeler: labeler}, nil
+}
+
+type handler struct {
+	labeler dispatcher.Labeler
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		h.precision(w, r)
+	case "POST":
+		h.label(w, r)
+	default:
+		logger := logging.FromContext(r.Context())
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		logger.Debugf(`{"error": "method %v is not allowed"}`, r.Method)
+		_, _ = fmt.Fprintf(w, `{"error": "method %v is not allowed"}`, r.Method)
+	}
+}
+
+func (h *handler) label(w http.ResponseWriter, r *http.Request) {
+	var req request
+	ctx := r.Context()
+	logger := logging.FromContext(ctx)
+
+	if t := r.Header.Get("content-type"); len(t) < 16 || t[:16] != "application/json" {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		logger.Debug(fmt.Sprintf(`{"error": "%v"}`, "content-type is not application/json"))
+		_, _ = fmt.Fprintf(w, `{"error": "%v"}`, "content-type is not application/json")
+		return
+	}
+
+	defer r.Body.Close()
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+	if err := d.Decode(&req); err != nil {
+		httputil.DecodeErr(ctx, w, err)
+		return
+	}
+	if req.EntityID == "" || req.MetricID == uuid.Nil {
+		httputil.RespBadRequestErrorf(ctx, w, `{"error": "entity and id are required"}`)
+		return
+	}
+
+	f, err := h.labeler.Label(ctx, req.EntityID, req.MetricID, req.Label)
+	if err != nil {
+		switch {
+		case errors.Is(err, dispatcher.ErrInvalidLabel):
+			httputil.RespBadRequestErrorf(ctx, w, `{"error": %q}`, err.Error())
+		case errors.Is(err, dispatcher.ErrMetricNotFound):
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprintf(w, `{"error": %q}`, err.Error())
+		case errors.Is(err, dispatcher.ErrMetricNotProcessed), errors.Is(err, dispatcher.ErrFeedbackDisabled):
+			w.WriteHeader(http.StatusConflict)
+			_, _ = fmt.Fprintf(w, `{"error": %q}`, err.Error())
+		default:
+			httputil.RespInternalErrorf(ctx, w, "label metric error: %v", err)
+		}
+		return
+	}
+	writeJSON(w, r, http.StatusOK, f)
+}
+
+func (h *handler) precision(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+	entityID := query.Get("entity")
+	if entityID == "" {
+		httputil.RespBadRequestErrorf(ctx, w, `{"error": "entity is not defined"}`)
+		return
+	}
+	var from, to time.Time
+	for name, t := range map[string]*time.Time{"from": &from, "to": &to} {
+		v := query.Get(name)
+		if v == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			httputil.RespBadRequestErrorf(ctx, w, `{"error": "invalid %s: %v"}`, name, err)
+			return
+		}
+		*t = parsed
+	}
+
+	p, err := h.labeler.Precision(entityID, from, to)
+	if err != nil {
+		httputil.RespInternalErrorf(ctx, w, "precision error: %v", err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, p)
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		httputil.RespInternalErrorf(r.Context(), w, "failed to encode output json %v", err)
+		return
+	}
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, "%s", bytes)
+}
@@ -0,0 +1,78 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Label is the verdict of the user on the classified metric
+type Label string
+
+const (
+	// LabelNormal marks the false positive outlier or the confirmed normal metric
+	LabelNormal Label = "normal"
+	// LabelAnomaly marks the true anomaly, detected or missed by the predictor
+	LabelAnomaly Label = "anomaly"
+)
+
+func (l Label) Validate() error {
+	switch l {
+	case LabelNormal, LabelAnomaly:
+		return nil
+	default:
+		return fmt.Errorf("unknown label %q", l)
+	}
+}
+
+// Feedback is the label of the metric with the classification of the predictor
+type Feedback struct {
+	EntityID string    `json:"entityId"`
+	MetricID uuid.UUID `json:"metricId"`
+	Label    Label     `json:"label"`
+	// Outlier and Score are the classification of the metric
+	Outlier   bool      `json:"outlier"`
+	Score     float64   `json:"score"`
+	CreatedAt time.Time `json:"createdAt"`
+	LabeledAt time.Time `json:"labeledAt"`
+}
+
+// Precision is the quality of the classification of the entity by the labeled metrics
+type Precision struct {
+	EntityID       string `json:"entityId"`
+	Labeled        int    `json:"labeled"`
+	TruePositives  int    `json:"truePositives"`
+	FalsePositives int    `json:"falsePositives"`
+	TrueNegatives  int    `json:"trueNegatives"`
+	FalseNegatives int    `json:"falseNegatives"`
+	// Precision and Recall are omitted without the labeled outliers and the labeled anomalies
+	Precision *float64 `json:"precision,omitempty"`
+	Recall    *float64 `json:"recall,omitempty"`
+}
+
+// NewPrecision counts the labels of the entity
+func NewPrecision(entityID string, labels []Feedback) Precision {
+	p := Precision{EntityID: entityID, Labeled: len(labels)}
+	for _, f := range labels {
+		switch {
+		case f.Outlier && f.Label == LabelAnomaly:
+			p.TruePositives++
+		case f.Outlier && f.Label == LabelNormal:
+			p.FalsePositives++
+		case !f.Outlier && f.Label == LabelNormal:
+			p.TrueNegatives++
+		default:
+			p.FalseNegatives++
+		}
+	}
+	if n := p.TruePositives + p.FalsePositives; n > 0 {
+		v := float64(p.TruePositives) / float64(n)
+		p.Precision = &v
+	}
+	if n := p.TruePositives + p.FalseNegatives; n > 0 {
+		v := float64(p.TruePositives) / float64(n)
+		p.Recall = &v
+	}
+	return p
+}
@@ -14,7 +14,8 @@ func EuclideanDistance(p, p1 []float64) (float64, error) {
 	}
 
 	for i := 0; i < len(p); i++ {
-		d += math.Pow(p[i]-p1[i], 2)
+		diff := p[i] - p1[i]
+		d += diff * diff
 	}
 	return math.Sqrt(d), nil
 }
@@ -46,3 +46,18 @@ func RespInternalErrorf(ctx context.Context, w http.ResponseWriter, format strin
 	logging.FromContext(ctx).Errorf(format, args...)
 	http.Error(w, "Internal error", http.StatusInternalServerError)
 }
+
+// RespTooManyRequestsErrorf asks the client to retry the request later
+func RespTooManyRequestsErrorf(ctx context.Context, w http.ResponseWriter, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	logging.FromContext(ctx).Debug(msg)
+	w.Header().Set("Retry-After", "1")
+	http.Error(w, msg, http.StatusTooManyRequests)
+}
+
+// RespServiceUnavailableErrorf reports the service unable to accept the request, e.g. while shutting down
+func RespServiceUnavailableErrorf(ctx context.Context, w http.ResponseWriter, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	logging.FromContext(ctx).Warn(msg)
+	http.Error(w, msg, http.StatusServiceUnavailable)
+}
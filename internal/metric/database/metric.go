@@ -2,18 +2,27 @@ package database
 
 import (
 	"context"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/go-sod/sod/internal/database"
 	"github.com/go-sod/sod/internal/metric/model"
+	"github.com/google/uuid"
 	bolt "go.etcd.io/bbolt"
 )
 
 const (
 	entityKeys = "entity:keys:"
 	prefix     = "metric:"
+	// latePrefix is the prefix of the side buckets of the metrics older than the watermark
+	latePrefix = "late:"
+	// dedupPrefix is the prefix of the buckets of the dedup keys of the entities,
+	// the keys are kept after the metrics are deleted and are removed by the retention of the entity
+	dedupPrefix = "dedup:"
 )
 
 type FilterFn func(metric model.Metric) bool
@@ -77,7 +86,7 @@ func (db *DB) Store(_ context.Context, metric model.Metric) error {
 		if err := b.Put([]byte(prefix+metric.EntityID), []byte{0x0}); err != nil {
 			return fmt.Errorf("unable put to entityies bucket: %w", err)
 		}
-		return nil
+		return putKey(tx, metric)
 	}); err != nil {
 		return fmt.Errorf("update transaction error: %w", err)
 	}
@@ -104,15 +113,15 @@ func (db *DB) AppendMany(_ context.Context, metrics []model.Metric) error {
 			if err := b.Put([]byte(metric.ID.String()), bytes); err != nil {
 				return fmt.Errorf("put to bucket error: %w", err)
 			}
-			b = tx.Bucket([]byte(entityKeys))
-			if b == nil {
-				keysBucket, err := tx.CreateBucket([]byte(entityKeys))
-				if err != nil {
-					return fmt.Errorf("unable create entityies bucket: %w", err)
-				}
-				if err := keysBucket.Put([]byte(prefix+metric.EntityID), []byte{0x0}); err != nil {
-					return fmt.Errorf("unable put to entityies bucket: %w", err)
-				}
+			keysBucket, err := tx.CreateBucketIfNotExists([]byte(entityKeys))
+			if err != nil {
+				return fmt.Errorf("unable create entityies bucket: %w", err)
+			}
+			if err := keysBucket.Put([]byte(prefix+metric.EntityID), []byte{0x0}); err != nil {
+				return fmt.Errorf("unable put to entityies bucket: %w", err)
+			}
+			if err := putKey(tx, metric); err != nil {
+				return err
 			}
 		}
 		return nil
@@ -239,6 +248,168 @@ func (db *DB) CountByEntity(entityID string) (int, error) {
 	return length, nil
 }
 
+// AppendLate stores the metrics older than the watermark to the late buckets of the entities
+func (db *DB) AppendLate(_ context.Context, metrics []model.Metric) error {
+	if err := db.sDB.DB.Batch(func(tx *bolt.Tx) error {
+		for _, metric := range metrics {
+			b, err := tx.CreateBucketIfNotExists([]byte(latePrefix + metric.EntityID))
+			if err != nil {
+				return fmt.Errorf("create bucket: %w", err)
+			}
+			bytes, err := json.Marshal(metric)
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte(metric.ID.String()), bytes); err != nil {
+				return fmt.Errorf("put to bucket error: %w", err)
+			}
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("update transaction error: %w", err)
+	}
+
+	return nil
+}
+
+// FindByID returns the stored metric of the entity, false when it is not stored
+func (db *DB) FindByID(entityID string, id uuid.UUID) (model.Metric, bool, error) {
+	var (
+		metric model.Metric
+		found  bool
+	)
+	if err := db.sDB.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(prefix + entityID))
+		if b == nil {
+			return nil
+		}
+		v := b.Get([]byte(id.String()))
+		if v == nil {
+			return nil
+		}
+		found = true
+		if err := json.Unmarshal(v, &metric); err != nil {
+			return fmt.Errorf("json unmarshal error, %w", err)
+		}
+		return nil
+	}); err != nil {
+		return model.Metric{}, false, fmt.Errorf("view transaction error: %w", err)
+	}
+
+	return metric, found, nil
+}
+
+// AppendKeys stores the dedup keys of the keyed metrics without the metrics,
+// the entities are registered for the retention of the keys
+func (db *DB) AppendKeys(_ context.Context, metrics []model.Metric) error {
+	if err := db.sDB.DB.Batch(func(tx *bolt.Tx) error {
+		for _, metric := range metrics {
+			if metric.Key == "" {
+				continue
+			}
+			keysBucket, err := tx.CreateBucketIfNotExists([]byte(entityKeys))
+			if err != nil {
+				return fmt.Errorf("unable create entityies bucket: %w", err)
+			}
+			if err := keysBucket.Put([]byte(prefix+metric.EntityID), []byte{0x0}); err != nil {
+				return fmt.Errorf("unable put to entityies bucket: %w", err)
+			}
+			if err := putKey(tx, metric); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("update transaction error: %w", err)
+	}
+
+	return nil
+}
+
+// putKey stores the dedup key of the keyed metric by the id of the metric with the creation time
+func putKey(tx *bolt.Tx, metric model.Metric) error {
+	if metric.Key == "" {
+		return nil
+	}
+	b, err := tx.CreateBucketIfNotExists([]byte(dedupPrefix + metric.EntityID))
+	if err != nil {
+		return fmt.Errorf("create dedup bucket: %w", err)
+	}
+	createdAt := make([]byte, 8)
+	binary.BigEndian.PutUint64(createdAt, uint64(metric.CreatedAt.UnixNano()))
+	if err := b.Put([]byte(metric.ID.String()), createdAt); err != nil {
+		return fmt.Errorf("put to dedup bucket error: %w", err)
+	}
+	return nil
+}
+
+// ExistKeys returns the ids of the keyed metrics of the entity stored among the passed ones,
+// the keys of the metrics deleted after the classification are found too
+func (db *DB) ExistKeys(entityID string, ids []uuid.UUID) (map[uuid.UUID]bool, error) {
+	exist := map[uuid.UUID]bool{}
+	if err := db.sDB.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(dedupPrefix + entityID))
+		if b == nil {
+			return nil
+		}
+		for _, id := range ids {
+			if b.Get([]byte(id.String())) != nil {
+				exist[id] = true
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("view transaction error: %w", err)
+	}
+
+	return exist, nil
+}
+
+// TrimKeys deletes the dedup keys of the entity created before maxStorageTime and the oldest keys over maxItemsStored,
+// the zero limits are not applied, returns the number of the deleted keys
+func (db *DB) TrimKeys(entityID string, maxItemsStored int, maxStorageTime time.Duration) (int, error) {
+	var deleted int
+	if err := db.sDB.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(dedupPrefix + entityID))
+		if b == nil {
+			return nil
+		}
+		type key struct {
+			id        []byte
+			createdAt int64
+		}
+		var keys []key
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			keys = append(keys, key{id: append([]byte(nil), k...), createdAt: int64(binary.BigEndian.Uint64(v))})
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].createdAt < keys[j].createdAt
+		})
+		var outdated int
+		if maxStorageTime > 0 {
+			before := time.Now().Add(-maxStorageTime).UnixNano()
+			outdated = sort.Search(len(keys), func(i int) bool {
+				return keys[i].createdAt >= before
+			})
+		}
+		if maxItemsStored > 0 && len(keys)-outdated > maxItemsStored {
+			outdated = len(keys) - maxItemsStored
+		}
+		for _, k := range keys[:outdated] {
+			if err := b.Delete(k.id); err != nil {
+				return fmt.Errorf("unable delete: %w", err)
+			}
+		}
+		deleted = outdated
+		return nil
+	}); err != nil {
+		return 0, fmt.Errorf("update transaction error: %w", err)
+	}
+
+	return deleted, nil
+}
+
 func (db *DB) FindByEntity(entityID string, filter FilterFn) ([]model.Metric, error) {
 	var list []model.Metric
 	if err := db.sDB.DB.View(func(tx *bolt.Tx) error {
@@ -1,6 +1,11 @@
 package model
 
 import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"strconv"
 	"time"
 
 	"github.com/go-sod/sod/internal/geom"
@@ -27,23 +32,57 @@ func NewMetric(entityID string, vec geom.Point, createdAt time.Time, extra inter
 	}
 }
 
+// keyNamespace is the namespace of the metric ids derived from the dedup keys
+var keyNamespace = uuid.MustParse("5d0c6a42-9b1e-4f7e-8c3a-2f1b7e6d9a10")
+
+// WithKey returns the metric with the dedup key, the id of the metric is derived from the entity and the key,
+// so the retried metric gets the same id
+func (m Metric) WithKey(key string) Metric {
+	m.Key = key
+	m.ID = uuid.NewSHA1(keyNamespace, []byte(m.EntityID+"\x00"+key))
+	return m
+}
+
+// ContentKey returns the dedup key built from the creation time and the hash of the vector
+func (m Metric) ContentKey() string {
+	h := sha256.New()
+	var b [8]byte
+	for _, v := range m.CheckedVec {
+		binary.BigEndian.PutUint64(b[:], math.Float64bits(v))
+		_, _ = h.Write(b[:])
+	}
+	return strconv.FormatInt(m.CreatedAt.UnixNano(), 10) + ":" + hex.EncodeToString(h.Sum(nil)[:16])
+}
+
 var _ predictor.DataPoint = (*Metric)(nil)
 
 type Metric struct {
 	ID         uuid.UUID   `json:"id"`
+	Key        string      `json:"key,omitempty"`
 	EntityID   string      `json:"entityId"`
 	NormVec    geom.Point  `json:"normVec"`
 	CheckedVec geom.Point  `json:"checkedVec"`
 	Outlier    bool        `json:"outlier"`
+	Score      float64     `json:"score"`
+	Threshold  float64     `json:"threshold"`
+	Confidence float64     `json:"confidence"`
 	Status     Status      `json:"status"`
 	CreatedAt  time.Time   `json:"createdAt"`
 	Extra      interface{} `json:"extra"`
+	// Excluded marks the processed metric kept out of the dataset of the predictor,
+	// the outlier without allowAppendOutlier or the metric labeled anomaly
+	Excluded bool `json:"excluded,omitempty"`
 }
 
 func (m Metric) IsProcessed() bool {
 	return m.Status == StatusProcessed
 }
 
+// InDataset returns true for the processed metric loaded to the predictor
+func (m Metric) InDataset() bool {
+	return m.IsProcessed() && !m.Excluded
+}
+
 func (m Metric) IsNew() bool {
 	return m.Status == StatusNew
 }
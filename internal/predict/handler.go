@@ -43,10 +43,13 @@ type request struct {
 type response struct {
 	EntityID string `json:"entity"`
 	Data     []struct {
-		Outlier   bool        `json:"outlier"`
-		Vec       []float64   `json:"vector"`
-		Extra     interface{} `json:"extra"`
-		CreatedAt time.Time   `json:"createdAt"`
+		Outlier    bool        `json:"outlier"`
+		Score      float64     `json:"score"`
+		Threshold  float64     `json:"threshold"`
+		Confidence float64     `json:"confidence"`
+		Vec        []float64   `json:"vector"`
+		Extra      interface{} `json:"extra"`
+		CreatedAt  time.Time   `json:"createdAt"`
 	} `json:"data"`
 }
 
@@ -101,10 +104,13 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var respData []struct {
-		Outlier   bool        `json:"outlier"`
-		Vec       []float64   `json:"vector"`
-		Extra     interface{} `json:"extra"`
-		CreatedAt time.Time   `json:"createdAt"`
+		Outlier    bool        `json:"outlier"`
+		Score      float64     `json:"score"`
+		Threshold  float64     `json:"threshold"`
+		Confidence float64     `json:"confidence"`
+		Vec        []float64   `json:"vector"`
+		Extra      interface{} `json:"extra"`
+		CreatedAt  time.Time   `json:"createdAt"`
 	}
 	errGrp := errgroup.Group{}
 	mtx := sync.Mutex{}
@@ -121,11 +127,22 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			}
 			mtx.Lock()
 			respData = append(respData, struct {
-				Outlier   bool        `json:"outlier"`
-				Vec       []float64   `json:"vector"`
-				Extra     interface{} `json:"extra"`
-				CreatedAt time.Time   `json:"createdAt"`
-			}{Outlier: result.Outlier, Vec: point.Point().Points(), Extra: dat.Extra, CreatedAt: dat.CreatedAt})
+				Outlier    bool        `json:"outlier"`
+				Score      float64     `json:"score"`
+				Threshold  float64     `json:"threshold"`
+				Confidence float64     `json:"confidence"`
+				Vec        []float64   `json:"vector"`
+				Extra      interface{} `json:"extra"`
+				CreatedAt  time.Time   `json:"createdAt"`
+			}{
+				Outlier:    result.Outlier,
+				Score:      result.Score,
+				Threshold:  result.Threshold,
+				Confidence: result.Confidence,
+				Vec:        point.Point().Points(),
+				Extra:      dat.Extra,
+				CreatedAt:  dat.CreatedAt,
+			})
 			mtx.Unlock()
 			return nil
 		})
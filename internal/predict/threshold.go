@@ -0,0 +1,63 @@
+package predict
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-sod/sod/internal/dispatcher"
+	"github.com/go-sod/sod/internal/httputil"
+	"github.com/go-sod/sod/internal/logging"
+)
+
+type thresholdResponse struct {
+	EntityID  string  `json:"entity"`
+	Threshold float64 `json:"threshold"`
+}
+
+// NewThresholdHandler returns the handler reporting the decision threshold used for the entity
+func NewThresholdHandler(outlier dispatcher.Predictor) (http.Handler, error) {
+	return &thresholdHandler{outlier: outlier}, nil
+}
+
+type thresholdHandler struct {
+	outlier dispatcher.Predictor
+}
+
+func (h *thresholdHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := logging.FromContext(ctx)
+
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		logger.Debugf(`{"error": "method %v is not allowed"}`, r.Method)
+		_, _ = fmt.Fprintf(w, `{"error": "method %v is not allowed"}`, r.Method)
+		return
+	}
+
+	entityID := r.URL.Query().Get("entity")
+	if entityID == "" {
+		httputil.RespBadRequestErrorf(ctx, w, `{"error": "entity is not defined"}`)
+		return
+	}
+
+	threshold, err := h.outlier.Threshold(entityID)
+	if err != nil {
+		if errors.Is(err, dispatcher.ErrPredictorNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprintf(w, `{"error": "entity %s not found"}`, entityID)
+			return
+		}
+		httputil.RespInternalErrorf(ctx, w, "threshold error: %v", err)
+		return
+	}
+
+	bytes, err := json.Marshal(thresholdResponse{EntityID: entityID, Threshold: threshold})
+	if err != nil {
+		httputil.RespInternalErrorf(ctx, w, "failed to encode output json %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = fmt.Fprintf(w, "%s", bytes)
+}
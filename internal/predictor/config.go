@@ -1,5 +1,7 @@
 package predictor
 
+import "github.com/go-sod/sod/internal/timeutil"
+
 type AlgType string
 
 const (
@@ -18,3 +20,18 @@ func (c Config) PredictorType() AlgType {
 func (c Config) PredictorConfig() Config {
 	return c
 }
+
+// Settings overrides the global predictor configuration for the entity, nil fields keep the global values
+type Settings struct {
+	KNum           *int               `json:"kNum,omitempty"`
+	DistanceFunc   *string            `json:"distanceFunc,omitempty"`
+	AlgType        *string            `json:"algType,omitempty"`
+	ThresholdType  *string            `json:"thresholdType,omitempty"`
+	Threshold      *float64           `json:"threshold,omitempty"`
+	Contamination  *float64           `json:"contamination,omitempty"`
+	SkipItems      *int               `json:"skipItems,omitempty"`
+	MaxItemsStored *int               `json:"maxItemsStored,omitempty"`
+	MaxStorageTime *timeutil.Duration `json:"maxStorageTime,omitempty"`
+	// AllowedLateness is the time the metrics are reordered within, the older metrics are late
+	AllowedLateness *timeutil.Duration `json:"allowedLateness,omitempty"`
+}
@@ -0,0 +1,16 @@
+package iforest
+
+const (
+	MinTreesNum      = 1
+	MinSubsampleSize = 2
+	MaxContamination = 0.5
+	// anomaly score delimiter proposed in the isolation forest white paper
+	DefaultThreshold = 0.5
+)
+
+type Config struct {
+	SkipItems     int     `envconfig:"SKIP_ITEMS"`
+	TreesNum      int     `envconfig:"IFOREST_TREES_NUM" default:"100"`
+	SubsampleSize int     `envconfig:"IFOREST_SUBSAMPLE_SIZE" default:"256"`
+	Contamination float64 `envconfig:"IFOREST_CONTAMINATION" default:"0"`
+}
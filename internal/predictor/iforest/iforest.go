@@ -0,0 +1,389 @@
+package iforest
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-sod/sod/internal/geom"
+	"github.com/go-sod/sod/internal/predictor"
+	"github.com/go-sod/sod/internal/predictor/knn/avlnode"
+	"github.com/go-sod/sod/pkg/avltree"
+)
+
+var (
+	_ predictor.Predictor   = (*forest)(nil)
+	_ predictor.Thresholder = (*forest)(nil)
+)
+
+type Option func(*forest)
+
+func WithSkipItems(n int) Option {
+	return func(f *forest) {
+		f.opts.skipItems = n
+	}
+}
+
+func WithMaxItems(n int) Option {
+	return func(f *forest) {
+		f.opts.maxItemsStored = n
+	}
+}
+
+func WithStorageTime(t time.Duration) Option {
+	return func(f *forest) {
+		f.opts.maxStorageTime = t
+	}
+}
+
+func WithTreesNum(n int) Option {
+	return func(f *forest) {
+		f.opts.treesNum = n
+	}
+}
+
+func WithSubsampleSize(n int) Option {
+	return func(f *forest) {
+		f.opts.subsampleSize = n
+	}
+}
+
+func WithContamination(c float64) Option {
+	return func(f *forest) {
+		f.opts.contamination = c
+	}
+}
+
+func WithSeed(seed int64) Option {
+	return func(f *forest) {
+		f.rnd = rand.New(rand.NewSource(seed)) // nolint:gosec
+	}
+}
+
+var defaultOptions = Options{treesNum: 100, subsampleSize: 256}
+
+type Options struct {
+	skipItems      int
+	maxItemsStored int
+	maxStorageTime time.Duration
+	treesNum       int
+	subsampleSize  int
+	contamination  float64
+}
+
+const (
+	rebuildOutdatedTime = 60 * time.Second
+	rebuildSizeTime     = 5 * time.Second
+	rebuildForestTime   = 10 * time.Second
+	// share of the changed dataset after which the forest is rebuilt before the prediction
+	rebuildForestRatio = 0.1
+	// the number of the dataset points scored for the contamination threshold
+	maxThresholdPoints = 4096
+)
+
+func New(opts ...Option) (*forest, error) {
+	f := &forest{
+		opts: defaultOptions,
+		data: avltree.New(),
+		rnd:  rand.New(rand.NewSource(time.Now().UnixNano())), // nolint:gosec
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	if f.opts.treesNum < MinTreesNum {
+		return nil, fmt.Errorf("unable creating isolation forest instance, trees num %d is too small", f.opts.treesNum)
+	}
+	if f.opts.subsampleSize < MinSubsampleSize {
+		return nil, fmt.Errorf(
+			"unable creating isolation forest instance, subsample size %d is too small", f.opts.subsampleSize,
+		)
+	}
+	if f.opts.contamination < 0 || f.opts.contamination > MaxContamination {
+		return nil, fmt.Errorf(
+			"unable creating isolation forest instance, contamination %v out of range [0, %v]",
+			f.opts.contamination,
+			MaxContamination,
+		)
+	}
+	f.threshold = DefaultThreshold
+	ctx, cancel := context.WithCancel(context.Background())
+	f.cancel = cancel
+	go f.schedule(ctx)
+	return f, nil
+}
+
+type forest struct {
+	mtx sync.RWMutex
+
+	opts Options
+	rnd  *rand.Rand
+	// time index of the stored dataset
+	data *avltree.Tree
+	// identifier of the last point in the time index
+	nextID uint64
+	// dimension of the points of the dataset, the points of other dimensions are skipped
+	dims int
+	// trees built from the subsamples of the dataset
+	trees []*tree
+	// the number of dataset items the trees were built on
+	builtLen int
+	// the number of added or removed items after the last build
+	changesCnt int
+	// anomaly score above which the point is an outlier
+	threshold       float64
+	rebuildOutdated time.Time
+	cancel          func()
+}
+
+func (f *forest) Close() {
+	f.cancel()
+}
+
+func (f *forest) Reset() {
+	f.mtx.Lock()
+	f.data = avltree.New()
+	f.dims = 0
+	f.trees = nil
+	f.builtLen = 0
+	f.changesCnt = 0
+	f.threshold = DefaultThreshold
+	f.mtx.Unlock()
+}
+
+func (f *forest) Len() int {
+	f.mtx.RLock()
+	defer f.mtx.RUnlock()
+	return f.data.Len()
+}
+
+func (f *forest) Build(data ...predictor.DataPoint) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.add(data...)
+	f.build()
+}
+
+func (f *forest) Append(data ...predictor.DataPoint) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.changesCnt += f.add(data...)
+}
+
+// add stores the points of the dataset dimension in the time index, returns the number of the added points,
+// must be called under the lock
+func (f *forest) add(data ...predictor.DataPoint) int {
+	var added int
+	for i := range data {
+		dims := data[i].Point().Dimensions()
+		if f.data.Len() == 0 {
+			f.dims = dims
+		}
+		if dims != f.dims {
+			continue
+		}
+		f.nextID++
+		f.data.Add(avlnode.TimeNode{
+			K:  data[i].Time(),
+			V:  data[i],
+			ID: f.nextID,
+		})
+		added++
+	}
+	return added
+}
+
+func (f *forest) Predict(vec predictor.Point) (*predictor.Conclusion, error) {
+	length := f.Len()
+	if length == 0 {
+		return nil, fmt.Errorf("unable to predict, test vec size 0")
+	}
+	if length < f.opts.skipItems {
+		return nil, fmt.Errorf("unable to predict, test vec less skip items param")
+	}
+	if !f.matches(vec) {
+		return nil, fmt.Errorf("unable to predict %v, %w", vec, geom.ErrDimNotEqual)
+	}
+	if f.needBuild() {
+		f.mtx.Lock()
+		f.build()
+		f.mtx.Unlock()
+	}
+	return predictor.NewConclusion(f.score(vec)), nil
+}
+
+// Threshold returns the anomaly score above which the point is an outlier
+func (f *forest) Threshold() float64 {
+	f.mtx.RLock()
+	defer f.mtx.RUnlock()
+	return f.threshold
+}
+
+// Score returns the anomaly score of the point in the range (0, 1]
+func (f *forest) Score(vec predictor.Point) float64 {
+	score, _ := f.score(vec)
+	return score
+}
+
+func (f *forest) score(vec predictor.Point) (float64, float64) {
+	f.mtx.RLock()
+	defer f.mtx.RUnlock()
+	return f.anomalyScore(vec), f.threshold
+}
+
+func (f *forest) anomalyScore(vec predictor.Point) float64 {
+	if len(f.trees) == 0 {
+		return 0
+	}
+	var pathSum float64
+	for _, t := range f.trees {
+		pathSum += t.pathLength(vec)
+	}
+	norm := averagePathLength(f.subsampleLen())
+	if norm == 0 {
+		return 0
+	}
+	return math.Pow(2, -(pathSum/float64(len(f.trees)))/norm)
+}
+
+func (f *forest) subsampleLen() int {
+	if f.builtLen < f.opts.subsampleSize {
+		return f.builtLen
+	}
+	return f.opts.subsampleSize
+}
+
+func (f *forest) matches(vec predictor.Point) bool {
+	f.mtx.RLock()
+	defer f.mtx.RUnlock()
+	return vec.Dimensions() == f.dims
+}
+
+func (f *forest) needBuild() bool {
+	f.mtx.RLock()
+	defer f.mtx.RUnlock()
+	return len(f.trees) == 0 || float64(f.changesCnt) > float64(f.builtLen)*rebuildForestRatio
+}
+
+// build creates new trees from random subsamples of the dataset, must be called under the lock
+func (f *forest) build() {
+	items := f.data.Points()
+	points := make([]predictor.Point, len(items))
+	for i := range items {
+		points[i] = items[i].(avlnode.TimeNode).V.Point()
+	}
+	f.builtLen = len(points)
+	f.changesCnt = 0
+	if len(points) == 0 {
+		f.trees = nil
+		return
+	}
+
+	sampleLen := f.subsampleLen()
+	heightLimit := int(math.Ceil(math.Log2(float64(sampleLen))))
+	trees := make([]*tree, f.opts.treesNum)
+	for i := range trees {
+		trees[i] = buildTree(f.rnd, f.sample(points, sampleLen), heightLimit)
+	}
+	f.trees = trees
+	f.threshold = f.contaminationThreshold(points)
+}
+
+// sample returns n distinct random points by the Floyd's algorithm, the dataset is not permuted as a whole
+func (f *forest) sample(points []predictor.Point, n int) []predictor.Point {
+	sample := make([]predictor.Point, 0, n)
+	seen := make(map[int]struct{}, n)
+	for i := len(points) - n; i < len(points); i++ {
+		idx := f.rnd.Intn(i + 1)
+		if _, ok := seen[idx]; ok {
+			idx = i
+		}
+		seen[idx] = struct{}{}
+		sample = append(sample, points[idx])
+	}
+	return sample
+}
+
+// contaminationThreshold returns the score quantile matching the expected share of outliers in the dataset,
+// the large dataset is estimated by the random sample of the points
+func (f *forest) contaminationThreshold(points []predictor.Point) float64 {
+	if f.opts.contamination == 0 {
+		return DefaultThreshold
+	}
+	if len(points) > maxThresholdPoints {
+		points = f.sample(points, maxThresholdPoints)
+	}
+	scores := make([]float64, len(points))
+	for i := range points {
+		scores[i] = f.anomalyScore(points[i])
+	}
+	sort.Float64s(scores)
+	idx := int(math.Floor(float64(len(scores)) * (1 - f.opts.contamination)))
+	if idx >= len(scores) {
+		idx = len(scores) - 1
+	}
+	return scores[idx]
+}
+
+func (f *forest) rebuildForest() {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	if f.changesCnt > 0 {
+		f.build()
+	}
+}
+
+func (f *forest) removeOutdated() {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	list := f.data.Filter(func(current avltree.Item) bool {
+		return time.Since(current.(avlnode.TimeNode).K) > f.opts.maxStorageTime
+	})
+	for i := range list {
+		f.data.Remove(list[i])
+		f.changesCnt++
+	}
+	f.rebuildOutdated = time.Now()
+}
+
+func (f *forest) removeOverSize() {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	sub := f.data.Len() - f.opts.maxItemsStored
+	if sub <= 0 {
+		return
+	}
+	list := f.data.Points()
+	for _, timeNode := range list[:sub] {
+		f.data.Remove(timeNode)
+		f.changesCnt++
+	}
+}
+
+func (f *forest) schedule(ctx context.Context) {
+	outdatedTicker := time.NewTicker(rebuildOutdatedTime)
+	sizeTicker := time.NewTicker(rebuildSizeTime)
+	forestTicker := time.NewTicker(rebuildForestTime)
+	defer outdatedTicker.Stop()
+	defer sizeTicker.Stop()
+	defer forestTicker.Stop()
+	for {
+		select {
+		case <-outdatedTicker.C:
+			if f.opts.maxStorageTime > 0 && time.Since(f.rebuildOutdated) > f.opts.maxStorageTime {
+				f.removeOutdated()
+			}
+		case <-sizeTicker.C:
+			if f.opts.maxItemsStored > 0 {
+				f.removeOverSize()
+			}
+		case <-forestTicker.C:
+			f.rebuildForest()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
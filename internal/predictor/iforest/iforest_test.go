@@ -0,0 +1,169 @@
+package iforest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-sod/sod/internal/geom"
+	"github.com/go-sod/sod/internal/predictor"
+	"github.com/go-sod/sod/internal/predictor/knn/avlnode"
+	"github.com/go-sod/sod/internal/predictor/predictortest"
+)
+
+func TestForest_Predict(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name          string
+		contamination float64
+		vec           geom.Point
+		expected      bool
+	}{
+		{
+			name:     "positive_outlier",
+			vec:      geom.Point{100, -50},
+			expected: true,
+		},
+		{
+			name:     "positive_normal",
+			vec:      geom.Point{10.2, 20.3},
+			expected: false,
+		},
+		{
+			name:          "positive_contamination_outlier",
+			contamination: 0.01,
+			vec:           geom.Point{100, -50},
+			expected:      true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			f, err := New(WithSeed(1), WithContamination(test.contamination), WithSubsampleSize(64))
+			if err != nil {
+				t.Fatalf("unable create forest: %v", err)
+			}
+			defer f.Close()
+			f.Build(predictortest.Cluster(500, geom.Point{10, 20}, 0.5)...)
+			conclusion, err := f.Predict(test.vec)
+			if err != nil {
+				t.Fatalf("compute Predict, got error: %v", err)
+			}
+			if conclusion.Outlier != test.expected {
+				t.Errorf("compute Predict, got: %v, expected: %v", conclusion.Outlier, test.expected)
+			}
+		})
+	}
+}
+
+func TestForest_Len(t *testing.T) {
+	t.Parallel()
+	f, err := New(WithSeed(1))
+	if err != nil {
+		t.Fatalf("unable create forest: %v", err)
+	}
+	defer f.Close()
+	f.Build(predictortest.Cluster(10, geom.Point{10, 20}, 0.5)...)
+	f.Append(predictortest.Cluster(5, geom.Point{10, 20}, 0.5)...)
+	if f.Len() != 15 {
+		t.Errorf("compute Len, got: %v, expected: %v", f.Len(), 15)
+	}
+	f.Reset()
+	if f.Len() != 0 {
+		t.Errorf("compute Len after Reset, got: %v, expected: %v", f.Len(), 0)
+	}
+	if _, err := f.Predict(geom.Point{1, 1}); err == nil {
+		t.Errorf("compute Predict on empty dataset, got: nil, expected: error")
+	}
+}
+
+func TestForest_MixedDimensions(t *testing.T) {
+	t.Parallel()
+	f, err := New(WithSeed(1))
+	if err != nil {
+		t.Fatalf("unable create forest: %v", err)
+	}
+	defer f.Close()
+	data := predictortest.Cluster(10, geom.Point{10, 20}, 0.5)
+	data = append(data, predictortest.DataPoint{Vec: geom.Point{1}, CreatedAt: time.Now()})
+	f.Build(data...)
+	f.Append(predictortest.DataPoint{Vec: geom.Point{1, 2, 3}, CreatedAt: time.Now()})
+	if f.Len() != 10 {
+		t.Errorf("compute Len, got: %v, expected: %v", f.Len(), 10)
+	}
+	if _, err := f.Predict(geom.Point{10, 20}); err != nil {
+		t.Errorf("compute Predict, got error: %v", err)
+	}
+	if _, err := f.Predict(geom.Point{1}); !errors.Is(err, geom.ErrDimNotEqual) {
+		t.Errorf("compute Predict of other dimension, got: %v, expected: %v", err, geom.ErrDimNotEqual)
+	}
+}
+
+func TestForest_SameTime(t *testing.T) {
+	t.Parallel()
+	f, err := New(WithSeed(1), WithMaxItems(2))
+	if err != nil {
+		t.Fatalf("unable create forest: %v", err)
+	}
+	defer f.Close()
+	createdAt := time.Now()
+	for i := 1; i <= 5; i++ {
+		f.Append(predictortest.DataPoint{Vec: geom.Point{float64(i)}, CreatedAt: createdAt})
+	}
+	// the oldest points by the order of adding are removed
+	f.removeOverSize()
+	items := f.data.Points()
+	if len(items) != 2 {
+		t.Fatalf("compute Len, got: %v, expected: %v", len(items), 2)
+	}
+	for i, item := range items {
+		if v := item.(avlnode.TimeNode).V.Point().Dim(0); v != float64(i+4) {
+			t.Errorf("point %d, got: %v, expected: %v", i, v, i+4)
+		}
+	}
+}
+
+func TestForest_Sample(t *testing.T) {
+	t.Parallel()
+	f, err := New(WithSeed(1))
+	if err != nil {
+		t.Fatalf("unable create forest: %v", err)
+	}
+	defer f.Close()
+	points := make([]predictor.Point, 100)
+	for i := range points {
+		points[i] = geom.Point{float64(i)}
+	}
+	for _, n := range []int{1, 10, 100} {
+		sample := f.sample(points, n)
+		seen := map[float64]struct{}{}
+		for _, p := range sample {
+			seen[p.(geom.Point)[0]] = struct{}{}
+		}
+		if len(sample) != n || len(seen) != n {
+			t.Errorf("sample of %d, got: %d points, %d distinct", n, len(sample), len(seen))
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "negative_trees_num", opts: []Option{WithTreesNum(0)}},
+		{name: "negative_subsample_size", opts: []Option{WithSubsampleSize(1)}},
+		{name: "negative_contamination", opts: []Option{WithContamination(0.6)}},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := New(test.opts...); err == nil {
+				t.Errorf("calling New, got: nil, expected: error")
+			}
+		})
+	}
+}
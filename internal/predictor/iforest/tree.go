@@ -0,0 +1,106 @@
+package iforest
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/go-sod/sod/internal/predictor"
+)
+
+// Euler–Mascheroni constant
+const eulerGamma = 0.5772156649
+
+// averagePathLength returns the average path length of unsuccessful search in a binary search tree of n items
+func averagePathLength(n int) float64 {
+	switch {
+	case n <= 1:
+		return 0
+	case n == 2:
+		return 1
+	default:
+		return 2*(math.Log(float64(n-1))+eulerGamma) - 2*float64(n-1)/float64(n)
+	}
+}
+
+type node struct {
+	left       *node
+	right      *node
+	splitDim   int
+	splitValue float64
+	size       int
+}
+
+func (n *node) external() bool {
+	return n.left == nil && n.right == nil
+}
+
+type tree struct {
+	root *node
+}
+
+func buildTree(rnd *rand.Rand, points []predictor.Point, heightLimit int) *tree {
+	return &tree{root: buildNodeRecursive(rnd, points, 0, heightLimit)}
+}
+
+func buildNodeRecursive(rnd *rand.Rand, points []predictor.Point, height, heightLimit int) *node {
+	if height >= heightLimit || len(points) <= 1 {
+		return &node{size: len(points)}
+	}
+
+	// only dimensions with a non-zero range can split the sample
+	dims := make([]int, 0, points[0].Dimensions())
+	mins := make([]float64, points[0].Dimensions())
+	maxs := make([]float64, points[0].Dimensions())
+	for dim := 0; dim < points[0].Dimensions(); dim++ {
+		mins[dim], maxs[dim] = math.MaxFloat64, -math.MaxFloat64
+		for _, p := range points {
+			mins[dim] = math.Min(mins[dim], p.Dim(dim))
+			maxs[dim] = math.Max(maxs[dim], p.Dim(dim))
+		}
+		if maxs[dim] > mins[dim] {
+			dims = append(dims, dim)
+		}
+	}
+	if len(dims) == 0 {
+		return &node{size: len(points)}
+	}
+
+	splitDim := dims[rnd.Intn(len(dims))]
+	splitValue := mins[splitDim] + rnd.Float64()*(maxs[splitDim]-mins[splitDim])
+
+	left := make([]predictor.Point, 0, len(points))
+	right := make([]predictor.Point, 0, len(points))
+	for _, p := range points {
+		if p.Dim(splitDim) < splitValue {
+			left = append(left, p)
+		} else {
+			right = append(right, p)
+		}
+	}
+
+	return &node{
+		splitDim:   splitDim,
+		splitValue: splitValue,
+		size:       len(points),
+		left:       buildNodeRecursive(rnd, left, height+1, heightLimit),
+		right:      buildNodeRecursive(rnd, right, height+1, heightLimit),
+	}
+}
+
+// pathLength returns the number of edges from the root to the external node isolating the point
+func (t *tree) pathLength(p predictor.Point) float64 {
+	var depth float64
+	current := t.root
+	for !current.external() {
+		if current.splitDim >= p.Dimensions() {
+			break
+		}
+		if p.Dim(current.splitDim) < current.splitValue {
+			current = current.left
+		} else {
+			current = current.right
+		}
+		depth++
+	}
+	return depth + averagePathLength(current.size)
+}
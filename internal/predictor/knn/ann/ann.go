@@ -0,0 +1,216 @@
+package ann
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-sod/sod/internal/predictor"
+	"github.com/go-sod/sod/internal/predictor/knn/avlnode"
+	"github.com/go-sod/sod/pkg/avltree"
+	"github.com/go-sod/sod/pkg/hnsw"
+)
+
+var _ predictor.KNNAlg = (*ann)(nil)
+
+func WithMaxItems(n int) Option {
+	return func(a *ann) {
+		a.opts.maxItemsStored = n
+	}
+}
+
+func WithStorageTime(t time.Duration) Option {
+	return func(a *ann) {
+		a.opts.maxStorageTime = t
+	}
+}
+
+func WithM(m int) Option {
+	return func(a *ann) {
+		a.opts.m = m
+	}
+}
+
+func WithEfConstruction(ef int) Option {
+	return func(a *ann) {
+		a.opts.efConstruction = ef
+	}
+}
+
+func WithEfSearch(ef int) Option {
+	return func(a *ann) {
+		a.opts.efSearch = ef
+	}
+}
+
+type Option func(*ann)
+
+type Options struct {
+	maxItemsStored int
+	maxStorageTime time.Duration
+	m              int
+	efConstruction int
+	efSearch       int
+}
+
+const (
+	rebuildOutdatedTime = 60 * time.Second
+	rebuildSizeTime     = 5 * time.Second
+)
+
+// NewHNSWAlg returns the approximate KNN algorithm based on the hierarchical navigable small world graph
+func NewHNSWAlg(distanceFn func(vec, vec1 []float64) (float64, error), opts ...Option) *ann {
+	a := &ann{
+		distanceFn: distanceFn,
+		timesTree:  avltree.New(),
+		opts: Options{
+			m:              hnsw.DefaultM,
+			efConstruction: hnsw.DefaultEfConstruction,
+			efSearch:       hnsw.DefaultEfSearch,
+		},
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	a.graph = a.newGraph()
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancel = cancel
+	go a.schedule(ctx)
+	return a
+}
+
+type ann struct {
+	mtx sync.RWMutex
+
+	opts       Options
+	distanceFn func(vec, vec1 []float64) (float64, error)
+	graph      *hnsw.Graph
+	// time index of the graph points, the node id refers to the point in the graph
+	timesTree *avltree.Tree
+	nextID    uint64
+	cancel    func()
+}
+
+func (a *ann) Close() {
+	a.cancel()
+}
+
+func (a *ann) Reset() {
+	a.mtx.Lock()
+	a.graph = a.newGraph()
+	a.timesTree = avltree.New()
+	a.mtx.Unlock()
+}
+
+func (a *ann) Len() int {
+	a.mtx.RLock()
+	defer a.mtx.RUnlock()
+	return a.graph.Len()
+}
+
+func (a *ann) DataPoints() []predictor.DataPoint {
+	a.mtx.RLock()
+	list := a.timesTree.Points()
+	a.mtx.RUnlock()
+	points := make([]predictor.DataPoint, len(list))
+	for i := range list {
+		points[i] = list[i].(avlnode.TimeNode).V
+	}
+	return points
+}
+
+func (a *ann) Build(data ...predictor.DataPoint) {
+	a.append(data...)
+}
+
+func (a *ann) Append(data ...predictor.DataPoint) {
+	a.append(data...)
+}
+
+func (a *ann) KNN(vec predictor.Point, n int) ([]predictor.Point, error) {
+	a.mtx.RLock()
+	items, err := a.graph.KNN(vec, n)
+	a.mtx.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	points := make([]predictor.Point, len(items))
+	for i := range items {
+		points[i] = items[i].(predictor.Point)
+	}
+	return points, nil
+}
+
+func (a *ann) newGraph() *hnsw.Graph {
+	return hnsw.New(
+		a.distanceFn,
+		hnsw.WithM(a.opts.m),
+		hnsw.WithEfConstruction(a.opts.efConstruction),
+		hnsw.WithEfSearch(a.opts.efSearch),
+	)
+}
+
+func (a *ann) append(data ...predictor.DataPoint) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	for i := range data {
+		a.nextID++
+		// the point with the dimension different from the graph is not indexed
+		if err := a.graph.Insert(a.nextID, data[i].Point()); err != nil {
+			continue
+		}
+		a.timesTree.Add(avlnode.TimeNode{
+			K:  data[i].Time(),
+			V:  data[i],
+			ID: a.nextID,
+		})
+	}
+}
+
+// remove deletes the points from the time index and the graph, must be called under the lock
+func (a *ann) remove(list []avltree.Item) {
+	for i := range list {
+		timeNode := list[i].(avlnode.TimeNode)
+		a.timesTree.Remove(timeNode)
+		_ = a.graph.Remove(timeNode.ID)
+	}
+}
+
+func (a *ann) removeOutdated() {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	a.remove(a.timesTree.Filter(func(current avltree.Item) bool {
+		return time.Since(current.(avlnode.TimeNode).K) > a.opts.maxStorageTime
+	}))
+}
+
+func (a *ann) removeOverSize() {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	sub := a.timesTree.Len() - a.opts.maxItemsStored
+	if sub <= 0 {
+		return
+	}
+	a.remove(a.timesTree.Points()[:sub])
+}
+
+func (a *ann) schedule(ctx context.Context) {
+	outdatedTicker := time.NewTicker(rebuildOutdatedTime)
+	sizeTicker := time.NewTicker(rebuildSizeTime)
+	defer outdatedTicker.Stop()
+	defer sizeTicker.Stop()
+	for {
+		select {
+		case <-outdatedTicker.C:
+			if a.opts.maxStorageTime > 0 {
+				a.removeOutdated()
+			}
+		case <-sizeTicker.C:
+			if a.opts.maxItemsStored > 0 {
+				a.removeOverSize()
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package ann
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-sod/sod/internal/geom"
+	"github.com/go-sod/sod/internal/predictor"
+	"github.com/go-sod/sod/internal/predictor/predictortest"
+)
+
+func data(n int, start time.Time) []predictor.DataPoint {
+	list := make([]predictor.DataPoint, n)
+	for i := range list {
+		list[i] = predictortest.DataPoint{Vec: geom.Point{float64(i), float64(i)}, CreatedAt: start.Add(time.Duration(i) * time.Second)}
+	}
+	return list
+}
+
+func TestANN_KNN(t *testing.T) {
+	t.Parallel()
+	a := NewHNSWAlg(geom.EuclideanDistance)
+	defer a.Close()
+	a.Build(data(100, time.Now())...)
+
+	points, err := a.KNN(geom.Point{50.1, 50.1}, 3)
+	if err != nil {
+		t.Fatalf("knn: %v", err)
+	}
+	expected := []float64{50, 51, 49}
+	if len(points) != len(expected) {
+		t.Fatalf("knn len, got: %d, expected: %d", len(points), len(expected))
+	}
+	for i := range expected {
+		if points[i].Dim(0) != expected[i] {
+			t.Errorf("neighbor %d, got: %v, expected: %v", i, points[i].Dim(0), expected[i])
+		}
+	}
+}
+
+func TestANN_Remove(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		opts     []Option
+		start    time.Time
+		remove   func(a *ann)
+		expected int
+	}{
+		{
+			name:     "positive_outdated",
+			opts:     []Option{WithStorageTime(time.Hour)},
+			start:    time.Now().Add(-time.Hour - 69500*time.Millisecond),
+			remove:   func(a *ann) { a.removeOutdated() },
+			expected: 30,
+		},
+		{
+			name:     "positive_over_size",
+			opts:     []Option{WithMaxItems(40)},
+			start:    time.Now(),
+			remove:   func(a *ann) { a.removeOverSize() },
+			expected: 40,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			a := NewHNSWAlg(geom.EuclideanDistance, test.opts...)
+			defer a.Close()
+			a.Build(data(100, test.start)...)
+			test.remove(a)
+			if a.Len() != test.expected {
+				t.Errorf("graph len, got: %d, expected: %d", a.Len(), test.expected)
+			}
+			if len(a.DataPoints()) != test.expected {
+				t.Errorf("time index len, got: %d, expected: %d", len(a.DataPoints()), test.expected)
+			}
+			// the newest points are kept
+			points, err := a.KNN(geom.Point{0, 0}, 1)
+			if err != nil {
+				t.Fatalf("knn: %v", err)
+			}
+			if points[0].Dim(0) != float64(100-test.expected) {
+				t.Errorf("closest kept point, got: %v, expected: %v", points[0].Dim(0), 100-test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package auto
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-sod/sod/internal/predictor"
+	"github.com/go-sod/sod/internal/predictor/knn/brute"
+	"github.com/go-sod/sod/internal/predictor/knn/gbball"
+	"github.com/go-sod/sod/internal/predictor/knn/gbkd"
+)
+
+var _ predictor.KNNAlg = (*auto)(nil)
+
+const (
+	// up to this dataset size the brute force search is faster than the trees traversal
+	DefaultBruteMaxItems = 512
+	// kd tree degrades to the full scan on higher dimensions, the ball tree is used instead
+	DefaultKDTreeMaxDimensions = 16
+)
+
+type backend uint8
+
+const (
+	backendBrute backend = iota
+	backendKDTree
+	backendBallTree
+)
+
+func WithMaxItems(n int) Option {
+	return func(a *auto) {
+		a.opts.maxItemsStored = n
+	}
+}
+
+func WithStorageTime(t time.Duration) Option {
+	return func(a *auto) {
+		a.opts.maxStorageTime = t
+	}
+}
+
+func WithBruteMaxItems(n int) Option {
+	return func(a *auto) {
+		a.opts.bruteMaxItems = n
+	}
+}
+
+func WithKDTreeMaxDimensions(n int) Option {
+	return func(a *auto) {
+		a.opts.kdTreeMaxDimensions = n
+	}
+}
+
+type Option func(*auto)
+
+type Options struct {
+	maxItemsStored      int
+	maxStorageTime      time.Duration
+	bruteMaxItems       int
+	kdTreeMaxDimensions int
+}
+
+type closer interface {
+	Close()
+}
+
+// NewAutoAlg returns the KNN algorithm choosing between brute force, kd tree and ball tree
+// from the dimensionality and the size of the dataset
+func NewAutoAlg(distFn func(vec, vec1 []float64) (float64, error), opts ...Option) *auto {
+	a := &auto{
+		distFn: distFn,
+		opts: Options{
+			bruteMaxItems:       DefaultBruteMaxItems,
+			kdTreeMaxDimensions: DefaultKDTreeMaxDimensions,
+		},
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	a.alg, a.backend = a.newAlg(backendBrute), backendBrute
+	return a
+}
+
+type auto struct {
+	mtx sync.RWMutex
+
+	opts    Options
+	distFn  func(vec, vec1 []float64) (float64, error)
+	alg     predictor.KNNAlg
+	backend backend
+}
+
+func (a *auto) Close() {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	if c, ok := a.alg.(closer); ok {
+		c.Close()
+	}
+}
+
+func (a *auto) Reset() {
+	a.mtx.RLock()
+	defer a.mtx.RUnlock()
+	a.alg.Reset()
+}
+
+func (a *auto) Len() int {
+	a.mtx.RLock()
+	defer a.mtx.RUnlock()
+	return a.alg.Len()
+}
+
+func (a *auto) DataPoints() []predictor.DataPoint {
+	a.mtx.RLock()
+	defer a.mtx.RUnlock()
+	return a.alg.DataPoints()
+}
+
+func (a *auto) KNN(vec predictor.Point, k int) ([]predictor.Point, error) {
+	a.mtx.RLock()
+	defer a.mtx.RUnlock()
+	return a.alg.KNN(vec, k)
+}
+
+func (a *auto) Build(data ...predictor.DataPoint) {
+	if len(data) == 0 {
+		return
+	}
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	selected := a.selectBackend(data[0].Point().Dimensions(), a.alg.Len()+len(data))
+	if selected != a.backend {
+		a.switchBackend(selected, data...)
+		return
+	}
+	a.alg.Build(data...)
+}
+
+func (a *auto) Append(data ...predictor.DataPoint) {
+	if len(data) == 0 {
+		return
+	}
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	selected := a.selectBackend(data[0].Point().Dimensions(), a.alg.Len()+len(data))
+	// the backend is switched only when the entity grows past the size boundary
+	if selected > a.backend {
+		a.switchBackend(selected, data...)
+		return
+	}
+	a.alg.Append(data...)
+}
+
+func (a *auto) selectBackend(dimensions, size int) backend {
+	switch {
+	case size <= a.opts.bruteMaxItems:
+		return backendBrute
+	case dimensions <= a.opts.kdTreeMaxDimensions:
+		return backendKDTree
+	default:
+		return backendBallTree
+	}
+}
+
+// switchBackend moves the stored dataset with the new data to the selected backend, must be called under the lock
+func (a *auto) switchBackend(selected backend, data ...predictor.DataPoint) {
+	alg := a.newAlg(selected)
+	alg.Build(append(a.alg.DataPoints(), data...)...)
+	if c, ok := a.alg.(closer); ok {
+		c.Close()
+	}
+	a.alg, a.backend = alg, selected
+}
+
+func (a *auto) newAlg(selected backend) predictor.KNNAlg {
+	switch selected {
+	case backendKDTree:
+		return gbkd.NewGBkdAlg(a.distFn, gbkd.WithStorageTime(a.opts.maxStorageTime), gbkd.WithMaxItems(a.opts.maxItemsStored))
+	case backendBallTree:
+		return gbball.NewGBBallAlg(
+			a.distFn,
+			gbball.WithStorageTime(a.opts.maxStorageTime),
+			gbball.WithMaxItems(a.opts.maxItemsStored),
+		)
+	default:
+		return brute.NewBruteAlg(a.distFn, brute.WithMaxItems(a.opts.maxItemsStored), brute.WithStorageTime(a.opts.maxStorageTime))
+	}
+}
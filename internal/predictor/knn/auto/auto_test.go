@@ -0,0 +1,61 @@
+package auto
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-sod/sod/internal/geom"
+	"github.com/go-sod/sod/internal/predictor"
+	"github.com/go-sod/sod/internal/predictor/predictortest"
+)
+
+func data(n, dims int) []predictor.DataPoint {
+	list := make([]predictor.DataPoint, n)
+	now := time.Now()
+	for i := range list {
+		vec := make(geom.Point, dims)
+		for j := range vec {
+			vec[j] = float64(i*(j+1)) / 10
+		}
+		list[i] = predictortest.DataPoint{Vec: vec, CreatedAt: now.Add(time.Duration(i) * time.Millisecond)}
+	}
+	return list
+}
+
+func TestAuto_Append(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		dims     int
+		n        int
+		expected backend
+	}{
+		{name: "positive_brute", dims: 2, n: 10, expected: backendBrute},
+		{name: "positive_kd_tree", dims: 2, n: 40, expected: backendKDTree},
+		{name: "positive_ball_tree", dims: 32, n: 40, expected: backendBallTree},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			a := NewAutoAlg(geom.EuclideanDistance, WithBruteMaxItems(20))
+			defer a.Close()
+			for _, dat := range data(test.n, test.dims) {
+				a.Append(dat)
+			}
+			if a.backend != test.expected {
+				t.Errorf("selected backend, got: %v, expected: %v", a.backend, test.expected)
+			}
+			if a.Len() != test.n {
+				t.Errorf("calling Len, got: %v, expected: %v", a.Len(), test.n)
+			}
+			nn, err := a.KNN(geom.Point(make([]float64, test.dims)), 3)
+			if err != nil {
+				t.Fatalf("calling KNN, got error: %v", err)
+			}
+			if len(nn) != 3 {
+				t.Errorf("calling KNN, got len: %v, expected: %v", len(nn), 3)
+			}
+		})
+	}
+}
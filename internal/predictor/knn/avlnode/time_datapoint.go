@@ -10,6 +10,8 @@ import (
 type TimeNode struct {
 	K time.Time
 	V predictor.DataPoint
+	// optional identifier of the point in the index, distinguishes the points with the same time
+	ID uint64
 }
 
 func (i TimeNode) Key() interface{} {
@@ -22,7 +24,14 @@ func (i TimeNode) Value() interface{} {
 
 func (i TimeNode) Subtraction(item avltree.Item) int {
 	if i.K.Equal(item.(TimeNode).K) {
-		return 0
+		switch {
+		case i.ID < item.(TimeNode).ID:
+			return -1
+		case i.ID > item.(TimeNode).ID:
+			return 1
+		default:
+			return 0
+		}
 	}
 
 	if i.K.Before(item.(TimeNode).K) {
@@ -57,6 +57,10 @@ type brute struct {
 	cancel    func()
 }
 
+func (b *brute) Close() {
+	b.cancel()
+}
+
 func (b *brute) Reset() {
 	b.mtx.Lock()
 	b.data = avltree.New()
@@ -73,6 +77,17 @@ func (b *brute) Len() int {
 	return b.data.Len()
 }
 
+func (b *brute) DataPoints() []predictor.DataPoint {
+	b.mtx.RLock()
+	list := b.data.Points()
+	b.mtx.RUnlock()
+	points := make([]predictor.DataPoint, len(list))
+	for i := range list {
+		points[i] = list[i].(avlnode.TimeNode).V
+	}
+	return points
+}
+
 func (b *brute) Build(data ...predictor.DataPoint) {
 	b.mtx.Lock()
 	defer b.mtx.Unlock()
@@ -0,0 +1,282 @@
+package gbball
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sod/sod/internal/predictor"
+	"github.com/go-sod/sod/internal/predictor/knn/avlnode"
+	"github.com/go-sod/sod/pkg/avltree"
+	"github.com/go-sod/sod/pkg/balltree"
+)
+
+func WithMaxItems(n int) Option {
+	return func(l *gbball) {
+		l.opts.maxItemsStored = n
+	}
+}
+
+func WithStorageTime(t time.Duration) Option {
+	return func(l *gbball) {
+		l.opts.maxStorageTime = t
+	}
+}
+
+type Option func(*gbball)
+
+type Options struct {
+	maxItemsStored int
+	maxStorageTime time.Duration
+}
+
+const (
+	rebuildOutdatedTime = 60 * time.Second
+	rebuildSizeTime     = 5 * time.Second
+	balanceBallTreeTime = 1 * time.Minute
+	greenBlueBuildTime  = 10 * time.Second
+)
+
+type gbTree struct {
+	green *balltree.Tree
+	blue  *balltree.Tree
+	state uint32
+}
+
+func (t *gbTree) tree() *balltree.Tree {
+	if atomic.LoadUint32(&t.state) == 0 {
+		return t.green
+	}
+	return t.blue
+}
+
+func (t *gbTree) build(items ...balltree.Point) error {
+	if atomic.LoadUint32(&t.state) == 0 {
+		if err := t.blue.Build(items...); err != nil {
+			return err
+		}
+		atomic.StoreUint32(&t.state, 1)
+	} else {
+		if err := t.green.Build(items...); err != nil {
+			return err
+		}
+		atomic.StoreUint32(&t.state, 0)
+	}
+	return nil
+}
+
+func NewGBBallAlg(distanceFn func(vec, vec1 []float64) (float64, error), opts ...Option) *gbball {
+	b := &gbball{
+		distanceFn:          distanceFn,
+		timesTree:           avltree.New(),
+		rebuildOutdatedTime: time.Now(),
+		gbTree:              &gbTree{state: 0, green: balltree.New(distanceFn), blue: balltree.New(distanceFn)},
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	b.cancel = cancel
+	go b.schedule(ctx)
+	return b
+}
+
+type gbball struct {
+	mtx sync.RWMutex
+
+	opts                Options
+	distanceFn          func(vec, vec1 []float64) (float64, error)
+	rebuildOutdatedTime time.Time
+	timesTree           *avltree.Tree
+	gbTree              *gbTree
+	removeOpCnt         int64
+	removeOpTime        int64
+	appendOpTime        int64
+	appendOpCnt         int64
+	cancel              func()
+}
+
+func (b *gbball) Close() {
+	b.cancel()
+}
+
+func (b *gbball) Build(data ...predictor.DataPoint) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.timesTree == nil {
+		b.timesTree = avltree.New()
+	}
+
+	items := make([]balltree.Point, len(data))
+
+	for i := range data {
+		items[i] = data[i].Point()
+		b.timesTree.Add(avlnode.TimeNode{
+			K: data[i].Time(),
+			V: data[i],
+		})
+	}
+
+	// the tree stays unchanged when the points dimensions are not equal
+	_ = b.gbTree.build(items...)
+}
+
+func (b *gbball) Len() int {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+	return b.gbTree.tree().Len()
+}
+
+func (b *gbball) DataPoints() []predictor.DataPoint {
+	b.mtx.RLock()
+	list := b.timesTree.Points()
+	b.mtx.RUnlock()
+	points := make([]predictor.DataPoint, len(list))
+	for i := range list {
+		points[i] = list[i].(avlnode.TimeNode).V
+	}
+	return points
+}
+
+func (b *gbball) Append(data ...predictor.DataPoint) {
+	for i := range data {
+		b.append(data[i])
+	}
+}
+
+func (b *gbball) KNN(vec predictor.Point, n int) ([]predictor.Point, error) {
+	b.mtx.RLock()
+	items, err := b.gbTree.tree().KNN(vec, n)
+	b.mtx.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	ballVectors := make([]predictor.Point, 0, len(items))
+	for i := range items {
+		ballVectors = append(ballVectors, items[i].(predictor.Point))
+	}
+	return ballVectors, nil
+}
+
+func (b *gbball) Reset() {
+	b.mtx.Lock()
+	b.gbTree.blue = balltree.New(b.distanceFn)
+	b.gbTree.green = balltree.New(b.distanceFn)
+	b.timesTree = avltree.New()
+	b.mtx.Unlock()
+}
+
+func (b *gbball) append(data predictor.DataPoint) {
+	b.mtx.Lock()
+	if err := b.gbTree.tree().Insert(data.Point()); err != nil {
+		b.mtx.Unlock()
+		return
+	}
+	b.timesTree.Add(avlnode.TimeNode{
+		K: data.Time(),
+		V: data,
+	})
+	b.mtx.Unlock()
+	atomic.AddInt64(&b.appendOpCnt, 1)
+}
+
+func (b *gbball) needBalanceBall() bool {
+	b.mtx.RLock()
+	gbLen := b.gbTree.tree().Len()
+	b.mtx.RUnlock()
+	timeDiff := time.Now().Unix() - atomic.LoadInt64(&b.appendOpTime)
+	valueDiff := float64(atomic.LoadInt64(&b.appendOpCnt)) / float64(gbLen)
+	return gbLen > 0 &&
+		(valueDiff > 0.001 || (atomic.LoadInt64(&b.appendOpCnt) > 0 && timeDiff > int64(greenBlueBuildTime.Seconds())))
+}
+
+func (b *gbball) balanceBallTree() {
+	if b.needBalanceBall() {
+		b.mtx.Lock()
+		_ = b.gbTree.tree().Balance()
+		b.mtx.Unlock()
+		atomic.StoreInt64(&b.appendOpCnt, 0)
+		atomic.StoreInt64(&b.appendOpTime, time.Now().Unix())
+	}
+}
+
+func (b *gbball) needGBBuild() bool {
+	b.mtx.RLock()
+	gbLen := b.gbTree.tree().Len()
+	b.mtx.RUnlock()
+	timeDiff := time.Now().Unix() - atomic.LoadInt64(&b.removeOpTime)
+	valueDiff := float64(atomic.LoadInt64(&b.removeOpCnt)) / float64(gbLen)
+	return gbLen > 0 &&
+		(valueDiff > 0.01 || (atomic.LoadInt64(&b.removeOpCnt) > 0 && timeDiff > int64(greenBlueBuildTime.Seconds())))
+}
+
+func (b *gbball) buildGBTree() {
+	if b.needGBBuild() {
+		b.mtx.RLock()
+		items := make([]balltree.Point, b.timesTree.Len())
+		for i, point := range b.timesTree.Points() {
+			items[i] = point.(avlnode.TimeNode).V.Point()
+		}
+		b.mtx.RUnlock()
+		if err := b.gbTree.build(items...); err != nil {
+			return
+		}
+		atomic.StoreInt64(&b.removeOpCnt, 0)
+		atomic.StoreInt64(&b.removeOpTime, time.Now().Unix())
+	}
+}
+
+func (b *gbball) rebuildOutdated() {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	list := b.timesTree.Filter(func(current avltree.Item) bool {
+		return time.Since(current.(avlnode.TimeNode).K) > b.opts.maxStorageTime
+	})
+	for i := range list {
+		b.timesTree.Remove(list[i])
+		atomic.AddInt64(&b.removeOpCnt, 1)
+	}
+	b.rebuildOutdatedTime = time.Now()
+}
+
+func (b *gbball) rebuildSize() {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	sub := b.timesTree.Len() - b.opts.maxItemsStored
+	list := b.timesTree.Points()
+	for _, timeNode := range list[:sub] {
+		b.timesTree.Remove(timeNode)
+		atomic.AddInt64(&b.removeOpCnt, 1)
+	}
+}
+
+func (b *gbball) schedule(ctx context.Context) {
+	outdatedTicker := time.NewTicker(rebuildOutdatedTime)
+	sizeTicker := time.NewTicker(rebuildSizeTime)
+	ballBalanceTicker := time.NewTicker(balanceBallTreeTime)
+	gbBuildTicker := time.NewTicker(5 * time.Second)
+	defer outdatedTicker.Stop()
+	defer sizeTicker.Stop()
+	defer ballBalanceTicker.Stop()
+	defer gbBuildTicker.Stop()
+	for {
+		select {
+		case <-outdatedTicker.C:
+			if b.opts.maxStorageTime > 0 && time.Since(b.rebuildOutdatedTime) > b.opts.maxStorageTime {
+				b.rebuildOutdated()
+			}
+		case <-sizeTicker.C:
+			if b.opts.maxItemsStored > 0 && b.timesTree.Len() > b.opts.maxItemsStored {
+				b.rebuildSize()
+			}
+		case <-ballBalanceTicker.C:
+			b.balanceBallTree()
+		case <-gbBuildTicker.C:
+			b.buildGBTree()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
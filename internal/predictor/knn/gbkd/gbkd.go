@@ -123,6 +123,17 @@ func (b *gbkd) Len() int {
 	return b.gbTree.tree().Len()
 }
 
+func (b *gbkd) DataPoints() []predictor.DataPoint {
+	b.mtx.RLock()
+	list := b.timesTree.Points()
+	b.mtx.RUnlock()
+	points := make([]predictor.DataPoint, len(list))
+	for i := range list {
+		points[i] = list[i].(avlnode.TimeNode).V
+	}
+	return points
+}
+
 func (b *gbkd) Append(data ...predictor.DataPoint) {
 	for i := range data {
 		b.append(data[i])
@@ -132,10 +143,10 @@ func (b *gbkd) Append(data ...predictor.DataPoint) {
 func (b *gbkd) KNN(vec predictor.Point, n int) ([]predictor.Point, error) {
 	b.mtx.RLock()
 	items, err := b.gbTree.tree().KNN(vec, n)
+	b.mtx.RUnlock()
 	if err != nil {
 		return nil, err
 	}
-	b.mtx.RUnlock()
 	kdVectors := make([]predictor.Point, 0, len(items))
 	for i := range items {
 		kdVectors = append(kdVectors, items[i].(predictor.Point))
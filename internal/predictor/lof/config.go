@@ -6,7 +6,10 @@ import (
 
 	"github.com/go-sod/sod/internal/geom"
 	"github.com/go-sod/sod/internal/predictor"
+	"github.com/go-sod/sod/internal/predictor/knn/ann"
+	"github.com/go-sod/sod/internal/predictor/knn/auto"
 	"github.com/go-sod/sod/internal/predictor/knn/brute"
+	"github.com/go-sod/sod/internal/predictor/knn/gbball"
 	"github.com/go-sod/sod/internal/predictor/knn/gbkd"
 )
 
@@ -27,22 +30,55 @@ const (
 	AlgTypeBallTree AlgType = "BALL_TREE"
 	AlgTypeKDTree   AlgType = "KD_TREE"
 	AlgTypeBrute    AlgType = "BRUTE"
+	// approximate search for the high-dimensional entities
+	AlgTypeHNSW AlgType = "HNSW"
+)
+
+type ThresholdType string
+
+const (
+	// the point is an outlier when its lof exceeds the configured value
+	ThresholdTypeFixed ThresholdType = "FIXED"
+	// the threshold is the lof quantile of the current entity window matching the expected share of outliers
+	ThresholdTypeContamination ThresholdType = "CONTAMINATION"
 )
 
 type Config struct {
-	SkipItems      int              `envconfig:"SKIP_ITEMS"`
-	KNum           int              `envconfig:"LOF_K_NUM" default:"3"`
-	MetricFuncType DistanceFuncType `envconfig:"LOF_DISTANCE_FUNC" default:"EUCLIDEAN"`
-	AlgType        AlgType          `envconfig:"LOF_ALG_TYPE" default:"KD_TREE"`
+	SkipItems            int              `envconfig:"SKIP_ITEMS"`
+	KNum                 int              `envconfig:"LOF_K_NUM" default:"3"`
+	MetricFuncType       DistanceFuncType `envconfig:"LOF_DISTANCE_FUNC" default:"EUCLIDEAN"`
+	AlgType              AlgType          `envconfig:"LOF_ALG_TYPE" default:"KD_TREE"`
+	ThresholdType        ThresholdType    `envconfig:"LOF_THRESHOLD_TYPE" default:"FIXED"`
+	Threshold            float64          `envconfig:"LOF_THRESHOLD" default:"1"`
+	Contamination        float64          `envconfig:"LOF_CONTAMINATION" default:"0.005"`
+	ThresholdSampleSize  int              `envconfig:"LOF_THRESHOLD_SAMPLE_SIZE" default:"1000"`
+	ThresholdRebuildTime time.Duration    `envconfig:"LOF_THRESHOLD_REBUILD_TIME" default:"1m"`
+	HNSWM                int              `envconfig:"LOF_HNSW_M" default:"16"`
+	HNSWEfConstruction   int              `envconfig:"LOF_HNSW_EF_CONSTRUCTION" default:"200"`
+	HNSWEfSearch         int              `envconfig:"LOF_HNSW_EF_SEARCH" default:"64"`
 }
 
-func NNFor(a AlgType, maxItems int, maxTime time.Duration, distFn func(vec, vec1 []float64) (float64, error)) (predictor.KNNAlg, error) {
+// NNFor returns the KNN algorithm of the type, annOpts are applied only to the HNSW algorithm
+func NNFor(
+	a AlgType,
+	maxItems int,
+	maxTime time.Duration,
+	distFn func(vec, vec1 []float64) (float64, error),
+	annOpts ...ann.Option,
+) (predictor.KNNAlg, error) {
 	switch a {
 	case AlgTypeBrute:
 		return brute.NewBruteAlg(distFn, brute.WithMaxItems(maxItems), brute.WithStorageTime(maxTime)), nil
 	case AlgTypeKDTree:
 		// return kd.NewKDAlg(distFn, kd.WithStorageTime(maxTime), kd.WithMaxItems(maxItems)), nil
 		return gbkd.NewGBkdAlg(distFn, gbkd.WithStorageTime(maxTime), gbkd.WithMaxItems(maxItems)), nil
+	case AlgTypeBallTree:
+		return gbball.NewGBBallAlg(distFn, gbball.WithStorageTime(maxTime), gbball.WithMaxItems(maxItems)), nil
+	case AlgTypeAuto:
+		return auto.NewAutoAlg(distFn, auto.WithStorageTime(maxTime), auto.WithMaxItems(maxItems)), nil
+	case AlgTypeHNSW:
+		opts := append([]ann.Option{ann.WithStorageTime(maxTime), ann.WithMaxItems(maxItems)}, annOpts...)
+		return ann.NewHNSWAlg(distFn, opts...), nil
 	default:
 		return nil, fmt.Errorf("unable to create alg with alg type %s", a)
 	}
@@ -1,18 +1,31 @@
 package lof
 
 import (
+	"context"
 	"fmt"
 	"math"
+	"math/rand"
+	"sort"
+	"sync"
 	"time"
 
+	"github.com/go-sod/sod/internal/geom"
 	"github.com/go-sod/sod/internal/predictor"
+	"github.com/go-sod/sod/internal/predictor/knn/ann"
+	"github.com/go-sod/sod/pkg/hnsw"
 )
 
-var _ predictor.Predictor = (*lof)(nil)
+var (
+	_ predictor.Predictor   = (*lof)(nil)
+	_ predictor.Thresholder = (*lof)(nil)
+)
 
 const (
 	// local predict factor delimiter
 	LOF = 1
+	// the minimum number of scored points to estimate the contamination threshold
+	minThresholdSampleSize = 10
+	MaxContamination       = 0.5
 )
 
 type Option func(*lof)
@@ -53,14 +66,71 @@ func WithAlg(alg AlgType) Option {
 	}
 }
 
-var defaultOptions = Options{algType: AlgTypeBrute, distanceFuncType: DistanceFuncTypeEuclidean}
+func WithThresholdType(t ThresholdType) Option {
+	return func(l *lof) {
+		l.opts.thresholdType = t
+	}
+}
+
+func WithThreshold(t float64) Option {
+	return func(l *lof) {
+		l.opts.threshold = t
+	}
+}
+
+func WithContamination(c float64) Option {
+	return func(l *lof) {
+		l.opts.contamination = c
+	}
+}
+
+func WithThresholdSampleSize(n int) Option {
+	return func(l *lof) {
+		l.opts.thresholdSampleSize = n
+	}
+}
+
+func WithThresholdRebuildTime(t time.Duration) Option {
+	return func(l *lof) {
+		l.opts.thresholdRebuildTime = t
+	}
+}
+
+// WithHNSWParams sets the graph parameters of the HNSW algorithm
+func WithHNSWParams(m, efConstruction, efSearch int) Option {
+	return func(l *lof) {
+		l.opts.hnswM = m
+		l.opts.hnswEfConstruction = efConstruction
+		l.opts.hnswEfSearch = efSearch
+	}
+}
+
+var defaultOptions = Options{
+	algType:              AlgTypeBrute,
+	distanceFuncType:     DistanceFuncTypeEuclidean,
+	thresholdType:        ThresholdTypeFixed,
+	threshold:            LOF,
+	thresholdSampleSize:  1000,
+	thresholdRebuildTime: time.Minute,
+	hnswM:                hnsw.DefaultM,
+	hnswEfConstruction:   hnsw.DefaultEfConstruction,
+	hnswEfSearch:         hnsw.DefaultEfSearch,
+}
 
 type Options struct {
-	algType          AlgType
-	distanceFuncType DistanceFuncType
-	skipItems        int
-	maxItemsStored   int
-	maxStorageTime   time.Duration
+	algType              AlgType
+	distanceFuncType     DistanceFuncType
+	skipItems            int
+	maxItemsStored       int
+	maxStorageTime       time.Duration
+	thresholdType        ThresholdType
+	threshold            float64
+	contamination        float64
+	thresholdSampleSize  int
+	thresholdRebuildTime time.Duration
+	hnswM                int
+	hnswEfConstruction   int
+	hnswEfSearch         int
 }
 
 func New(opts ...Option) (*lof, error) {
@@ -71,24 +141,95 @@ func New(opts ...Option) (*lof, error) {
 	for _, f := range opts {
 		f(lof)
 	}
-	distFunc, err := DistanceFuncFor(lof.opts.distanceFuncType)
-	if err != nil {
-		return nil, fmt.Errorf("unable creating lof instance, %w", err)
+	// the distance function passed with WithDistance takes precedence over the default one
+	if lof.distFunc == nil {
+		distFunc, err := DistanceFuncFor(lof.opts.distanceFuncType)
+		if err != nil {
+			return nil, fmt.Errorf("unable creating lof instance, %w", err)
+		}
+		lof.distFunc = distFunc
 	}
-	lof.distFunc = distFunc
-	alg, err := NNFor(lof.opts.algType, lof.opts.maxItemsStored, lof.opts.maxStorageTime, distFunc)
+	alg, err := NNFor(
+		lof.opts.algType,
+		lof.opts.maxItemsStored,
+		lof.opts.maxStorageTime,
+		lof.distFunc,
+		ann.WithM(lof.opts.hnswM),
+		ann.WithEfConstruction(lof.opts.hnswEfConstruction),
+		ann.WithEfSearch(lof.opts.hnswEfSearch),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("unable creating lof instance, %w", err)
 	}
 	lof.alg = alg
+	lof.threshold = lof.opts.threshold
+	switch lof.opts.thresholdType {
+	case ThresholdTypeFixed:
+	case ThresholdTypeContamination:
+		if lof.opts.contamination <= 0 || lof.opts.contamination > MaxContamination {
+			lof.Close()
+			return nil, fmt.Errorf(
+				"unable creating lof instance, contamination %v out of range (0, %v]",
+				lof.opts.contamination,
+				MaxContamination,
+			)
+		}
+		if lof.opts.thresholdRebuildTime <= 0 {
+			lof.Close()
+			return nil, fmt.Errorf(
+				"unable creating lof instance, threshold rebuild time %v is not positive",
+				lof.opts.thresholdRebuildTime,
+			)
+		}
+		if lof.opts.thresholdSampleSize < minThresholdSampleSize {
+			lof.Close()
+			return nil, fmt.Errorf(
+				"unable creating lof instance, threshold sample size %d is less than %d",
+				lof.opts.thresholdSampleSize,
+				minThresholdSampleSize,
+			)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		lof.cancel = cancel
+		go lof.schedule(ctx)
+	default:
+		lof.Close()
+		return nil, fmt.Errorf("unable creating lof instance, unknown threshold type: %s", lof.opts.thresholdType)
+	}
 	return lof, nil
 }
 
 type lof struct {
+	mtx sync.RWMutex
+	// estimateMtx serializes the estimations of the threshold by the schedule and by the first prediction
+	estimateMtx sync.Mutex
+
 	opts     Options
 	kNum     int
 	alg      predictor.KNNAlg
 	distFunc func(vec, vec1 []float64) (float64, error)
+	// the lof value above which the point is an outlier
+	threshold float64
+	// the threshold is estimated from the current window,
+	// until then the contamination threshold falls back to the fixed one, LOF (1) by default
+	estimated bool
+	cancel    func()
+}
+
+// Threshold returns the lof value above which the point is an outlier
+func (l *lof) Threshold() float64 {
+	l.mtx.RLock()
+	defer l.mtx.RUnlock()
+	return l.threshold
+}
+
+func (l *lof) Close() {
+	if l.cancel != nil {
+		l.cancel()
+	}
+	if c, ok := l.alg.(predictor.Closer); ok {
+		c.Close()
+	}
 }
 
 func (l *lof) Len() int {
@@ -118,12 +259,24 @@ func (l *lof) Predict(vec predictor.Point) (*predictor.Conclusion, error) {
 }
 
 func (l *lof) Reset() {
+	// the estimation of the previous window does not overwrite the reset threshold
+	l.estimateMtx.Lock()
+	defer l.estimateMtx.Unlock()
 	l.alg.Reset()
+	l.mtx.Lock()
+	l.threshold = l.opts.threshold
+	l.estimated = false
+	l.mtx.Unlock()
 }
 
 func (l *lof) Lof(vec predictor.Point) (float64, error) {
+	return l.lof(vec, false)
+}
+
+// lof computes the local outlier factor, the stored point is excluded from its own neighbors with the exclude flag
+func (l *lof) lof(vec predictor.Point, exclude bool) (float64, error) {
 	var lrdSum, avgLrd float64
-	nn, err := l.alg.KNN(vec, l.kNum)
+	nn, err := l.knn(vec, l.kNum, exclude)
 	if err != nil {
 		return 0.0, fmt.Errorf("unable compute KNN: %w", err)
 	}
@@ -135,7 +288,7 @@ func (l *lof) Lof(vec predictor.Point) (float64, error) {
 		lrdSum += lrd
 	}
 	avgLrd = lrdSum / float64(l.kNum)
-	lrd, err := l.lrd(vec)
+	lrd, err := l.lrdExcluding(vec, exclude)
 	if err != nil {
 		return 0.0, fmt.Errorf("unable compute lrd: %w", err)
 	}
@@ -154,15 +307,112 @@ func (l *lof) predict(data predictor.Point) (*predictor.Conclusion, error) {
 	if err := l.validateKNum(); err != nil {
 		return nil, err
 	}
+	if l.opts.thresholdType == ThresholdTypeContamination && !l.isEstimated() {
+		l.estimateMtx.Lock()
+		// the threshold is estimated once by the concurrent predictions or by the schedule meanwhile
+		if !l.isEstimated() {
+			l.estimate()
+		}
+		l.estimateMtx.Unlock()
+	}
 	lof, err := l.Lof(data)
 	if err != nil {
 		return nil, fmt.Errorf("unable compute lof: %w", err)
 	}
-	conclusion := &predictor.Conclusion{Outlier: false}
-	if lof > LOF {
-		conclusion.Outlier = true
+	return predictor.NewConclusion(normLof(lof), l.Threshold()), nil
+}
+
+// the point is in the cluster of duplicates, its density is equal to the density of the neighbors
+func normLof(lof float64) float64 {
+	if math.IsNaN(lof) {
+		return LOF
+	}
+	return lof
+}
+
+func (l *lof) isEstimated() bool {
+	l.mtx.RLock()
+	defer l.mtx.RUnlock()
+	return l.estimated
+}
+
+// estimateThreshold computes lof for a random sample of the window
+// and takes the quantile matching the contamination as the threshold
+func (l *lof) estimateThreshold() {
+	l.estimateMtx.Lock()
+	defer l.estimateMtx.Unlock()
+	l.estimate()
+}
+
+// estimate is estimateThreshold of the caller holding estimateMtx
+func (l *lof) estimate() {
+	dataPoints := l.alg.DataPoints()
+	points := make([]predictor.Point, len(dataPoints))
+	for i := range dataPoints {
+		points[i] = dataPoints[i].Point()
+	}
+	if len(points) <= l.kNum || len(points) < minThresholdSampleSize {
+		return
+	}
+	if len(points) > l.opts.thresholdSampleSize {
+		rand.Shuffle(len(points), func(i, j int) {
+			points[i], points[j] = points[j], points[i]
+		})
+		points = points[:l.opts.thresholdSampleSize]
+	}
+	scores := make([]float64, 0, len(points))
+	for _, p := range points {
+		score, err := l.lof(p, true)
+		if err != nil {
+			continue
+		}
+		scores = append(scores, normLof(score))
+	}
+	if len(scores) < minThresholdSampleSize {
+		return
+	}
+	sort.Float64s(scores)
+	idx := int(math.Floor(float64(len(scores)) * (1 - l.opts.contamination)))
+	if idx >= len(scores) {
+		idx = len(scores) - 1
+	}
+	l.mtx.Lock()
+	l.threshold = scores[idx]
+	l.estimated = true
+	l.mtx.Unlock()
+}
+
+func (l *lof) schedule(ctx context.Context) {
+	ticker := time.NewTicker(l.opts.thresholdRebuildTime)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.estimateThreshold()
+		case <-ctx.Done():
+			return
+		}
 	}
-	return conclusion, nil
+}
+
+// knn returns k nearest neighbors, the exclude flag drops the first neighbor equal to the point
+func (l *lof) knn(vec predictor.Point, k int, exclude bool) ([]predictor.Point, error) {
+	if !exclude {
+		return l.alg.KNN(vec, k)
+	}
+	nn, err := l.alg.KNN(vec, k+1)
+	if err != nil {
+		return nil, err
+	}
+	for i := range nn {
+		if geom.NewPoint(nn[i].Points()).Equal(vec.Points()) {
+			return append(nn[:i:i], nn[i+1:]...), nil
+		}
+	}
+	if len(nn) > k {
+		return nn[:k], nil
+	}
+	return nn, nil
 }
 
 func (l *lof) validateKNum() error {
@@ -172,16 +422,16 @@ func (l *lof) validateKNum() error {
 	return nil
 }
 
-func (l *lof) kDistance(in predictor.Point) (float64, error) {
-	vectors, err := l.alg.KNN(in, 3)
+func (l *lof) kDistance(in predictor.Point, exclude bool) (float64, error) {
+	vectors, err := l.knn(in, 3, exclude)
 	if err != nil {
 		return 0.0, fmt.Errorf("unable compute KNN: %w", err)
 	}
 	return l.distFunc(in.Points(), vectors[0].Points())
 }
 
-func (l *lof) reachabilityDist(vec, vec1 predictor.Point) (float64, error) {
-	kDistance, err := l.kDistance(vec)
+func (l *lof) reachabilityDist(vec, vec1 predictor.Point, exclude bool) (float64, error) {
+	kDistance, err := l.kDistance(vec, exclude)
 	if err != nil {
 		return 0.0, fmt.Errorf("unable compute kDistance: %w", err)
 	}
@@ -193,13 +443,17 @@ func (l *lof) reachabilityDist(vec, vec1 predictor.Point) (float64, error) {
 }
 
 func (l *lof) lrd(vec predictor.Point) (float64, error) {
+	return l.lrdExcluding(vec, false)
+}
+
+func (l *lof) lrdExcluding(vec predictor.Point, exclude bool) (float64, error) {
 	var rSum float64
-	nn, err := l.alg.KNN(vec, l.kNum)
+	nn, err := l.knn(vec, l.kNum, exclude)
 	if err != nil {
 		return 0.0, fmt.Errorf("unable to compute KNN: %w", err)
 	}
 	for _, vec1 := range nn {
-		rDistance, err := l.reachabilityDist(vec, vec1)
+		rDistance, err := l.reachabilityDist(vec, vec1, exclude)
 		if err != nil {
 			return 0.0, fmt.Errorf("unable to compute reachabilityDist: %w", err)
 		}
@@ -0,0 +1,143 @@
+package lof
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/go-sod/sod/internal/geom"
+	"github.com/go-sod/sod/internal/predictor/predictortest"
+)
+
+func TestLof_Threshold(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		opts []Option
+		// the number of the points of the window, 200 by default
+		points            int
+		expectedEstimated bool
+		expectedThreshold float64
+	}{
+		{
+			name:              "positive_fixed",
+			opts:              []Option{WithThresholdType(ThresholdTypeFixed), WithThreshold(1.5)},
+			expectedThreshold: 1.5,
+		},
+		{
+			name:              "positive_contamination",
+			opts:              []Option{WithThresholdType(ThresholdTypeContamination), WithContamination(0.05)},
+			expectedEstimated: true,
+		},
+		{
+			name:              "positive_contamination_fixed_fallback",
+			opts:              []Option{WithThresholdType(ThresholdTypeContamination), WithContamination(0.05)},
+			points:            minThresholdSampleSize - 1,
+			expectedThreshold: LOF,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			l, err := New(append(test.opts, WithAlg(AlgTypeBrute), WithKNum(5))...)
+			if err != nil {
+				t.Fatalf("unable create lof: %v", err)
+			}
+			defer l.Close()
+			points := test.points
+			if points == 0 {
+				points = 200
+			}
+			l.Build(predictortest.Cluster(points, geom.Point{10, 20}, 1)...)
+			conclusion, err := l.Predict(geom.Point{30, -10})
+			if err != nil {
+				t.Fatalf("compute Predict, got error: %v", err)
+			}
+			if !conclusion.Outlier {
+				t.Errorf("compute Predict, got: %v, expected: %v", conclusion.Outlier, true)
+			}
+			if conclusion.Threshold != l.Threshold() {
+				t.Errorf("compute Threshold, got: %v, expected: %v", conclusion.Threshold, l.Threshold())
+			}
+			if test.expectedEstimated {
+				if l.Threshold() <= 0 || l.Threshold() == LOF {
+					t.Errorf("compute estimated Threshold, got: %v, expected value estimated from window", l.Threshold())
+				}
+				return
+			}
+			if l.Threshold() != test.expectedThreshold {
+				t.Errorf("compute Threshold, got: %v, expected: %v", l.Threshold(), test.expectedThreshold)
+			}
+		})
+	}
+}
+
+func TestLof_ConcurrentEstimate(t *testing.T) {
+	t.Parallel()
+	l, err := New(WithThresholdType(ThresholdTypeContamination), WithContamination(0.05), WithAlg(AlgTypeBrute), WithKNum(5))
+	if err != nil {
+		t.Fatalf("unable create lof: %v", err)
+	}
+	defer l.Close()
+	l.Build(predictortest.Cluster(100, geom.Point{10, 20}, 1)...)
+
+	// the scheduled estimation runs along with the first predictions
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := l.Predict(geom.Point{30, -10}); err != nil {
+				t.Errorf("compute Predict, got error: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			l.estimateThreshold()
+		}()
+	}
+	wg.Wait()
+	if !l.isEstimated() || l.Threshold() == LOF {
+		t.Errorf("compute estimated Threshold, got: %v, expected value estimated from window", l.Threshold())
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "negative_threshold_type", opts: []Option{WithThresholdType("UNKNOWN")}},
+		{
+			name: "negative_contamination",
+			opts: []Option{WithThresholdType(ThresholdTypeContamination), WithContamination(0)},
+		},
+		{name: "negative_alg_type", opts: []Option{WithAlg("UNKNOWN")}},
+		{
+			name: "negative_threshold_rebuild_time",
+			opts: []Option{
+				WithThresholdType(ThresholdTypeContamination),
+				WithContamination(0.05),
+				WithThresholdRebuildTime(0),
+			},
+		},
+		{
+			name: "negative_threshold_sample_size",
+			opts: []Option{
+				WithThresholdType(ThresholdTypeContamination),
+				WithContamination(0.05),
+				WithThresholdSampleSize(1),
+			},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := New(test.opts...); err == nil {
+				t.Errorf("calling New, got: nil, expected: error")
+			}
+		})
+	}
+}
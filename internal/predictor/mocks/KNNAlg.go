@@ -34,6 +34,22 @@ func (_m *KNNAlg) Build(data ...predictor.DataPoint) {
 	_m.Called(_ca...)
 }
 
+// DataPoints provides a mock function with given fields:
+func (_m *KNNAlg) DataPoints() []predictor.DataPoint {
+	ret := _m.Called()
+
+	var r0 []predictor.DataPoint
+	if rf, ok := ret.Get(0).(func() []predictor.DataPoint); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]predictor.DataPoint)
+		}
+	}
+
+	return r0
+}
+
 // KNN provides a mock function with given fields: vec, k
 func (_m *KNNAlg) KNN(vec predictor.Point, k int) ([]predictor.Point, error) {
 	ret := _m.Called(vec, k)
@@ -12,13 +12,13 @@ type ProvideFn struct {
 	mock.Mock
 }
 
-// Execute provides a mock function with given fields:
-func (_m *ProvideFn) Execute() (predictor.Predictor, error) {
-	ret := _m.Called()
+// Execute provides a mock function with given fields: settings
+func (_m *ProvideFn) Execute(settings predictor.Settings) (predictor.Predictor, error) {
+	ret := _m.Called(settings)
 
 	var r0 predictor.Predictor
-	if rf, ok := ret.Get(0).(func() predictor.Predictor); ok {
-		r0 = rf()
+	if rf, ok := ret.Get(0).(func(predictor.Settings) predictor.Predictor); ok {
+		r0 = rf(settings)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(predictor.Predictor)
@@ -26,8 +26,8 @@ func (_m *ProvideFn) Execute() (predictor.Predictor, error) {
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func() error); ok {
-		r1 = rf()
+	if rf, ok := ret.Get(1).(func(predictor.Settings) error); ok {
+		r1 = rf(settings)
 	} else {
 		r1 = ret.Error(1)
 	}
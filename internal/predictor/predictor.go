@@ -1,10 +1,12 @@
 package predictor
 
 import (
+	"math"
 	"time"
 )
 
-type ProvideFn func() (Predictor, error)
+// ProvideFn returns the predictor instance, the zero settings keep the global configuration
+type ProvideFn func(settings Settings) (Predictor, error)
 
 type Point interface {
 	Dim(idx int) float64
@@ -25,14 +27,56 @@ type Predictor interface {
 	Predict(vec Point) (*Conclusion, error)
 }
 
+// Thresholder is implemented by predictors that can report the decision threshold in use
+type Thresholder interface {
+	Threshold() float64
+}
+
+// Closer is implemented by predictors running background jobs, e.g. the window cleanup or the threshold estimation,
+// the predictor is closed when it is replaced or the service stops
+type Closer interface {
+	Close()
+}
+
 type KNNAlg interface {
 	Reset()
 	Len() int
 	Build(data ...DataPoint)
 	Append(data ...DataPoint)
 	KNN(vec Point, k int) ([]Point, error)
+	DataPoints() []DataPoint
 }
 
 type Conclusion struct {
 	Outlier bool
+	// raw anomaly score computed by the predictor
+	Score float64
+	// the score above which the point is an outlier
+	Threshold float64
+	// normalized score in the range [0, 1], equal to 0.5 on the threshold
+	Confidence float64
+}
+
+// NewConclusion returns the conclusion for the score compared with the threshold
+func NewConclusion(score, threshold float64) *Conclusion {
+	if math.IsInf(score, 1) {
+		score = math.MaxFloat64
+	}
+	return &Conclusion{
+		Outlier:    score > threshold,
+		Score:      score,
+		Threshold:  threshold,
+		Confidence: confidence(score, threshold),
+	}
+}
+
+func confidence(score, threshold float64) float64 {
+	if threshold <= 0 || score < 0 {
+		if score > threshold {
+			return 1
+		}
+		return 0
+	}
+	ratio := score / threshold
+	return ratio / (1 + ratio)
 }
@@ -0,0 +1,39 @@
+package predictor
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNewConclusion(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name               string
+		score              float64
+		threshold          float64
+		expectedOutlier    bool
+		expectedConfidence float64
+	}{
+		{name: "positive_on_threshold", score: 1, threshold: 1, expectedOutlier: false, expectedConfidence: 0.5},
+		{name: "positive_outlier", score: 3, threshold: 1, expectedOutlier: true, expectedConfidence: 0.75},
+		{name: "positive_normal", score: 0, threshold: 0.5, expectedOutlier: false, expectedConfidence: 0},
+		{name: "positive_inf", score: math.Inf(1), threshold: 1, expectedOutlier: true, expectedConfidence: 1},
+		{name: "positive_zero_threshold", score: 0.1, threshold: 0, expectedOutlier: true, expectedConfidence: 1},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			conclusion := NewConclusion(test.score, test.threshold)
+			if conclusion.Outlier != test.expectedOutlier {
+				t.Errorf("compute Outlier, got: %v, expected: %v", conclusion.Outlier, test.expectedOutlier)
+			}
+			if math.Abs(conclusion.Confidence-test.expectedConfidence) > 1e-9 {
+				t.Errorf("compute Confidence, got: %v, expected: %v", conclusion.Confidence, test.expectedConfidence)
+			}
+			if conclusion.Confidence < 0 || conclusion.Confidence > 1 {
+				t.Errorf("compute Confidence, got: %v, expected value in range [0, 1]", conclusion.Confidence)
+			}
+		})
+	}
+}
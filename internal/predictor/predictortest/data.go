@@ -0,0 +1,42 @@
+// Package predictortest provides the datasets for the tests of the predictors.
+package predictortest
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/go-sod/sod/internal/geom"
+	"github.com/go-sod/sod/internal/predictor"
+)
+
+var _ predictor.DataPoint = DataPoint{}
+
+// DataPoint is the point of the test dataset
+type DataPoint struct {
+	Vec       geom.Point
+	CreatedAt time.Time
+}
+
+func (d DataPoint) Point() predictor.Point {
+	return d.Vec
+}
+
+func (d DataPoint) Time() time.Time {
+	return d.CreatedAt
+}
+
+// Cluster returns n points normally distributed around the center with the deviation,
+// the points are the same for the same arguments and are created a millisecond apart
+func Cluster(n int, center geom.Point, deviation float64) []predictor.DataPoint {
+	data := make([]predictor.DataPoint, n)
+	now := time.Now()
+	rnd := rand.New(rand.NewSource(1)) // nolint:gosec
+	for i := range data {
+		vec := make(geom.Point, len(center))
+		for j := range vec {
+			vec[j] = center[j] + rnd.NormFloat64()*deviation
+		}
+		data[i] = DataPoint{Vec: vec, CreatedAt: now.Add(time.Duration(i) * time.Millisecond)}
+	}
+	return data
+}
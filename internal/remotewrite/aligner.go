@@ -0,0 +1,189 @@
+package remotewrite
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-sod/sod/internal/geom"
+	"github.com/go-sod/sod/internal/metric/model"
+)
+
+const metricNameLabel = "__name__"
+
+func newAligner(mappings []Mapping, window time.Duration) (*aligner, error) {
+	if len(mappings) == 0 {
+		return nil, fmt.Errorf("remote write mappings are not defined")
+	}
+	for i := range mappings {
+		if err := mappings[i].compile(); err != nil {
+			return nil, fmt.Errorf("invalid remote write mapping: %w", err)
+		}
+	}
+	return &aligner{
+		mappings: mappings,
+		window:   window,
+		pending:  map[vectorKey]*pendingVector{},
+		emitted:  map[string]int64{},
+	}, nil
+}
+
+// aligner joins the samples of the series selected as the entity dimensions into vectors by timestamp.
+// The vector collects the samples for the window after its first sample, the series of the vector may come
+// in different requests of the remote write shards, then the vector is emitted until it is accepted
+type aligner struct {
+	mtx sync.Mutex
+
+	mappings []Mapping
+	window   time.Duration
+	pending  map[vectorKey]*pendingVector
+	// the timestamp of the last emitted vector of each entity, the older samples are dropped
+	emitted map[string]int64
+}
+
+type vectorKey struct {
+	entityID  string
+	timestamp int64
+}
+
+type pendingVector struct {
+	// the values of the series by the series labels for each dimension, summed up on completion
+	values  []map[string]float64
+	group   map[string]string
+	addedAt time.Time
+	// the vector is being collected, it is emitted again only if it is not accepted
+	emitting bool
+}
+
+func (v *pendingVector) complete() bool {
+	for i := range v.values {
+		if len(v.values[i]) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (v *pendingVector) vec() geom.Point {
+	vec := make(geom.Point, len(v.values))
+	for i := range v.values {
+		for _, value := range v.values[i] {
+			vec[i] += value
+		}
+	}
+	return vec
+}
+
+// add merges the series to the pending vectors and returns the vectors completed by the window
+func (a *aligner) add(list []timeSeries, now time.Time) []model.Metric {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	for _, ts := range list {
+		name := ts.labels[metricNameLabel]
+		for _, m := range a.mappings {
+			for di, dim := range m.Dimensions {
+				if !dim.Matches(name, ts.labels) {
+					continue
+				}
+				entityID, group := m.entity(ts.labels)
+				id := seriesID(ts.labels)
+				for _, s := range ts.samples {
+					// the stale markers and missing values do not make up the vector
+					if math.IsNaN(s.value) {
+						continue
+					}
+					key := vectorKey{entityID: entityID, timestamp: s.timestamp}
+					pv, ok := a.pending[key]
+					if !ok {
+						// the vector of the timestamp is accepted already
+						if emitted, ok := a.emitted[entityID]; ok && s.timestamp <= emitted {
+							continue
+						}
+						pv = &pendingVector{values: make([]map[string]float64, len(m.Dimensions)), group: group, addedAt: now}
+						for i := range pv.values {
+							pv.values[i] = map[string]float64{}
+						}
+						a.pending[key] = pv
+					}
+					pv.values[di][id] = s.value
+				}
+			}
+		}
+	}
+	return a.due(now)
+}
+
+// flush returns the vectors completed by the window
+func (a *aligner) flush(now time.Time) []model.Metric {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	return a.due(now)
+}
+
+// due emits the vectors collected for the window ordered by time, the vectors missing any dimension are dropped,
+// the emitted vectors are kept until they are done
+func (a *aligner) due(now time.Time) []model.Metric {
+	var metrics []model.Metric
+	for key, pv := range a.pending {
+		if pv.emitting || now.Sub(pv.addedAt) < a.window {
+			continue
+		}
+		if !pv.complete() {
+			delete(a.pending, key)
+			continue
+		}
+		pv.emitting = true
+		var extra interface{}
+		if pv.group != nil {
+			extra = pv.group
+		}
+		createdAt := time.Unix(0, key.timestamp*int64(time.Millisecond))
+		// the vectors of the resent samples are skipped as duplicates by the content key
+		metric := model.NewMetric(key.entityID, pv.vec(), createdAt, extra)
+		metrics = append(metrics, metric.WithKey(metric.ContentKey()))
+	}
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].CreatedAt.Before(metrics[j].CreatedAt)
+	})
+	return metrics
+}
+
+// done completes the emission of the vector, the accepted vector is removed and the older samples of the entity
+// are dropped, the rejected vector is emitted again with the retried samples
+func (a *aligner) done(metric model.Metric, accepted bool) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	key := vectorKey{entityID: metric.EntityID, timestamp: metric.CreatedAt.UnixNano() / int64(time.Millisecond)}
+	pv, ok := a.pending[key]
+	if !ok {
+		return
+	}
+	if !accepted {
+		pv.emitting = false
+		return
+	}
+	delete(a.pending, key)
+	if emitted, ok := a.emitted[key.entityID]; !ok || key.timestamp > emitted {
+		a.emitted[key.entityID] = key.timestamp
+	}
+}
+
+// seriesID returns the identity of the series from its sorted labels
+func seriesID(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(labels[name])
+		b.WriteByte(',')
+	}
+	return b.String()
+}
@@ -0,0 +1,65 @@
+package remotewrite
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-sod/sod/internal/series"
+)
+
+type Config struct {
+	Mappings Mappings `envconfig:"SOD_REMOTE_WRITE_MAPPINGS"`
+	// the vector collects the samples of the dimensions for the window, the incomplete vector is dropped after it
+	AlignWindow time.Duration `envconfig:"SOD_REMOTE_WRITE_ALIGN_WINDOW" default:"10s"`
+}
+
+type Mappings []Mapping
+
+func (ms *Mappings) Decode(value string) error {
+	mappings := []Mapping{}
+	if err := json.Unmarshal([]byte(value), &mappings); err != nil {
+		return err
+	}
+	*ms = mappings
+	return nil
+}
+
+// Mapping groups the series into the entities and selects the series making up the vector dimensions
+type Mapping struct {
+	EntityID string `json:"entityId"`
+	// the series with different values of the labels belong to different entities
+	GroupBy    []string          `json:"groupBy"`
+	Dimensions []series.Selector `json:"dimensions"`
+}
+
+func (m *Mapping) compile() error {
+	if m.EntityID == "" {
+		return fmt.Errorf("entity is not defined")
+	}
+	if len(m.Dimensions) == 0 {
+		return fmt.Errorf("dimensions of entity %s are not defined", m.EntityID)
+	}
+	for i := range m.Dimensions {
+		if err := m.Dimensions[i].Compile(); err != nil {
+			return fmt.Errorf("dimension %d of entity %s: %w", i, m.EntityID, err)
+		}
+	}
+	return nil
+}
+
+// entity returns the entity id of the series, the group by label values are appended separated by colon
+func (m Mapping) entity(labels map[string]string) (string, map[string]string) {
+	if len(m.GroupBy) == 0 {
+		return m.EntityID, nil
+	}
+	group := make(map[string]string, len(m.GroupBy))
+	parts := make([]string, 0, len(m.GroupBy)+1)
+	parts = append(parts, m.EntityID)
+	for _, name := range m.GroupBy {
+		group[name] = labels[name]
+		parts = append(parts, labels[name])
+	}
+	return strings.Join(parts, ":"), group
+}
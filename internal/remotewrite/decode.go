@@ -0,0 +1,158 @@
+package remotewrite
+
+import (
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// the field numbers of the prometheus.WriteRequest protobuf messages
+const (
+	writeRequestTimeseries = 1
+
+	timeSeriesLabels  = 1
+	timeSeriesSamples = 2
+
+	labelName  = 1
+	labelValue = 2
+
+	sampleValue     = 1
+	sampleTimestamp = 2
+)
+
+type timeSeries struct {
+	labels  map[string]string
+	samples []sample
+}
+
+type sample struct {
+	value float64
+	// milliseconds since epoch
+	timestamp int64
+}
+
+// decodeWriteRequest decodes the time series of the uncompressed prometheus.WriteRequest,
+// the metadata and exemplars are skipped
+func decodeWriteRequest(b []byte) ([]timeSeries, error) {
+	var list []timeSeries
+	err := walk(b, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if num != writeRequestTimeseries || typ != protowire.BytesType {
+			return nil
+		}
+		ts, err := decodeTimeSeries(v)
+		if err != nil {
+			return fmt.Errorf("decode time series: %w", err)
+		}
+		list = append(list, ts)
+		return nil
+	})
+	return list, err
+}
+
+func decodeTimeSeries(b []byte) (timeSeries, error) {
+	ts := timeSeries{labels: map[string]string{}}
+	err := walk(b, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case timeSeriesLabels:
+			name, value, err := decodeLabel(v)
+			if err != nil {
+				return fmt.Errorf("decode label: %w", err)
+			}
+			ts.labels[name] = value
+		case timeSeriesSamples:
+			s, err := decodeSample(v)
+			if err != nil {
+				return fmt.Errorf("decode sample: %w", err)
+			}
+			ts.samples = append(ts.samples, s)
+		}
+		return nil
+	})
+	return ts, err
+}
+
+func decodeLabel(b []byte) (string, string, error) {
+	var name, value string
+	err := walk(b, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case labelName:
+			name = string(v)
+		case labelValue:
+			value = string(v)
+		}
+		return nil
+	})
+	return name, value, err
+}
+
+func decodeSample(b []byte) (sample, error) {
+	var s sample
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return s, protowire.ParseError(n)
+		}
+		b = b[n:]
+		switch {
+		case num == sampleValue && typ == protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			if n < 0 {
+				return s, protowire.ParseError(n)
+			}
+			s.value = math.Float64frombits(v)
+			b = b[n:]
+		case num == sampleTimestamp && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return s, protowire.ParseError(n)
+			}
+			s.timestamp = int64(v)
+			b = b[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return s, protowire.ParseError(n)
+			}
+			b = b[n:]
+		}
+	}
+	return s, nil
+}
+
+// walk calls fn for every field of the message, the value of the length-delimited field is passed without the length
+func walk(b []byte, fn func(num protowire.Number, typ protowire.Type, v []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if typ == protowire.BytesType {
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			if err := fn(num, typ, v); err != nil {
+				return err
+			}
+			b = b[n:]
+			continue
+		}
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		if err := fn(num, typ, nil); err != nil {
+			return err
+		}
+		b = b[n:]
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package remotewrite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/go-sod/sod/internal/dispatcher"
+	"github.com/go-sod/sod/internal/httputil"
+	"github.com/go-sod/sod/internal/logging"
+	"github.com/go-sod/sod/internal/metric/model"
+	"github.com/golang/snappy"
+)
+
+const (
+	maxBodyBytes = 64 * 1024 * 1024
+	// flushInterval is the interval of the emission of the aligned vectors without the requests
+	flushInterval = time.Second
+)
+
+// NewHandler returns the handler receiving the Prometheus remote write requests,
+// the vectors aligned by the window are collected until the ctx is done
+func NewHandler(ctx context.Context, cfg *Config, outlier dispatcher.Collector) (http.Handler, error) {
+	a, err := newAligner(cfg.Mappings, cfg.AlignWindow)
+	if err != nil {
+		return nil, err
+	}
+	h := &handler{outlier: outlier, aligner: a}
+	go h.flusher(ctx)
+	return h, nil
+}
+
+type handler struct {
+	outlier dispatcher.Collector
+	aligner *aligner
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := logging.FromContext(ctx)
+
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		logger.Debugf(`{"error": "method %v is not allowed"}`, r.Method)
+		_, _ = fmt.Fprintf(w, `{"error": "method %v is not allowed"}`, r.Method)
+		return
+	}
+
+	defer r.Body.Close()
+
+	compressed, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		if err.Error() == "http: request body too large" {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		httputil.RespInternalErrorf(ctx, w, "failed to read request body: %v", err)
+		return
+	}
+
+	body, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		httputil.RespBadRequestErrorf(ctx, w, `{"error": "snappy decode error: %v"}`, err)
+		return
+	}
+
+	list, err := decodeWriteRequest(body)
+	if err != nil {
+		httputil.RespBadRequestErrorf(ctx, w, `{"error": "protobuf decode error: %v"}`, err)
+		return
+	}
+
+	// the retried request brings the samples of the rejected vectors only, the accepted ones are dropped
+	if accepted, err := h.collect(h.aligner.add(list, time.Now())); err != nil {
+		if errors.Is(err, dispatcher.ErrQueueFull) {
+			httputil.RespTooManyRequestsErrorf(ctx, w, `{"error": %q, "accepted": %d}`, err.Error(), accepted)
+			return
+		}
+		httputil.RespServiceUnavailableErrorf(ctx, w, `{"error": %q, "accepted": %d}`, err.Error(), accepted)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// collect sends the aligned vectors one by one, the duplicates of the resent samples are skipped.
+// The vectors of the entity after the rejected one are rejected too to keep the order,
+// the rejected vectors are emitted again, returns the number of the accepted vectors and the first error
+func (h *handler) collect(metrics []model.Metric) (int, error) {
+	var (
+		accepted int
+		firstErr error
+	)
+	rejected := map[string]bool{}
+	for i := range metrics {
+		if rejected[metrics[i].EntityID] {
+			h.aligner.done(metrics[i], false)
+			continue
+		}
+		if err := h.outlier.Collect(metrics[i]); err != nil && !errors.Is(err, dispatcher.ErrDuplicate) {
+			h.aligner.done(metrics[i], false)
+			rejected[metrics[i].EntityID] = true
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		h.aligner.done(metrics[i], true)
+		accepted++
+	}
+	return accepted, firstErr
+}
+
+// flusher emits the vectors completed by the window when no requests come
+func (h *handler) flusher(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			if _, err := h.collect(h.aligner.flush(now)); err != nil {
+				logger.Errorf("error sending to collect service: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-sod/sod/internal/dispatcher"
+	"github.com/go-sod/sod/internal/metric/model"
+	"github.com/go-sod/sod/internal/series"
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+type collector struct {
+	mtx     sync.Mutex
+	metrics []model.Metric
+	// the errors of the calls by the call number
+	failures map[int]error
+	calls    int
+}
+
+func (c *collector) Collect(in ...model.Metric) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.calls++
+	if err, ok := c.failures[c.calls]; ok {
+		return err
+	}
+	c.metrics = append(c.metrics, in...)
+	return nil
+}
+
+type testSeries struct {
+	labels  map[string]string
+	samples [][2]float64
+}
+
+// encodeWriteRequest encodes the series as prometheus.WriteRequest, the sample is the pair of timestamp and value
+func encodeWriteRequest(list []testSeries) []byte {
+	var req []byte
+	for _, ts := range list {
+		var b []byte
+		for name, value := range ts.labels {
+			var l []byte
+			l = protowire.AppendTag(l, labelName, protowire.BytesType)
+			l = protowire.AppendString(l, name)
+			l = protowire.AppendTag(l, labelValue, protowire.BytesType)
+			l = protowire.AppendString(l, value)
+			b = protowire.AppendTag(b, timeSeriesLabels, protowire.BytesType)
+			b = protowire.AppendBytes(b, l)
+		}
+		for _, s := range ts.samples {
+			var smp []byte
+			smp = protowire.AppendTag(smp, sampleValue, protowire.Fixed64Type)
+			smp = protowire.AppendFixed64(smp, math.Float64bits(s[1]))
+			smp = protowire.AppendTag(smp, sampleTimestamp, protowire.VarintType)
+			smp = protowire.AppendVarint(smp, uint64(s[0]))
+			b = protowire.AppendTag(b, timeSeriesSamples, protowire.BytesType)
+			b = protowire.AppendBytes(b, smp)
+		}
+		req = protowire.AppendTag(req, writeRequestTimeseries, protowire.BytesType)
+		req = protowire.AppendBytes(req, b)
+	}
+	return snappy.Encode(nil, req)
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+	cfg := &Config{
+		AlignWindow: time.Minute,
+		Mappings: Mappings{
+			{
+				EntityID: "api",
+				GroupBy:  []string{"instance"},
+				Dimensions: []series.Selector{
+					{Metric: "http_requests_total"},
+					{Metric: "memory_bytes"},
+				},
+			},
+		},
+	}
+	tests := []struct {
+		name     string
+		requests [][]testSeries
+		// the series sent after the vectors are emitted
+		late           []testSeries
+		expectedStatus int
+		expected       map[string][]float64
+	}{
+		{
+			name: "positive_aligned_by_timestamp",
+			requests: [][]testSeries{{
+				{
+					labels:  map[string]string{"__name__": "http_requests_total", "instance": "a", "code": "200"},
+					samples: [][2]float64{{1000, 10}, {2000, 11}},
+				},
+				{
+					labels:  map[string]string{"__name__": "http_requests_total", "instance": "a", "code": "500"},
+					samples: [][2]float64{{1000, 1}, {2000, 2}},
+				},
+				{
+					labels:  map[string]string{"__name__": "memory_bytes", "instance": "a"},
+					samples: [][2]float64{{1000, 512}},
+				},
+				{
+					labels:  map[string]string{"__name__": "unknown", "instance": "a"},
+					samples: [][2]float64{{1000, 1}},
+				},
+			}},
+			expectedStatus: http.StatusNoContent,
+			expected:       map[string][]float64{"api:a": {11, 512}},
+		},
+		{
+			name: "positive_aligned_across_requests",
+			requests: [][]testSeries{
+				{{
+					labels:  map[string]string{"__name__": "http_requests_total", "instance": "b"},
+					samples: [][2]float64{{1000, 3}},
+				}},
+				{{
+					labels:  map[string]string{"__name__": "memory_bytes", "instance": "b"},
+					samples: [][2]float64{{1000, 256}},
+				}},
+			},
+			expectedStatus: http.StatusNoContent,
+			expected:       map[string][]float64{"api:b": {3, 256}},
+		},
+		{
+			name: "positive_sharded_dimension",
+			requests: [][]testSeries{
+				{
+					{
+						labels:  map[string]string{"__name__": "http_requests_total", "instance": "c", "code": "200"},
+						samples: [][2]float64{{1000, 10}},
+					},
+					{
+						labels:  map[string]string{"__name__": "memory_bytes", "instance": "c"},
+						samples: [][2]float64{{1000, 128}},
+					},
+				},
+				{{
+					labels:  map[string]string{"__name__": "http_requests_total", "instance": "c", "code": "500"},
+					samples: [][2]float64{{1000, 2}},
+				}},
+			},
+			late: []testSeries{{
+				labels:  map[string]string{"__name__": "http_requests_total", "instance": "c", "code": "503"},
+				samples: [][2]float64{{1000, 5}},
+			}},
+			expectedStatus: http.StatusNoContent,
+			expected:       map[string][]float64{"api:c": {12, 128}},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			c := &collector{}
+			h, err := NewHandler(ctx, cfg, c)
+			if err != nil {
+				t.Fatalf("create handler: %v", err)
+			}
+			send := func(req []testSeries) {
+				w := httptest.NewRecorder()
+				r := httptest.NewRequest("POST", "/api/v1/write", bytes.NewReader(encodeWriteRequest(req)))
+				h.ServeHTTP(w, r)
+				if w.Code != test.expectedStatus {
+					t.Fatalf("remote write status, got: %d, expected: %d", w.Code, test.expectedStatus)
+				}
+			}
+			for _, req := range test.requests {
+				send(req)
+			}
+			// the vectors are emitted after the window
+			if len(c.metrics) != 0 {
+				t.Fatalf("collected metrics within the window, got: %v", c.metrics)
+			}
+			flush := func() {
+				if _, err := h.(*handler).collect(h.(*handler).aligner.flush(time.Now().Add(cfg.AlignWindow))); err != nil {
+					t.Fatalf("flush: %v", err)
+				}
+			}
+			flush()
+			if len(test.late) > 0 {
+				send(test.late)
+				flush()
+			}
+			if len(c.metrics) != len(test.expected) {
+				t.Fatalf("collected metrics, got: %v, expected: %v", c.metrics, test.expected)
+			}
+			for _, m := range c.metrics {
+				expected, ok := test.expected[m.EntityID]
+				if !ok {
+					t.Fatalf("unexpected entity %s", m.EntityID)
+				}
+				for i := range expected {
+					if m.CheckedVec[i] != expected[i] {
+						t.Errorf("vector of entity %s, got: %v, expected: %v", m.EntityID, m.CheckedVec, expected)
+					}
+				}
+				if !m.CreatedAt.Equal(time.Unix(1, 0)) {
+					t.Errorf("created at, got: %v, expected: %v", m.CreatedAt, time.Unix(1, 0))
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_Retry(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := &collector{failures: map[int]error{2: fmt.Errorf("entity api: %w", dispatcher.ErrQueueFull)}}
+	h, err := NewHandler(ctx, &Config{Mappings: Mappings{{EntityID: "api", Dimensions: []series.Selector{{Metric: "up"}}}}}, c)
+	if err != nil {
+		t.Fatalf("create handler: %v", err)
+	}
+	req := encodeWriteRequest([]testSeries{{
+		labels:  map[string]string{"__name__": "up"},
+		samples: [][2]float64{{1000, 1}, {2000, 2}, {3000, 3}},
+	}})
+
+	// the first vector is accepted, the rest are emitted again with the retried samples
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/write", bytes.NewReader(req)))
+	if w.Code != http.StatusTooManyRequests || !strings.Contains(w.Body.String(), `"accepted": 1`) {
+		t.Fatalf("remote write, got: %d %s, expected: %d", w.Code, w.Body.String(), http.StatusTooManyRequests)
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/write", bytes.NewReader(req)))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("remote write retry status, got: %d, expected: %d", w.Code, http.StatusNoContent)
+	}
+	if len(c.metrics) != 3 {
+		t.Fatalf("collected metrics, got: %v, expected: 3", c.metrics)
+	}
+	for i, m := range c.metrics {
+		if m.CheckedVec[0] != float64(i+1) {
+			t.Errorf("vector %d, got: %v, expected: %v", i, m.CheckedVec, []float64{float64(i + 1)})
+		}
+	}
+}
+
+func TestHandler_Malformed(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	h, err := NewHandler(ctx, &Config{Mappings: Mappings{{EntityID: "api", Dimensions: []series.Selector{{Metric: "up"}}}}}, &collector{})
+	if err != nil {
+		t.Fatalf("create handler: %v", err)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/write", bytes.NewReader([]byte("not snappy"))))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("remote write status, got: %d, expected: %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestNewHandler_NoMappings(t *testing.T) {
+	t.Parallel()
+	if _, err := NewHandler(context.Background(), &Config{}, &collector{}); err == nil {
+		t.Errorf("create handler without mappings, got: nil, expected: error")
+	}
+}
@@ -0,0 +1,10 @@
+package rpc
+
+type Config struct {
+	// Address of the grpc server, the server is disabled when empty
+	Addr string `envconfig:"SOD_GRPC_ADDR"`
+	// Maximum number of the points in the predict request
+	MaxDataItemsLen int `envconfig:"SOD_GRPC_MAX_DATA_ITEMS_LEN" default:"1000"`
+	// Maximum size of the received message
+	MaxRecvMsgSize int `envconfig:"SOD_GRPC_MAX_RECV_MSG_SIZE" default:"67108864"`
+}
@@ -0,0 +1,180 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/go-sod/sod/internal/dispatcher"
+	"github.com/go-sod/sod/internal/geom"
+	"github.com/go-sod/sod/internal/logging"
+	"github.com/go-sod/sod/internal/metric/model"
+	"github.com/go-sod/sod/internal/predict"
+	"github.com/go-sod/sod/pkg/sodpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// New returns the grpc server of the sod service calling the same collector and predictor as the http handlers
+func New(cfg *Config, outlier dispatcher.CollectPredictor) *grpc.Server {
+	srv := grpc.NewServer(grpc.MaxRecvMsgSize(cfg.MaxRecvMsgSize))
+	sodpb.RegisterSodServer(srv, &server{cfg: cfg, outlier: outlier})
+	return srv
+}
+
+// shutdownTimeout is the time the open streams are waited for after the context is done
+const shutdownTimeout = 5 * time.Second
+
+// Serve serves the grpc requests on the listener until the context is done
+func Serve(ctx context.Context, srv *grpc.Server, l net.Listener) error {
+	return serve(ctx, srv, l, shutdownTimeout)
+}
+
+// serve stops the server gracefully, the streams not finished within the timeout are closed
+func serve(ctx context.Context, srv *grpc.Server, l net.Listener, timeout time.Duration) error {
+	logger := logging.FromContext(ctx)
+	errCh := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		logger.Debugf("grpc server shutting down")
+		shutdownCtx, done := context.WithTimeout(context.Background(), timeout)
+		defer done()
+
+		stopped := make(chan struct{})
+		go func() {
+			srv.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-shutdownCtx.Done():
+			logger.Warnf("grpc server is not stopped gracefully within %s, closing the connections", timeout)
+			srv.Stop()
+		}
+		errCh <- nil
+	}()
+
+	logger.Infof("grpc server listening on %s", l.Addr())
+	if err := srv.Serve(l); err != nil {
+		return fmt.Errorf("failed to serve grpc: %w", err)
+	}
+	return <-errCh
+}
+
+type server struct {
+	sodpb.UnimplementedSodServer
+
+	cfg     *Config
+	outlier dispatcher.CollectPredictor
+}
+
+func (s *server) Collect(stream sodpb.Sod_CollectServer) error {
+	var accepted, duplicates uint64
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return stream.SendAndClose(&sodpb.CollectResponse{Accepted: accepted, Duplicates: duplicates})
+		}
+		if err != nil {
+			return err
+		}
+		if req.Entity == "" {
+			return status.Error(codes.InvalidArgument, "entity is not defined")
+		}
+
+		metrics := make([]model.Metric, len(req.Data))
+		for i, dat := range req.Data {
+			metrics[i] = model.NewMetric(req.Entity, geom.NewPoint(dat.Vector), createdAt(dat), extra(dat))
+			if dat.Id != "" {
+				metrics[i] = metrics[i].WithKey(dat.Id)
+			}
+		}
+		sort.SliceStable(metrics, func(i, j int) bool {
+			return metrics[i].CreatedAt.Before(metrics[j].CreatedAt)
+		})
+		for _, metric := range metrics {
+			if err := s.outlier.Collect(metric); err != nil {
+				if errors.Is(err, dispatcher.ErrDuplicate) {
+					duplicates++
+					continue
+				}
+				if errors.Is(err, dispatcher.ErrQueueFull) {
+					return status.Errorf(codes.ResourceExhausted, "collect: %v, accepted %d", err, accepted)
+				}
+				return status.Errorf(codes.Unavailable, "collect: %v", err)
+			}
+			accepted++
+		}
+	}
+}
+
+func (s *server) Predict(ctx context.Context, req *sodpb.PredictRequest) (*sodpb.PredictResponse, error) {
+	return s.predict(req)
+}
+
+func (s *server) PredictStream(stream sodpb.Sod_PredictStreamServer) error {
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		resp, err := s.predict(req)
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+// predict returns the conclusions in the order of the request points
+func (s *server) predict(req *sodpb.PredictRequest) (*sodpb.PredictResponse, error) {
+	if req.Entity == "" {
+		return nil, status.Error(codes.InvalidArgument, "entity is not defined")
+	}
+	if len(req.Data) > s.cfg.MaxDataItemsLen {
+		return nil, status.Errorf(codes.InvalidArgument, "data items is too large, max allowed len is %d", s.cfg.MaxDataItemsLen)
+	}
+
+	resp := &sodpb.PredictResponse{Entity: req.Entity, Results: make([]*sodpb.Conclusion, len(req.Data))}
+	for i, dat := range req.Data {
+		result, err := s.outlier.Predict(req.Entity, predict.DataPoint{
+			Vec:       geom.NewPoint(dat.Vector),
+			CreatedAt: createdAt(dat),
+		})
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "predict: %v", err)
+		}
+		resp.Results[i] = &sodpb.Conclusion{
+			Outlier:    result.Outlier,
+			Score:      result.Score,
+			Threshold:  result.Threshold,
+			Confidence: result.Confidence,
+			Point:      dat,
+		}
+	}
+	return resp, nil
+}
+
+func createdAt(dat *sodpb.DataPoint) time.Time {
+	if dat.CreatedAt == nil {
+		return time.Time{}
+	}
+	return dat.CreatedAt.AsTime()
+}
+
+func extra(dat *sodpb.DataPoint) interface{} {
+	if dat.Extra == nil {
+		return nil
+	}
+	return dat.Extra.AsInterface()
+}
@@ -0,0 +1,243 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-sod/sod/internal/metric/model"
+	"github.com/go-sod/sod/internal/predictor"
+	"github.com/go-sod/sod/pkg/sodpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type fakeOutlier struct {
+	mtx     sync.Mutex
+	metrics []model.Metric
+}
+
+func (f *fakeOutlier) Collect(in ...model.Metric) error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.metrics = append(f.metrics, in...)
+	return nil
+}
+
+// Predict marks the points with the first coordinate above 10 as outliers
+func (f *fakeOutlier) Predict(entityID string, in predictor.DataPoint) (*predictor.Conclusion, error) {
+	if entityID == "broken" {
+		return nil, errors.New("broken predictor")
+	}
+	score := in.Point().Points()[0] / 10
+	return &predictor.Conclusion{Outlier: score > 1, Score: score, Threshold: 1}, nil
+}
+
+func (f *fakeOutlier) Threshold(string) (float64, error) {
+	return 1, nil
+}
+
+func newTestClient(t *testing.T, outlier *fakeOutlier) sodpb.SodClient {
+	t.Helper()
+	l := bufconn.Listen(1024 * 1024)
+	srv := New(&Config{MaxDataItemsLen: 2, MaxRecvMsgSize: 1024 * 1024}, outlier)
+	go func() {
+		_ = srv.Serve(l)
+	}()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial(
+		"bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return l.DialContext(ctx)
+		}),
+		grpc.WithInsecure(),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return sodpb.NewSodClient(conn)
+}
+
+func point(v float64, createdAt time.Time) *sodpb.DataPoint {
+	return &sodpb.DataPoint{Vector: []float64{v, 1}, CreatedAt: timestamppb.New(createdAt)}
+}
+
+func TestServer_Collect(t *testing.T) {
+	t.Parallel()
+	outlier := &fakeOutlier{}
+	client := newTestClient(t, outlier)
+	now := time.Now().UTC()
+
+	stream, err := client.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	requests := []*sodpb.CollectRequest{
+		{Entity: "api", Data: []*sodpb.DataPoint{point(2, now.Add(time.Second)), point(1, now)}},
+		{Entity: "db", Data: []*sodpb.DataPoint{{Vector: []float64{3}, Extra: structpb.NewStringValue("host-1")}}},
+	}
+	for _, req := range requests {
+		if err := stream.Send(req); err != nil {
+			t.Fatalf("send: %v", err)
+		}
+	}
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatalf("close and recv: %v", err)
+	}
+	if resp.Accepted != 3 {
+		t.Errorf("accepted, got: %d, expected: 3", resp.Accepted)
+	}
+
+	outlier.mtx.Lock()
+	defer outlier.mtx.Unlock()
+	if len(outlier.metrics) != 3 {
+		t.Fatalf("collected, got: %d, expected: 3", len(outlier.metrics))
+	}
+	if outlier.metrics[0].CheckedVec[0] != 1 || outlier.metrics[1].CheckedVec[0] != 2 {
+		t.Errorf("points are not sorted by the time, got: %v %v", outlier.metrics[0].CheckedVec, outlier.metrics[1].CheckedVec)
+	}
+	if outlier.metrics[2].EntityID != "db" || outlier.metrics[2].Extra != "host-1" {
+		t.Errorf("collected, got: %s %v, expected: db host-1", outlier.metrics[2].EntityID, outlier.metrics[2].Extra)
+	}
+}
+
+func TestServer_Predict(t *testing.T) {
+	t.Parallel()
+	client := newTestClient(t, &fakeOutlier{})
+	now := time.Now()
+
+	tests := []struct {
+		name         string
+		req          *sodpb.PredictRequest
+		expectedCode codes.Code
+		expected     []bool
+	}{
+		{
+			name:     "positive_ordered_results",
+			req:      &sodpb.PredictRequest{Entity: "api", Data: []*sodpb.DataPoint{point(20, now), point(5, now)}},
+			expected: []bool{true, false},
+		},
+		{
+			name:         "negative_without_entity",
+			req:          &sodpb.PredictRequest{Data: []*sodpb.DataPoint{point(1, now)}},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "negative_too_many_points",
+			req:          &sodpb.PredictRequest{Entity: "api", Data: []*sodpb.DataPoint{point(1, now), point(2, now), point(3, now)}},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "negative_predictor_error",
+			req:          &sodpb.PredictRequest{Entity: "broken", Data: []*sodpb.DataPoint{point(1, now)}},
+			expectedCode: codes.Internal,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			resp, err := client.Predict(context.Background(), test.req)
+			if status.Code(err) != test.expectedCode {
+				t.Fatalf("code, got: %v, expected: %v", status.Code(err), test.expectedCode)
+			}
+			if err != nil {
+				return
+			}
+			if len(resp.Results) != len(test.expected) {
+				t.Fatalf("results, got: %d, expected: %d", len(resp.Results), len(test.expected))
+			}
+			for i, outlier := range test.expected {
+				if resp.Results[i].Outlier != outlier {
+					t.Errorf("result %d outlier, got: %v, expected: %v", i, resp.Results[i].Outlier, outlier)
+				}
+				if resp.Results[i].Point.Vector[0] != test.req.Data[i].Vector[0] {
+					t.Errorf("result %d point, got: %v, expected: %v", i, resp.Results[i].Point.Vector, test.req.Data[i].Vector)
+				}
+			}
+		})
+	}
+}
+
+func TestServer_PredictStream(t *testing.T) {
+	t.Parallel()
+	client := newTestClient(t, &fakeOutlier{})
+	stream, err := client.PredictStream(context.Background())
+	if err != nil {
+		t.Fatalf("predict stream: %v", err)
+	}
+	for _, v := range []float64{30, 1} {
+		if err := stream.Send(&sodpb.PredictRequest{Entity: "api", Data: []*sodpb.DataPoint{point(v, time.Now())}}); err != nil {
+			t.Fatalf("send: %v", err)
+		}
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("recv: %v", err)
+		}
+		if len(resp.Results) != 1 || resp.Results[0].Outlier != (v > 10) {
+			t.Errorf("result of %v, got: %v", v, resp.Results)
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatalf("close send: %v", err)
+	}
+}
+
+func TestServe_Shutdown(t *testing.T) {
+	t.Parallel()
+	l := bufconn.Listen(1024 * 1024)
+	srv := New(&Config{MaxDataItemsLen: 2, MaxRecvMsgSize: 1024 * 1024}, &fakeOutlier{})
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(ctx, srv, l, 100*time.Millisecond)
+	}()
+
+	conn, err := grpc.Dial(
+		"bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return l.DialContext(ctx)
+		}),
+		grpc.WithInsecure(),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	// the open stream blocks the graceful stop, the server is stopped after the timeout
+	stream, err := sodpb.NewSodClient(conn).PredictStream(context.Background())
+	if err != nil {
+		t.Fatalf("predict stream: %v", err)
+	}
+	if err := stream.Send(&sodpb.PredictRequest{Entity: "api", Data: []*sodpb.DataPoint{point(1, time.Now())}}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("recv: %v", err)
+	}
+
+	cancel()
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("serve: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("server is not stopped")
+	}
+	if _, err := stream.Recv(); err == nil {
+		t.Errorf("stream is not closed")
+	}
+}
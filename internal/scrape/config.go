@@ -2,13 +2,17 @@ package scrape
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
+
+	"github.com/go-sod/sod/internal/series"
 )
 
 type Config struct {
 	Targets              Targets       `envconfig:"SOD_SCRAPE_TARGET_URLS"`
 	MaxConcurrentRequest int           `envconfig:"SOD_SCRAPE_MAX_CONCURRENT_REQUEST" default:"64"`
 	Interval             time.Duration `envconfig:"SOD_SCRAPE_INTERVAL" default:"1s"`
+	RequestTimeout       time.Duration `envconfig:"SOD_SCRAPE_REQUEST_TIMEOUT" default:"10s"`
 }
 
 type Targets []Target
@@ -22,7 +26,54 @@ func (ts *Targets) Decode(value string) error {
 	return nil
 }
 
+type TargetType string
+
+const (
+	// the target responds with the SOD json data
+	TargetTypeSOD TargetType = "SOD"
+	// the target exposes the metrics in the Prometheus text format
+	TargetTypePrometheus TargetType = "PROMETHEUS"
+)
+
 type Target struct {
-	URL      string `json:"url"`
-	EntityID string `json:"entityId"`
+	URL      string     `json:"url"`
+	EntityID string     `json:"entityId"`
+	Type     TargetType `json:"type"`
+	// the dimensions of the entity vector built from the Prometheus series
+	Dimensions []Dimension `json:"dimensions"`
 }
+
+func (t Target) validate() error {
+	switch t.Type {
+	case "", TargetTypeSOD:
+		return nil
+	case TargetTypePrometheus:
+		if t.EntityID == "" {
+			return fmt.Errorf("entity of target %s is not defined", t.URL)
+		}
+		if len(t.Dimensions) == 0 {
+			return fmt.Errorf("dimensions of target %s are not defined", t.URL)
+		}
+		for i := range t.Dimensions {
+			if err := t.Dimensions[i].Compile(); err != nil {
+				return fmt.Errorf("dimension %d of target %s: %w", i, t.URL, err)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown type %s of target %s", t.Type, t.URL)
+	}
+}
+
+// Dimension is the value of the series selected by the metric name and the label matchers,
+// the values of several matching series are summed up
+type Dimension = series.Selector
+
+type LabelMatcher = series.Matcher
+
+const (
+	MatchEqual     = series.MatchEqual
+	MatchNotEqual  = series.MatchNotEqual
+	MatchRegexp    = series.MatchRegexp
+	MatchNotRegexp = series.MatchNotRegexp
+)
@@ -0,0 +1,127 @@
+package scrape
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+const prometheusAccept = "text/plain;version=0.0.4;q=1,*/*;q=0.1"
+
+// sample is the single series value of the Prometheus text format
+type sample struct {
+	name   string
+	labels map[string]string
+	value  float64
+}
+
+// parsePrometheus builds the vector of the dimensions from the Prometheus text format
+func parsePrometheus(r io.Reader, dimensions []Dimension) ([]float64, error) {
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(r)
+	if err != nil {
+		return nil, fmt.Errorf("parsing prometheus text format error: %w", err)
+	}
+
+	samples := map[string][]sample{}
+	for _, family := range families {
+		for _, s := range flatten(family) {
+			samples[s.name] = append(samples[s.name], s)
+		}
+	}
+
+	vec := make([]float64, len(dimensions))
+	for i, dim := range dimensions {
+		found := false
+		for _, s := range samples[dim.Metric] {
+			if !dim.Matches(s.name, s.labels) {
+				continue
+			}
+			vec[i] += s.value
+			found = true
+		}
+		if !found {
+			return nil, fmt.Errorf("no series found for dimension %d, metric %s", i, dim.Metric)
+		}
+	}
+	return vec, nil
+}
+
+// flatten expands the metric family to the series as they are exposed,
+// histograms and summaries produce the _bucket, quantile, _sum and _count series
+func flatten(family *dto.MetricFamily) []sample {
+	var samples []sample
+	name := family.GetName()
+	for _, m := range family.GetMetric() {
+		labels := make(map[string]string, len(m.GetLabel()))
+		for _, l := range m.GetLabel() {
+			labels[l.GetName()] = l.GetValue()
+		}
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			samples = append(samples, sample{name: name, labels: labels, value: m.GetCounter().GetValue()})
+		case dto.MetricType_GAUGE:
+			samples = append(samples, sample{name: name, labels: labels, value: m.GetGauge().GetValue()})
+		case dto.MetricType_UNTYPED:
+			samples = append(samples, sample{name: name, labels: labels, value: m.GetUntyped().GetValue()})
+		case dto.MetricType_SUMMARY:
+			summary := m.GetSummary()
+			for _, q := range summary.GetQuantile() {
+				samples = append(samples, sample{
+					name:   name,
+					labels: withLabel(labels, "quantile", formatFloat(q.GetQuantile())),
+					value:  q.GetValue(),
+				})
+			}
+			samples = append(
+				samples,
+				sample{name: name + "_sum", labels: labels, value: summary.GetSampleSum()},
+				sample{name: name + "_count", labels: labels, value: float64(summary.GetSampleCount())},
+			)
+		case dto.MetricType_HISTOGRAM:
+			histogram := m.GetHistogram()
+			hasInf := false
+			for _, b := range histogram.GetBucket() {
+				hasInf = hasInf || math.IsInf(b.GetUpperBound(), 1)
+				samples = append(samples, sample{
+					name:   name + "_bucket",
+					labels: withLabel(labels, "le", formatFloat(b.GetUpperBound())),
+					value:  float64(b.GetCumulativeCount()),
+				})
+			}
+			if !hasInf {
+				samples = append(samples, sample{
+					name:   name + "_bucket",
+					labels: withLabel(labels, "le", "+Inf"),
+					value:  float64(histogram.GetSampleCount()),
+				})
+			}
+			samples = append(
+				samples,
+				sample{name: name + "_sum", labels: labels, value: histogram.GetSampleSum()},
+				sample{name: name + "_count", labels: labels, value: float64(histogram.GetSampleCount())},
+			)
+		}
+	}
+	return samples
+}
+
+func withLabel(labels map[string]string, name, value string) map[string]string {
+	copied := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		copied[k] = v
+	}
+	copied[name] = value
+	return copied
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
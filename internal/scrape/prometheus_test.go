@@ -0,0 +1,92 @@
+package scrape
+
+import (
+	"strings"
+	"testing"
+)
+
+const exposition = `# HELP http_requests_total The total number of HTTP requests.
+# TYPE http_requests_total counter
+http_requests_total{method="GET",code="200"} 1027
+http_requests_total{method="GET",code="500"} 3
+http_requests_total{method="POST",code="200"} 100
+# HELP queue_length The current queue length.
+# TYPE queue_length gauge
+queue_length 12
+# HELP request_duration_seconds The request latencies.
+# TYPE request_duration_seconds histogram
+request_duration_seconds_bucket{le="0.1"} 5
+request_duration_seconds_bucket{le="1"} 8
+request_duration_seconds_bucket{le="+Inf"} 10
+request_duration_seconds_sum 7.5
+request_duration_seconds_count 10
+`
+
+func TestParsePrometheus(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		dimensions  []Dimension
+		expected    []float64
+		expectedErr bool
+	}{
+		{
+			name: "positive_equal_matchers",
+			dimensions: []Dimension{
+				{Metric: "http_requests_total", Matchers: []LabelMatcher{{Name: "method", Value: "GET"}, {Name: "code", Value: "200"}}},
+				{Metric: "queue_length"},
+			},
+			expected: []float64{1027, 12},
+		},
+		{
+			name: "positive_summed_series",
+			dimensions: []Dimension{
+				{Metric: "http_requests_total", Matchers: []LabelMatcher{{Name: "code", Op: MatchRegexp, Value: "5.."}}},
+				{Metric: "http_requests_total", Matchers: []LabelMatcher{{Name: "method", Op: MatchNotEqual, Value: "POST"}}},
+			},
+			expected: []float64{3, 1030},
+		},
+		{
+			name: "positive_histogram",
+			dimensions: []Dimension{
+				{Metric: "request_duration_seconds_bucket", Matchers: []LabelMatcher{{Name: "le", Value: "1"}}},
+				{Metric: "request_duration_seconds_bucket", Matchers: []LabelMatcher{{Name: "le", Value: "+Inf"}}},
+				{Metric: "request_duration_seconds_sum"},
+				{Metric: "request_duration_seconds_count"},
+			},
+			expected: []float64{8, 10, 7.5, 10},
+		},
+		{
+			name: "negative_series_not_found",
+			dimensions: []Dimension{
+				{Metric: "http_requests_total", Matchers: []LabelMatcher{{Name: "method", Value: "PUT"}}},
+			},
+			expectedErr: true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			target := Target{URL: "http://localhost", EntityID: "test", Type: TargetTypePrometheus, Dimensions: test.dimensions}
+			if err := target.validate(); err != nil {
+				t.Fatalf("validate target: %v", err)
+			}
+			vec, err := parsePrometheus(strings.NewReader(exposition), target.Dimensions)
+			if (err != nil) != test.expectedErr {
+				t.Fatalf("parse prometheus, got error: %v, expected error: %v", err, test.expectedErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(vec) != len(test.expected) {
+				t.Fatalf("parse prometheus, got: %v, expected: %v", vec, test.expected)
+			}
+			for i := range vec {
+				if vec[i] != test.expected[i] {
+					t.Errorf("parse prometheus, got: %v, expected: %v", vec, test.expected)
+				}
+			}
+		})
+	}
+}
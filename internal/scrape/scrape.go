@@ -5,6 +5,7 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -18,6 +19,7 @@ import (
 	"github.com/go-sod/sod/internal/geom"
 	"github.com/go-sod/sod/internal/logging"
 	"github.com/go-sod/sod/internal/metric/model"
+	"github.com/go-sod/sod/internal/telemetry"
 	"github.com/go-sod/sod/pkg/rworker"
 )
 
@@ -61,6 +63,12 @@ func WithInterval(t time.Duration) Option {
 	}
 }
 
+func WithRequestTimeout(t time.Duration) Option {
+	return func(o *manager) {
+		o.opts.requestTimeout = t
+	}
+}
+
 func WithTargetUrls(m Targets) Option {
 	return func(o *manager) {
 		o.targets = m
@@ -79,6 +87,11 @@ func New(outlier dispatcher.Manager, shutdownCh chan<- error, opts ...Option) (*
 	for _, opt := range opts {
 		opt(m)
 	}
+	for i := range m.targets {
+		if err := m.targets[i].validate(); err != nil {
+			return nil, fmt.Errorf("invalid scrape target: %w", err)
+		}
+	}
 	m.client = &http.Client{
 		Transport: &http.Transport{
 			TLSHandshakeTimeout:   m.opts.tlsHandshakeTimeout,
@@ -129,20 +142,20 @@ func (s *manager) Run(ctx context.Context) error {
 	return nil
 }
 
-func (s *manager) scrape(url string) (response, error) {
-	var response response
+// fetch requests the target and returns the decoded response body
+func (s *manager) fetch(url, accept string) ([]byte, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), s.opts.requestTimeout)
 	defer cancel()
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return response, fmt.Errorf("creating request error: %w", err)
+		return nil, fmt.Errorf("creating request error: %w", err)
 	}
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", accept)
 	req.Header.Add("User-Agent", UserAgent)
 	req.Header.Add("Accept-Encoding", "gzip")
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return response, fmt.Errorf("sending request error: %w", err)
+		return nil, fmt.Errorf("sending request error: %w", err)
 	}
 
 	defer resp.Body.Close()
@@ -150,22 +163,33 @@ func (s *manager) scrape(url string) (response, error) {
 	var reader io.ReadCloser
 	switch resp.Header.Get("Content-Encoding") {
 	case "gzip":
-		reader, err := gzip.NewReader(resp.Body)
+		gzipReader, err := gzip.NewReader(resp.Body)
 		if err != nil {
-			return response, fmt.Errorf("unable create gzip.NewReader: %w", err)
+			return nil, fmt.Errorf("unable create gzip.NewReader: %w", err)
 		}
-		defer reader.Close()
+		defer gzipReader.Close()
+		reader = gzipReader
 	default:
 		reader = resp.Body
 	}
 
 	body, err := ioutil.ReadAll(reader)
 	if err != nil {
-		return response, fmt.Errorf("failed to read response body: %w", err)
+		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return response, fmt.Errorf("response was not 200 OK: %s", body)
+		return nil, fmt.Errorf("response was not 200 OK: %s", body)
+	}
+
+	return body, nil
+}
+
+func (s *manager) scrape(url string) (response, error) {
+	var response response
+	body, err := s.fetch(url, "application/json")
+	if err != nil {
+		return response, err
 	}
 
 	decoder := json.NewDecoder(bytes.NewReader(body))
@@ -176,6 +200,20 @@ func (s *manager) scrape(url string) (response, error) {
 	return response, nil
 }
 
+// scrapePrometheus returns the entity vector built from the target series, the scrape time is the vector time
+func (s *manager) scrapePrometheus(url string, target Target) (model.Metric, error) {
+	createdAt := time.Now()
+	body, err := s.fetch(url, prometheusAccept)
+	if err != nil {
+		return model.Metric{}, err
+	}
+	vec, err := parsePrometheus(bytes.NewReader(body), target.Dimensions)
+	if err != nil {
+		return model.Metric{}, err
+	}
+	return model.NewMetric(target.EntityID, geom.NewPoint(vec), createdAt, nil), nil
+}
+
 func (s *manager) scrapping(ctx context.Context) {
 	wg := sync.WaitGroup{}
 	logger := logging.FromContext(ctx)
@@ -183,26 +221,31 @@ func (s *manager) scrapping(ctx context.Context) {
 	defer close(errCh)
 	rateCh := make(chan struct{}, s.opts.maxConcurrentRequest)
 	defer close(rateCh)
-	for err := range errCh {
-		logger.Errorf("scrape manager error: %v", err)
-	}
+	go func() {
+		for err := range errCh {
+			logger.Errorf("scrape manager error: %v", err)
+		}
+	}()
 OuterLoop:
-	for _, link := range s.targets {
-		urlData, err := url.Parse(link.URL)
+	for _, target := range s.targets {
+		target := target
+		urlData, err := url.Parse(target.URL)
 		if err != nil {
 			errCh <- fmt.Errorf("url parsing error: %w", err)
 			continue OuterLoop
 		}
 		rworker.Job(&wg, func() error {
-			resp, err := s.scrape(urlData.String())
+			start := time.Now()
+			metrics, err := s.scrapeTarget(urlData.String(), target)
+			telemetry.ScrapeDuration.WithLabelValues(urlData.String()).Observe(time.Since(start).Seconds())
+			telemetry.ScrapeTargetUp.WithLabelValues(urlData.String()).Set(up(err))
 			if err != nil {
 				return fmt.Errorf("scrape error: %w", err)
 			}
-			sort.Slice(resp.Data, func(i, j int) bool {
-				return resp.Data[i].CreatedAt.Before(resp.Data[j].CreatedAt)
-			})
-			for _, dat := range resp.Data {
-				if err := s.outlier.Collect(model.NewMetric(resp.EntityID, geom.NewPoint(dat.Vec), dat.CreatedAt, dat.Extra)); err != nil {
+			for i := range metrics {
+				// the metrics of the overlapping scrapes are skipped as duplicates by the content key
+				keyed := metrics[i].WithKey(metrics[i].ContentKey())
+				if err := s.outlier.Collect(keyed); err != nil && !errors.Is(err, dispatcher.ErrDuplicate) {
 					return fmt.Errorf("send to collect error: %w", err)
 				}
 			}
@@ -211,3 +254,33 @@ OuterLoop:
 	}
 	wg.Wait()
 }
+
+// scrapeTarget returns the target metrics ordered by creation time
+func (s *manager) scrapeTarget(url string, target Target) ([]model.Metric, error) {
+	if target.Type == TargetTypePrometheus {
+		metric, err := s.scrapePrometheus(url, target)
+		if err != nil {
+			return nil, err
+		}
+		return []model.Metric{metric}, nil
+	}
+	resp, err := s.scrape(url)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(resp.Data, func(i, j int) bool {
+		return resp.Data[i].CreatedAt.Before(resp.Data[j].CreatedAt)
+	})
+	metrics := make([]model.Metric, len(resp.Data))
+	for i, dat := range resp.Data {
+		metrics[i] = model.NewMetric(resp.EntityID, geom.NewPoint(dat.Vec), dat.CreatedAt, dat.Extra)
+	}
+	return metrics, nil
+}
+
+func up(err error) float64 {
+	if err != nil {
+		return 0
+	}
+	return 1
+}
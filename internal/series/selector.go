@@ -0,0 +1,86 @@
+package series
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Selector selects the series by the metric name and the label matchers as the Prometheus instant vector selector
+type Selector struct {
+	Metric   string    `json:"metric"`
+	Matchers []Matcher `json:"matchers"`
+}
+
+// Compile validates the selector and prepares the regexp matchers
+func (s *Selector) Compile() error {
+	if s.Metric == "" {
+		return fmt.Errorf("metric name is not defined")
+	}
+	for i := range s.Matchers {
+		if err := s.Matchers[i].compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Matches reports whether the series is selected, the missing label is matched as the empty value
+func (s Selector) Matches(name string, labels map[string]string) bool {
+	if name != s.Metric {
+		return false
+	}
+	for _, m := range s.Matchers {
+		if !m.matches(labels[m.Name]) {
+			return false
+		}
+	}
+	return true
+}
+
+type MatchType string
+
+const (
+	MatchEqual     MatchType = "="
+	MatchNotEqual  MatchType = "!="
+	MatchRegexp    MatchType = "=~"
+	MatchNotRegexp MatchType = "!~"
+)
+
+type Matcher struct {
+	Name string `json:"name"`
+	// the equal match is used by default
+	Op    MatchType `json:"op"`
+	Value string    `json:"value"`
+	re    *regexp.Regexp
+}
+
+func (m *Matcher) compile() error {
+	switch m.Op {
+	case "":
+		m.Op = MatchEqual
+	case MatchEqual, MatchNotEqual:
+	case MatchRegexp, MatchNotRegexp:
+		// the regexp is anchored as in the Prometheus selectors
+		re, err := regexp.Compile("^(?:" + m.Value + ")$")
+		if err != nil {
+			return fmt.Errorf("label %s matcher: %w", m.Name, err)
+		}
+		m.re = re
+	default:
+		return fmt.Errorf("label %s: unknown match type %s", m.Name, m.Op)
+	}
+	return nil
+}
+
+func (m Matcher) matches(value string) bool {
+	switch m.Op {
+	case MatchNotEqual:
+		return value != m.Value
+	case MatchRegexp:
+		return m.re.MatchString(value)
+	case MatchNotRegexp:
+		return !m.re.MatchString(value)
+	default:
+		return value == m.Value
+	}
+}
@@ -3,15 +3,18 @@ package setup
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/go-sod/sod/internal/alert"
 	"github.com/go-sod/sod/internal/database"
 	"github.com/go-sod/sod/internal/dispatcher"
 	"github.com/go-sod/sod/internal/logging"
 	"github.com/go-sod/sod/internal/predictor"
+	"github.com/go-sod/sod/internal/predictor/iforest"
 	"github.com/go-sod/sod/internal/predictor/lof"
 	"github.com/go-sod/sod/internal/scrape"
 	"github.com/go-sod/sod/internal/srvenv"
+	"github.com/go-sod/sod/internal/timeutil"
 	"github.com/kelseyhightower/envconfig"
 )
 
@@ -136,6 +139,7 @@ func ProvideScrapperFor(provider ScrapeConfigProvider) (scrape.ProvideFn, error)
 			outlier,
 			shutdownCh,
 			scrape.WithInterval(cfg.Interval),
+			scrape.WithRequestTimeout(cfg.RequestTimeout),
 			scrape.WithMaxConcurrentRequest(cfg.MaxConcurrentRequest),
 			scrape.WithTargetUrls(cfg.Targets),
 		)
@@ -154,6 +158,13 @@ func ProvideNotifierFor(provider NotifierConfigProvider, db *database.DB) (alert
 			alert.WithMaxConcurrentRequest(cfg.MaxConcurrentRequest),
 			alert.WithScrapeInterval(cfg.Interval),
 			alert.WithTargets(cfg.Targets),
+			alert.WithSinks(cfg.Sinks),
+			alert.WithRoutes(cfg.Routes),
+			alert.WithRequestTimeout(cfg.RequestTimeout),
+			alert.WithMaxRetries(cfg.MaxRetries),
+			alert.WithBackoff(cfg.InitialBackoff, cfg.MaxBackoff),
+			alert.WithResolveAfter(cfg.ResolveAfter),
+			alert.WithHistoryRetention(cfg.HistoryRetention),
 		)
 	}, nil
 }
@@ -181,6 +192,14 @@ func ProvideOutlierFor(
 			dispatcher.WithSkipItems(cfg.SkipItems),
 			dispatcher.WithDBFlushSize(cfg.DBFlushSize),
 			dispatcher.WithDBFlushTime(cfg.DBFlushTime),
+			dispatcher.WithAlertRules(cfg.AlertRules),
+			dispatcher.WithQueueCapacity(cfg.QueueCapacity),
+			dispatcher.WithQueueOverflow(cfg.QueueOverflow),
+			dispatcher.WithQueueBlockTimeout(cfg.QueueBlockTimeout),
+			dispatcher.WithWorkers(cfg.Workers),
+			dispatcher.WithDedupKey(cfg.DedupKey),
+			dispatcher.WithAllowedLateness(cfg.AllowedLateness),
+			dispatcher.WithLatePolicy(cfg.LatePolicy),
 		)
 	}, nil
 }
@@ -192,25 +211,85 @@ func ProvidePredictorFor(cfg *predictor.Config, outlierCfg *dispatcher.Config) (
 		if err := envconfig.Process("", &cfgLof); err != nil {
 			return nil, fmt.Errorf("error loading environment variables: %w", err)
 		}
-		distFunc, err := lof.DistanceFuncFor(cfgLof.MetricFuncType)
-		if err != nil {
+		if _, err := lof.DistanceFuncFor(cfgLof.MetricFuncType); err != nil {
 			return nil, fmt.Errorf("unable provide distance function: %w", err)
 		}
-		return func() (predictor.Predictor, error) {
+		return func(s predictor.Settings) (predictor.Predictor, error) {
+			distFunc, err := lof.DistanceFuncFor(lof.DistanceFuncType(stringOr(s.DistanceFunc, string(cfgLof.MetricFuncType))))
+			if err != nil {
+				return nil, fmt.Errorf("unable provide distance function: %w", err)
+			}
+			kNum := intOr(s.KNum, cfgLof.KNum)
+			if kNum < lof.MinKNum {
+				return nil, fmt.Errorf("k num %d is less than %d", kNum, lof.MinKNum)
+			}
 			l, err := lof.New(
-				lof.WithSkipItems(cfgLof.SkipItems),
-				lof.WithKNum(cfgLof.KNum),
+				lof.WithSkipItems(intOr(s.SkipItems, cfgLof.SkipItems)),
+				lof.WithKNum(kNum),
 				lof.WithDistance(distFunc),
-				lof.WithStorageTime(outlierCfg.MaxStorageTime),
-				lof.WithMaxItems(outlierCfg.MaxItemsStored),
-				lof.WithAlg(cfgLof.AlgType),
+				lof.WithStorageTime(durationOr(s.MaxStorageTime, outlierCfg.MaxStorageTime)),
+				lof.WithMaxItems(intOr(s.MaxItemsStored, outlierCfg.MaxItemsStored)),
+				lof.WithAlg(lof.AlgType(stringOr(s.AlgType, string(cfgLof.AlgType)))),
+				lof.WithThresholdType(lof.ThresholdType(stringOr(s.ThresholdType, string(cfgLof.ThresholdType)))),
+				lof.WithThreshold(floatOr(s.Threshold, cfgLof.Threshold)),
+				lof.WithContamination(floatOr(s.Contamination, cfgLof.Contamination)),
+				lof.WithThresholdSampleSize(cfgLof.ThresholdSampleSize),
+				lof.WithThresholdRebuildTime(cfgLof.ThresholdRebuildTime),
+				lof.WithHNSWParams(cfgLof.HNSWM, cfgLof.HNSWEfConstruction, cfgLof.HNSWEfSearch),
 			)
 			if err != nil {
 				return nil, fmt.Errorf("unable create lof instance: %w", err)
 			}
 			return l, nil
 		}, nil
+	case predictor.AlgIsolationForest:
+		cfgForest := iforest.Config{}
+		if err := envconfig.Process("", &cfgForest); err != nil {
+			return nil, fmt.Errorf("error loading environment variables: %w", err)
+		}
+		return func(s predictor.Settings) (predictor.Predictor, error) {
+			f, err := iforest.New(
+				iforest.WithSkipItems(intOr(s.SkipItems, cfgForest.SkipItems)),
+				iforest.WithTreesNum(cfgForest.TreesNum),
+				iforest.WithSubsampleSize(cfgForest.SubsampleSize),
+				iforest.WithContamination(floatOr(s.Contamination, cfgForest.Contamination)),
+				iforest.WithStorageTime(durationOr(s.MaxStorageTime, outlierCfg.MaxStorageTime)),
+				iforest.WithMaxItems(intOr(s.MaxItemsStored, outlierCfg.MaxItemsStored)),
+			)
+			if err != nil {
+				return nil, fmt.Errorf("unable create isolation forest instance: %w", err)
+			}
+			return f, nil
+		}, nil
 	default:
 		return nil, fmt.Errorf("unknown predictor type: %s", cfg.PredictorType())
 	}
 }
+
+func intOr(v *int, def int) int {
+	if v != nil {
+		return *v
+	}
+	return def
+}
+
+func floatOr(v *float64, def float64) float64 {
+	if v != nil {
+		return *v
+	}
+	return def
+}
+
+func stringOr(v *string, def string) string {
+	if v != nil {
+		return *v
+	}
+	return def
+}
+
+func durationOr(v *timeutil.Duration, def time.Duration) time.Duration {
+	if v != nil {
+		return v.Duration
+	}
+	return def
+}
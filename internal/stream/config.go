@@ -0,0 +1,12 @@
+package stream
+
+import "time"
+
+type Config struct {
+	// Number of the points buffered for the subscriber, the points are dropped for the slow subscribers
+	BufferSize int `envconfig:"SOD_STREAM_BUFFER_SIZE" default:"256"`
+	// Interval of the keep alive messages
+	KeepAliveInterval time.Duration `envconfig:"SOD_STREAM_KEEP_ALIVE_INTERVAL" default:"15s"`
+	// Origins allowed to open the WebSocket, only the same origin is allowed without them
+	AllowedOrigins []string `envconfig:"SOD_STREAM_ALLOWED_ORIGINS"`
+}
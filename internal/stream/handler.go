@@ -0,0 +1,248 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-sod/sod/internal/httputil"
+	"github.com/go-sod/sod/internal/logging"
+	"github.com/go-sod/sod/internal/metric/model"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	ModeOutliers = "outliers"
+	ModeAll      = "all"
+)
+
+const (
+	eventOutlier = "outlier"
+	eventPoint   = "point"
+	eventDropped = "dropped"
+)
+
+type event struct {
+	Type       string      `json:"type"`
+	ID         string      `json:"id,omitempty"`
+	EntityID   string      `json:"entity,omitempty"`
+	Vec        []float64   `json:"vector,omitempty"`
+	NormVec    []float64   `json:"normVec,omitempty"`
+	Outlier    bool        `json:"outlier"`
+	Score      float64     `json:"score"`
+	Threshold  float64     `json:"threshold"`
+	Confidence float64     `json:"confidence"`
+	CreatedAt  *time.Time  `json:"createdAt,omitempty"`
+	Extra      interface{} `json:"extra,omitempty"`
+	// Dropped is the number of the points dropped before the event for the slow subscriber
+	Dropped uint64 `json:"dropped,omitempty"`
+}
+
+func newEvent(metric model.Metric) event {
+	typ := eventPoint
+	if metric.Outlier {
+		typ = eventOutlier
+	}
+	createdAt := metric.CreatedAt
+	return event{
+		Type:       typ,
+		ID:         metric.ID.String(),
+		EntityID:   metric.EntityID,
+		Vec:        metric.CheckedVec,
+		NormVec:    metric.NormVec,
+		Outlier:    metric.Outlier,
+		Score:      metric.Score,
+		Threshold:  metric.Threshold,
+		Confidence: metric.Confidence,
+		CreatedAt:  &createdAt,
+		Extra:      metric.Extra,
+	}
+}
+
+// NewHandler returns the handler streaming the classified points over the Server-Sent Events,
+// or over the WebSocket when the connection is upgraded
+func NewHandler(cfg *Config, subscriber Subscriber) (http.Handler, error) {
+	return &handler{
+		cfg:        cfg,
+		subscriber: subscriber,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: checkOrigin(cfg.AllowedOrigins),
+		},
+	}, nil
+}
+
+// checkOrigin returns the check of the WebSocket origin by the allowed origins,
+// without them the default check of the upgrader allows only the same origin
+func checkOrigin(allowed []string) func(r *http.Request) bool {
+	if len(allowed) == 0 {
+		return nil
+	}
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		// the clients other than the browsers do not send the origin
+		if origin == "" {
+			return true
+		}
+		for _, o := range allowed {
+			if strings.EqualFold(o, origin) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+type handler struct {
+	cfg        *Config
+	subscriber Subscriber
+	upgrader   websocket.Upgrader
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := logging.FromContext(ctx)
+
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		logger.Debugf(`{"error": "method %v is not allowed"}`, r.Method)
+		_, _ = fmt.Fprintf(w, `{"error": "method %v is not allowed"}`, r.Method)
+		return
+	}
+
+	filter := Filter{EntityID: r.URL.Query().Get("entity"), OutliersOnly: true}
+	switch mode := r.URL.Query().Get("mode"); mode {
+	case ModeOutliers, "":
+	case ModeAll:
+		filter.OutliersOnly = false
+	default:
+		httputil.RespBadRequestErrorf(ctx, w, `{"error": "unknown mode %s"}`, mode)
+		return
+	}
+
+	if websocket.IsWebSocketUpgrade(r) {
+		h.serveWebSocket(w, r, filter)
+		return
+	}
+	h.serveSSE(w, r, filter)
+}
+
+func (h *handler) serveSSE(w http.ResponseWriter, r *http.Request, filter Filter) {
+	ctx := r.Context()
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		httputil.RespInternalErrorf(ctx, w, "streaming is not supported by the response writer")
+		return
+	}
+
+	sub := h.subscriber.Subscribe(filter, h.cfg.BufferSize)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(h.cfg.KeepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case metric, ok := <-sub.C():
+			if !ok {
+				return
+			}
+			if dropped := sub.Dropped(); dropped > 0 {
+				if err := writeSSE(w, "", event{Type: eventDropped, Dropped: dropped}); err != nil {
+					return
+				}
+			}
+			e := newEvent(metric)
+			if err := writeSSE(w, e.ID, e); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+func writeSSE(w http.ResponseWriter, id string, e event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("encode event: %w", err)
+	}
+	var sb strings.Builder
+	if id != "" {
+		sb.WriteString("id: " + id + "\n")
+	}
+	sb.WriteString("event: " + e.Type + "\n")
+	sb.WriteString("data: ")
+	sb.Write(b)
+	sb.WriteString("\n\n")
+	_, err = fmt.Fprint(w, sb.String())
+	return err
+}
+
+func (h *handler) serveWebSocket(w http.ResponseWriter, r *http.Request, filter Filter) {
+	logger := logging.FromContext(r.Context())
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader has already responded
+		logger.Debugf("websocket upgrade: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	sub := h.subscriber.Subscribe(filter, h.cfg.BufferSize)
+	defer sub.Close()
+
+	// the messages of the client are discarded, the read loop handles the control messages and the close
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.SetReadLimit(512)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	// the deadline is set before every write, the wait for the points does not use it up
+	write := func(e event) error {
+		_ = conn.SetWriteDeadline(time.Now().Add(h.cfg.KeepAliveInterval))
+		return conn.WriteJSON(e)
+	}
+	keepAlive := time.NewTicker(h.cfg.KeepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-keepAlive.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(h.cfg.KeepAliveInterval)); err != nil {
+				return
+			}
+		case metric, ok := <-sub.C():
+			if !ok {
+				return
+			}
+			if dropped := sub.Dropped(); dropped > 0 {
+				if err := write(event{Type: eventDropped, Dropped: dropped}); err != nil {
+					return
+				}
+			}
+			if err := write(newEvent(metric)); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package stream
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-sod/sod/internal/metric/model"
+	"github.com/go-sod/sod/internal/telemetry"
+)
+
+// Filter selects the points sent to the subscriber
+type Filter struct {
+	// EntityID is the entity of the points, all entities when empty
+	EntityID string
+	// OutliersOnly skips the normal points
+	OutliersOnly bool
+}
+
+func (f Filter) matches(metric model.Metric) bool {
+	if f.OutliersOnly && !metric.Outlier {
+		return false
+	}
+	return f.EntityID == "" || f.EntityID == metric.EntityID
+}
+
+// Subscriber defines the behavior of the service streaming the classified points
+type Subscriber interface {
+	// Subscribe returns the subscription buffering up to size points
+	Subscribe(filter Filter, size int) *Subscription
+}
+
+var _ Subscriber = (*Broker)(nil)
+
+func NewBroker() *Broker {
+	return &Broker{subs: map[*Subscription]struct{}{}}
+}
+
+// Broker sends the published points to the subscribers without blocking the publisher,
+// the points are dropped for the subscribers with the full buffers
+type Broker struct {
+	mtx  sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+func (b *Broker) Subscribe(filter Filter, size int) *Subscription {
+	if size < 1 {
+		size = 1
+	}
+	sub := &Subscription{broker: b, filter: filter, ch: make(chan model.Metric, size)}
+	b.mtx.Lock()
+	b.subs[sub] = struct{}{}
+	b.mtx.Unlock()
+	telemetry.StreamSubscribers.Inc()
+	return sub
+}
+
+// Publish sends the point to the matching subscribers
+func (b *Broker) Publish(metric model.Metric) {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+	for sub := range b.subs {
+		if !sub.filter.matches(metric) {
+			continue
+		}
+		select {
+		case sub.ch <- metric:
+		default:
+			atomic.AddUint64(&sub.dropped, 1)
+			telemetry.StreamDroppedTotal.Inc()
+		}
+	}
+}
+
+func (b *Broker) unsubscribe(sub *Subscription) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if _, ok := b.subs[sub]; !ok {
+		return
+	}
+	delete(b.subs, sub)
+	close(sub.ch)
+	telemetry.StreamSubscribers.Dec()
+}
+
+type Subscription struct {
+	broker  *Broker
+	filter  Filter
+	ch      chan model.Metric
+	dropped uint64
+}
+
+// C returns the points of the subscription, the channel is closed by Close
+func (s *Subscription) C() <-chan model.Metric {
+	return s.ch
+}
+
+// Dropped returns the number of the points dropped since the last call
+func (s *Subscription) Dropped() uint64 {
+	return atomic.SwapUint64(&s.dropped, 0)
+}
+
+func (s *Subscription) Close() {
+	s.broker.unsubscribe(s)
+}
@@ -0,0 +1,243 @@
+package stream
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sod/sod/internal/metric/model"
+	"github.com/gorilla/websocket"
+)
+
+func TestBroker_Publish(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		filter   Filter
+		expected []string
+	}{
+		{
+			name:     "positive_all",
+			filter:   Filter{},
+			expected: []string{"api", "api", "db"},
+		},
+		{
+			name:     "positive_outliers_only",
+			filter:   Filter{OutliersOnly: true},
+			expected: []string{"api", "db"},
+		},
+		{
+			name:     "positive_entity_outliers",
+			filter:   Filter{EntityID: "db", OutliersOnly: true},
+			expected: []string{"db"},
+		},
+		{
+			name:   "negative_unknown_entity",
+			filter: Filter{EntityID: "web"},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			b := NewBroker()
+			sub := b.Subscribe(test.filter, 10)
+			b.Publish(model.Metric{EntityID: "api"})
+			b.Publish(model.Metric{EntityID: "api", Outlier: true})
+			b.Publish(model.Metric{EntityID: "db", Outlier: true})
+			sub.Close()
+
+			var got []string
+			for metric := range sub.C() {
+				got = append(got, metric.EntityID)
+			}
+			if strings.Join(got, ",") != strings.Join(test.expected, ",") {
+				t.Errorf("points, got: %v, expected: %v", got, test.expected)
+			}
+		})
+	}
+}
+
+func TestBroker_SlowSubscriber(t *testing.T) {
+	t.Parallel()
+	b := NewBroker()
+	sub := b.Subscribe(Filter{}, 2)
+	defer sub.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 5; i++ {
+			b.Publish(model.Metric{EntityID: "api"})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("publish is blocked by the slow subscriber")
+	}
+	if len(sub.C()) != 2 {
+		t.Errorf("buffered points, got: %d, expected: 2", len(sub.C()))
+	}
+	if dropped := sub.Dropped(); dropped != 3 {
+		t.Errorf("dropped points, got: %d, expected: 3", dropped)
+	}
+	if dropped := sub.Dropped(); dropped != 0 {
+		t.Errorf("dropped points after reset, got: %d, expected: 0", dropped)
+	}
+}
+
+func TestHandler_SSE(t *testing.T) {
+	t.Parallel()
+	b := NewBroker()
+	h, _ := NewHandler(&Config{BufferSize: 10, KeepAliveInterval: time.Minute}, b)
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"?entity=api", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content type, got: %s, expected: text/event-stream", ct)
+	}
+
+	waitSubscribers(t, b, 1)
+	b.Publish(model.Metric{EntityID: "api", Score: 1})
+	b.Publish(model.Metric{EntityID: "api", Outlier: true, Score: 2.5, CheckedVec: []float64{1, 2}})
+
+	reader := bufio.NewReader(resp.Body)
+	var lines []string
+	for len(lines) < 3 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read event: %v", err)
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if lines[1] != "event: outlier" {
+		t.Errorf("event, got: %s, expected: event: outlier", lines[1])
+	}
+	var e event
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[2], "data: ")), &e); err != nil {
+		t.Fatalf("decode event: %v", err)
+	}
+	if e.EntityID != "api" || e.Score != 2.5 || len(e.Vec) != 2 {
+		t.Errorf("event, got: %+v", e)
+	}
+
+	resp, err = http.Get(srv.URL + "?mode=unknown")
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unknown mode status, got: %d, expected: %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestHandler_WebSocket(t *testing.T) {
+	t.Parallel()
+	b := NewBroker()
+	h, _ := NewHandler(&Config{BufferSize: 10, KeepAliveInterval: time.Minute}, b)
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"?mode=all", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	waitSubscribers(t, b, 1)
+	b.Publish(model.Metric{EntityID: "db", Score: 0.5})
+
+	var e event
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	if err := conn.ReadJSON(&e); err != nil {
+		t.Fatalf("read event: %v", err)
+	}
+	if e.Type != eventPoint || e.EntityID != "db" || e.Outlier {
+		t.Errorf("event, got: %+v", e)
+	}
+
+	_ = conn.Close()
+	waitSubscribers(t, b, 0)
+}
+
+func TestHandler_WebSocketOrigin(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		allowed  []string
+		origin   string
+		expected int
+	}{
+		{
+			name:     "positive_without_origin",
+			expected: http.StatusSwitchingProtocols,
+		},
+		{
+			name:     "positive_allowed_origin",
+			allowed:  []string{"https://dashboard.example.com"},
+			origin:   "https://dashboard.example.com",
+			expected: http.StatusSwitchingProtocols,
+		},
+		{
+			name:     "negative_cross_origin",
+			origin:   "https://evil.example.com",
+			expected: http.StatusForbidden,
+		},
+		{
+			name:     "negative_not_allowed_origin",
+			allowed:  []string{"https://dashboard.example.com"},
+			origin:   "https://evil.example.com",
+			expected: http.StatusForbidden,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			h, _ := NewHandler(&Config{BufferSize: 10, KeepAliveInterval: time.Minute, AllowedOrigins: test.allowed}, NewBroker())
+			srv := httptest.NewServer(h)
+			defer srv.Close()
+
+			header := http.Header{}
+			if test.origin != "" {
+				header.Set("Origin", test.origin)
+			}
+			conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), header)
+			if err == nil {
+				defer conn.Close()
+			}
+			if resp == nil || resp.StatusCode != test.expected {
+				t.Errorf("upgrade, got: %v (%v), expected status: %d", resp, err, test.expected)
+			}
+		})
+	}
+}
+
+func waitSubscribers(t *testing.T, b *Broker, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		b.mtx.RLock()
+		got := len(b.subs)
+		b.mtx.RUnlock()
+		if got == n {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("subscribers, expected: %d", n)
+}
@@ -0,0 +1,205 @@
+package telemetry
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "sod"
+
+var (
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Total number of HTTP requests by handler and status code.",
+	}, []string{"handler", "code"})
+
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latencies by handler.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"handler"})
+
+	// OutliersTotal counts the outliers detected by the classification of the collected metrics of the entity
+	OutliersTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "outliers_total",
+		Help:      "Total number of outliers detected by entity.",
+	}, []string{"entity"})
+
+	// DBTxBufferLength is the number of metrics waiting to be flushed to the database
+	DBTxBufferLength = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "db_tx_buffer_length",
+		Help:      "Number of metrics in the transaction buffer waiting to be flushed.",
+	})
+
+	// DBFlushDuration observes the bulk insert of the transaction buffer
+	DBFlushDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_flush_duration_seconds",
+		Help:      "Duration of the transaction buffer flushes.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	// DBDeletedMetricsTotal counts the metrics deleted by the retention policy, reason is outdated or oversize
+	DBDeletedMetricsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_deleted_metrics_total",
+		Help:      "Total number of metrics deleted from the database by the retention policy.",
+	}, []string{"reason"})
+
+	// AlertDeliveriesTotal counts the alert deliveries to the targets, result is success or failure
+	AlertDeliveriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "alert_deliveries_total",
+		Help:      "Total number of alert deliveries by entity and result.",
+	}, []string{"entity", "result"})
+
+	// AlertDeadLettersTotal counts the alerts moved to the dead letters after the exhausted retries
+	AlertDeadLettersTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "alert_dead_letters_total",
+		Help:      "Total number of alerts moved to the dead letters by entity.",
+	}, []string{"entity"})
+
+	// AlertsSuppressedTotal counts the outliers not notified by the alert rules, reason is window, cooldown or dedup
+	AlertsSuppressedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "alerts_suppressed_total",
+		Help:      "Total number of outliers suppressed by the alert rules by entity and reason.",
+	}, []string{"entity", "reason"})
+
+	// QueueOverflowsTotal counts the metrics dropped or rejected by the full queues, policy is the overflow policy
+	QueueOverflowsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "queue_overflows_total",
+		Help:      "Total number of metrics dropped or rejected by the full entity queues by entity and overflow policy.",
+	}, []string{"entity", "policy"})
+
+	// DuplicatesTotal counts the skipped metrics with the already collected dedup keys
+	DuplicatesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "duplicates_total",
+		Help:      "Total number of skipped duplicate metrics by entity.",
+	}, []string{"entity"})
+
+	// LateMetricsTotal counts the metrics older than the watermark of the entity, policy is the late policy
+	LateMetricsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "late_metrics_total",
+		Help:      "Total number of metrics older than the entity watermark by entity and late policy.",
+	}, []string{"entity", "policy"})
+
+	// FeedbackTotal counts the labels of the metrics
+	FeedbackTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "feedback_total",
+		Help:      "Total number of labeled metrics by entity and label.",
+	}, []string{"entity", "label"})
+
+	// StreamSubscribers is the number of the connected outlier stream subscribers
+	StreamSubscribers = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "stream_subscribers",
+		Help:      "Number of the connected outlier stream subscribers.",
+	})
+
+	// StreamDroppedTotal counts the points not sent to the slow stream subscribers
+	StreamDroppedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "stream_dropped_total",
+		Help:      "Total number of points dropped for the slow outlier stream subscribers.",
+	})
+
+	// ScrapeTargetUp is 1 when the last scrape of the target succeeded
+	ScrapeTargetUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "scrape_target_up",
+		Help:      "Whether the last scrape of the target was successful.",
+	}, []string{"target"})
+
+	// ScrapeDuration observes the scrapes of the targets
+	ScrapeDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "scrape_duration_seconds",
+		Help:      "Duration of the target scrapes.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"target"})
+)
+
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+
+	ReasonOutdated = "outdated"
+	ReasonOversize = "oversize"
+)
+
+// Result returns the result label value for the error
+func Result(err error) string {
+	if err != nil {
+		return ResultFailure
+	}
+	return ResultSuccess
+}
+
+// Handler returns the handler exposing the registered metrics
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// InstrumentHandler counts the requests and observes the latencies of the handler
+func InstrumentHandler(name string, h http.Handler) http.Handler {
+	return promhttp.InstrumentHandlerCounter(
+		requestsTotal.MustCurryWith(prometheus.Labels{"handler": name}),
+		promhttp.InstrumentHandlerDuration(
+			requestDuration.MustCurryWith(prometheus.Labels{"handler": name}),
+			h,
+		),
+	)
+}
+
+var datasetSizeDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "", "entity_dataset_size"),
+	"Number of data points in the entity predictor.",
+	[]string{"entity"},
+	nil,
+)
+
+var queueDepthDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "", "queue_depth"),
+	"Number of metrics waiting in the entity queue.",
+	[]string{"entity"},
+	nil,
+)
+
+// NewQueueCollector returns the collector reporting the queue depths of the entities on every scrape
+func NewQueueCollector(depthsFn func() map[string]int) prometheus.Collector {
+	return &datasetCollector{desc: queueDepthDesc, sizesFn: depthsFn}
+}
+
+// NewDatasetCollector returns the collector reporting the dataset sizes of the entities on every scrape
+func NewDatasetCollector(sizesFn func() map[string]int) prometheus.Collector {
+	return &datasetCollector{desc: datasetSizeDesc, sizesFn: sizesFn}
+}
+
+// datasetCollector reports the gauge of every entity
+type datasetCollector struct {
+	desc    *prometheus.Desc
+	sizesFn func() map[string]int
+}
+
+func (c *datasetCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *datasetCollector) Collect(ch chan<- prometheus.Metric) {
+	for entityID, size := range c.sizesFn() {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(size), entityID)
+	}
+}
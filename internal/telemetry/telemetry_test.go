@@ -0,0 +1,23 @@
+package telemetry
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestDatasetCollector(t *testing.T) {
+	t.Parallel()
+	collector := NewDatasetCollector(func() map[string]int {
+		return map[string]int{"weather": 10}
+	})
+	expected := `
+# HELP sod_entity_dataset_size Number of data points in the entity predictor.
+# TYPE sod_entity_dataset_size gauge
+sod_entity_dataset_size{entity="weather"} 10
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected)); err != nil {
+		t.Errorf("collect dataset sizes: %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+package timeutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Duration is encoded to json as the time.Duration string, e.g. "1h30m"
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string: %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("unable parse duration: %w", err)
+	}
+	d.Duration = v
+	return nil
+}
@@ -0,0 +1,154 @@
+package balltree
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+type node struct {
+	center []float64
+	radius float64
+	// points are stored only in the leaves
+	points []Point
+	left   *node
+	right  *node
+}
+
+func (n *node) leaf() bool {
+	return n.left == nil && n.right == nil
+}
+
+func (n *node) Points() []Point {
+	if n.leaf() {
+		points := make([]Point, len(n.points))
+		copy(points, n.points)
+		return points
+	}
+	return append(n.left.Points(), n.right.Points()...)
+}
+
+func (n *node) len() int {
+	if n.leaf() {
+		return len(n.points)
+	}
+	return n.left.len() + n.right.len()
+}
+
+// insert descends to the closest ball, expanding the radius of each ball on the path
+func (n *node) insert(p Point, leafSize int, distFn DistanceFn) error {
+	distance, err := distFn(n.center, p.Points())
+	if err != nil {
+		return fmt.Errorf("compute distance error: %w", err)
+	}
+	n.radius = math.Max(n.radius, distance)
+
+	if n.leaf() {
+		n.points = append(n.points, p)
+		// the overflowed leaf is rebuilt into a subtree
+		if len(n.points) > 2*leafSize {
+			rebuilt, err := buildRecursive(n.points, leafSize, distFn)
+			if err != nil {
+				return err
+			}
+			*n = *rebuilt
+		}
+		return nil
+	}
+
+	leftDistance, err := distFn(n.left.center, p.Points())
+	if err != nil {
+		return fmt.Errorf("compute distance error: %w", err)
+	}
+	rightDistance, err := distFn(n.right.center, p.Points())
+	if err != nil {
+		return fmt.Errorf("compute distance error: %w", err)
+	}
+	if leftDistance <= rightDistance {
+		return n.left.insert(p, leafSize, distFn)
+	}
+	return n.right.insert(p, leafSize, distFn)
+}
+
+type sortPoints struct {
+	dim    int
+	points []Point
+}
+
+func (b *sortPoints) Len() int {
+	return len(b.points)
+}
+
+func (b *sortPoints) Less(i, j int) bool {
+	return b.points[i].Dim(b.dim) < b.points[j].Dim(b.dim)
+}
+
+func (b *sortPoints) Swap(i, j int) {
+	b.points[i], b.points[j] = b.points[j], b.points[i]
+}
+
+func centroid(points []Point) []float64 {
+	center := make([]float64, points[0].Dimensions())
+	for _, p := range points {
+		for dim := range center {
+			center[dim] += p.Dim(dim)
+		}
+	}
+	for dim := range center {
+		center[dim] /= float64(len(points))
+	}
+	return center
+}
+
+// spreadDim returns the dimension with the greatest spread of values
+func spreadDim(points []Point) int {
+	var (
+		maxDim    int
+		maxSpread float64
+	)
+	for dim := 0; dim < points[0].Dimensions(); dim++ {
+		min, max := math.MaxFloat64, -math.MaxFloat64
+		for _, p := range points {
+			min = math.Min(min, p.Dim(dim))
+			max = math.Max(max, p.Dim(dim))
+		}
+		if max-min > maxSpread {
+			maxSpread = max - min
+			maxDim = dim
+		}
+	}
+	return maxDim
+}
+
+func buildRecursive(points []Point, leafSize int, distFn DistanceFn) (*node, error) {
+	n := &node{center: centroid(points)}
+	for _, p := range points {
+		distance, err := distFn(n.center, p.Points())
+		if err != nil {
+			return nil, fmt.Errorf("compute distance error: %w", err)
+		}
+		n.radius = math.Max(n.radius, distance)
+	}
+
+	if len(points) <= leafSize || n.radius == 0 {
+		n.points = make([]Point, len(points))
+		copy(n.points, points)
+		return n, nil
+	}
+
+	sorted := make([]Point, len(points))
+	copy(sorted, points)
+	sort.Sort(&sortPoints{dim: spreadDim(sorted), points: sorted})
+	mid := len(sorted) / 2
+
+	left, err := buildRecursive(sorted[:mid], leafSize, distFn)
+	if err != nil {
+		return nil, err
+	}
+	right, err := buildRecursive(sorted[mid:], leafSize, distFn)
+	if err != nil {
+		return nil, err
+	}
+	n.left, n.right = left, right
+	return n, nil
+}
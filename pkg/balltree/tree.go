@@ -0,0 +1,150 @@
+package balltree
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/go-sod/sod/pkg/pqueue"
+)
+
+const DefaultLeafSize = 16
+
+type Point interface {
+	Dim(idx int) float64
+	Dimensions() int
+	Points() []float64
+}
+
+type DistanceFn func(vec, vec1 []float64) (float64, error)
+
+type Option func(*Tree)
+
+func WithLeafSize(n int) Option {
+	return func(t *Tree) {
+		if n > 0 {
+			t.leafSize = n
+		}
+	}
+}
+
+// New returns the ball tree, the distance function must satisfy the triangle inequality
+func New(distFn DistanceFn, opts ...Option) *Tree {
+	t := &Tree{distFn: distFn, leafSize: DefaultLeafSize}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+type Tree struct {
+	root     *node
+	len      int
+	leafSize int
+	distFn   DistanceFn
+}
+
+func (t *Tree) Build(points ...Point) error {
+	if len(points) == 0 {
+		t.root, t.len = nil, 0
+		return nil
+	}
+	root, err := buildRecursive(points, t.leafSize, t.distFn)
+	if err != nil {
+		return fmt.Errorf("build ball tree error: %w", err)
+	}
+	t.root, t.len = root, len(points)
+	return nil
+}
+
+func (t *Tree) Len() int {
+	return t.len
+}
+
+func (t *Tree) Insert(p Point) error {
+	if t.root == nil {
+		return t.Build(p)
+	}
+	if err := t.root.insert(p, t.leafSize, t.distFn); err != nil {
+		return fmt.Errorf("insert to ball tree error: %w", err)
+	}
+	t.len += 1
+	return nil
+}
+
+func (t *Tree) Balance() error {
+	return t.Build(t.Points()...)
+}
+
+func (t *Tree) Points() []Point {
+	if t.root == nil {
+		return []Point{}
+	}
+	return t.root.Points()
+}
+
+func (t *Tree) KNN(p Point, k int) ([]Point, error) {
+	if t.root == nil || k == 0 {
+		return []Point{}, fmt.Errorf("root is nil or K is 0")
+	}
+
+	queue := pqueue.New(pqueue.WithCap(uint(k)))
+	if err := t.knn(p, k, t.root, queue); err != nil {
+		return []Point{}, err
+	}
+
+	points := make([]Point, queue.Len())
+	for i, item := range queue.PopAll() {
+		points[i] = item.(Point)
+	}
+	return points, nil
+}
+
+func (t *Tree) knn(p Point, k int, current *node, queue *pqueue.Queue) error {
+	centerDistance, err := t.distFn(p.Points(), current.center)
+	if err != nil {
+		return fmt.Errorf("compute knn error: %w", err)
+	}
+	// no point of the ball can be closer than the k-th found neighbor
+	if centerDistance-current.radius >= getKthOrLastDistance(queue, k-1) {
+		return nil
+	}
+
+	if current.leaf() {
+		for _, point := range current.points {
+			distance, err := t.distFn(p.Points(), point.Points())
+			if err != nil {
+				return fmt.Errorf("compute knn error: %w", err)
+			}
+			if distance < getKthOrLastDistance(queue, k-1) {
+				queue.Push(point, distance)
+			}
+		}
+		return nil
+	}
+
+	leftDistance, err := t.distFn(p.Points(), current.left.center)
+	if err != nil {
+		return fmt.Errorf("compute knn error: %w", err)
+	}
+	rightDistance, err := t.distFn(p.Points(), current.right.center)
+	if err != nil {
+		return fmt.Errorf("compute knn error: %w", err)
+	}
+	// the closer ball is visited first to shrink the search radius faster
+	first, second := current.left, current.right
+	if rightDistance < leftDistance {
+		first, second = second, first
+	}
+	if err := t.knn(p, k, first, queue); err != nil {
+		return err
+	}
+	return t.knn(p, k, second, queue)
+}
+
+func getKthOrLastDistance(queue *pqueue.Queue, i int) float64 {
+	if queue.Len() <= i {
+		return math.MaxFloat64
+	}
+	_, distance := queue.Seek(i)
+	return distance
+}
@@ -0,0 +1,79 @@
+package balltree
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/go-sod/sod/internal/geom"
+)
+
+func randomPoints(rnd *rand.Rand, n, dims int) []Point {
+	points := make([]Point, n)
+	for i := range points {
+		vec := make(geom.Point, dims)
+		for j := range vec {
+			vec[j] = rnd.Float64() * 100
+		}
+		points[i] = vec
+	}
+	return points
+}
+
+func bruteKNN(points []Point, p Point, k int) []float64 {
+	distances := make([]float64, len(points))
+	for i := range points {
+		distances[i], _ = geom.EuclideanDistance(p.Points(), points[i].Points())
+	}
+	sort.Float64s(distances)
+	return distances[:k]
+}
+
+func TestTree_KNN(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		n      int
+		dims   int
+		k      int
+		insert bool
+	}{
+		{name: "positive_build_low_dim", n: 500, dims: 2, k: 5},
+		{name: "positive_build_high_dim", n: 500, dims: 64, k: 10},
+		{name: "positive_insert", n: 300, dims: 8, k: 3, insert: true},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			rnd := rand.New(rand.NewSource(1)) // nolint:gosec
+			points := randomPoints(rnd, test.n, test.dims)
+			tree := New(geom.EuclideanDistance, WithLeafSize(8))
+			if test.insert {
+				for i := range points {
+					if err := tree.Insert(points[i]); err != nil {
+						t.Fatalf("calling Insert, got error: %v", err)
+					}
+				}
+			} else if err := tree.Build(points...); err != nil {
+				t.Fatalf("calling Build, got error: %v", err)
+			}
+			if tree.Len() != test.n {
+				t.Errorf("calling Len, got: %v, expected: %v", tree.Len(), test.n)
+			}
+			for _, query := range randomPoints(rnd, 20, test.dims) {
+				nn, err := tree.KNN(query, test.k)
+				if err != nil {
+					t.Fatalf("calling KNN, got error: %v", err)
+				}
+				expected := bruteKNN(points, query, test.k)
+				for i := range nn {
+					distance, _ := geom.EuclideanDistance(query.Points(), nn[i].Points())
+					if distance != expected[i] {
+						t.Errorf("calling KNN, got distance: %v, expected: %v", distance, expected[i])
+					}
+				}
+			}
+		})
+	}
+}
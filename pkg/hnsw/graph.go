@@ -0,0 +1,426 @@
+package hnsw
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+const (
+	DefaultM              = 16
+	DefaultEfConstruction = 200
+	DefaultEfSearch       = 64
+)
+
+type Point interface {
+	Dim(idx int) float64
+	Dimensions() int
+	Points() []float64
+}
+
+type DistanceFn func(vec, vec1 []float64) (float64, error)
+
+type Option func(*Graph)
+
+// WithM sets the number of links created for every new point on each layer
+func WithM(m int) Option {
+	return func(g *Graph) {
+		if m > 1 {
+			g.m = m
+		}
+	}
+}
+
+// WithEfConstruction sets the size of the dynamic candidates list used on insert
+func WithEfConstruction(ef int) Option {
+	return func(g *Graph) {
+		if ef > 0 {
+			g.efConstruction = ef
+		}
+	}
+}
+
+// WithEfSearch sets the size of the dynamic candidates list used on search
+func WithEfSearch(ef int) Option {
+	return func(g *Graph) {
+		if ef > 0 {
+			g.efSearch = ef
+		}
+	}
+}
+
+func WithSeed(seed int64) Option {
+	return func(g *Graph) {
+		g.rnd = rand.New(rand.NewSource(seed)) // nolint:gosec
+	}
+}
+
+type node struct {
+	id    uint64
+	point Point
+	level int
+	// outgoing links on each layer
+	links [][]uint64
+	// nodes linking to this node on each layer, used to repair the graph on removal
+	inbound []map[uint64]struct{}
+}
+
+// New returns the hierarchical navigable small world graph for the approximate nearest neighbors search
+func New(distFn DistanceFn, opts ...Option) *Graph {
+	g := &Graph{
+		m:              DefaultM,
+		efConstruction: DefaultEfConstruction,
+		efSearch:       DefaultEfSearch,
+		distFn:         distFn,
+		nodes:          map[uint64]*node{},
+		rnd:            rand.New(rand.NewSource(time.Now().UnixNano())), // nolint:gosec
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	g.levelMult = 1 / math.Log(float64(g.m))
+	return g
+}
+
+type Graph struct {
+	m              int
+	efConstruction int
+	efSearch       int
+	levelMult      float64
+	distFn         DistanceFn
+	rnd            *rand.Rand
+	nodes          map[uint64]*node
+	entry          *node
+	maxLevel       int
+}
+
+func (g *Graph) Len() int {
+	return len(g.nodes)
+}
+
+func (g *Graph) Contains(id uint64) bool {
+	_, ok := g.nodes[id]
+	return ok
+}
+
+// Insert adds the point with the unique id to the graph
+func (g *Graph) Insert(id uint64, p Point) error {
+	if _, ok := g.nodes[id]; ok {
+		return fmt.Errorf("point with id %d already exists", id)
+	}
+	level := g.randomLevel()
+	n := &node{
+		id:      id,
+		point:   p,
+		level:   level,
+		links:   make([][]uint64, level+1),
+		inbound: make([]map[uint64]struct{}, level+1),
+	}
+	for l := range n.inbound {
+		n.inbound[l] = map[uint64]struct{}{}
+	}
+
+	if g.entry == nil {
+		g.nodes[id] = n
+		g.entry, g.maxLevel = n, level
+		return nil
+	}
+
+	distance, err := g.distFn(p.Points(), g.entry.point.Points())
+	if err != nil {
+		return fmt.Errorf("compute distance error: %w", err)
+	}
+	entryPoints := []candidate{{id: g.entry.id, distance: distance}}
+	for l := g.maxLevel; l > level; l-- {
+		if entryPoints, err = g.searchLayer(p, entryPoints, 1, l); err != nil {
+			return err
+		}
+	}
+
+	g.nodes[id] = n
+	for l := minInt(level, g.maxLevel); l >= 0; l-- {
+		candidates, err := g.searchLayer(p, entryPoints, g.efConstruction, l)
+		if err != nil {
+			delete(g.nodes, id)
+			return err
+		}
+		neighbors, err := g.selectNeighbors(candidates, g.m)
+		if err != nil {
+			delete(g.nodes, id)
+			return err
+		}
+		if err := g.setLinks(n, l, ids(neighbors)); err != nil {
+			return err
+		}
+		for _, neighbor := range neighbors {
+			nb := g.nodes[neighbor.id]
+			if err := g.setLinks(nb, l, append(nb.links[l], id)); err != nil {
+				return err
+			}
+		}
+		entryPoints = candidates
+	}
+
+	if level > g.maxLevel {
+		g.entry, g.maxLevel = n, level
+	}
+	return nil
+}
+
+// Remove deletes the point from the graph and reconnects its neighbors
+func (g *Graph) Remove(id uint64) error {
+	n, ok := g.nodes[id]
+	if !ok {
+		return nil
+	}
+	delete(g.nodes, id)
+
+	for l := 0; l <= n.level; l++ {
+		affected := map[uint64]struct{}{}
+		for _, linkID := range n.links[l] {
+			affected[linkID] = struct{}{}
+			if linked, ok := g.nodes[linkID]; ok {
+				delete(linked.inbound[l], id)
+			}
+		}
+		for inboundID := range n.inbound[l] {
+			affected[inboundID] = struct{}{}
+		}
+		for affectedID := range affected {
+			a, ok := g.nodes[affectedID]
+			if !ok {
+				continue
+			}
+			if err := g.repair(a, n, l); err != nil {
+				return err
+			}
+		}
+	}
+
+	if g.entry == n {
+		g.entry, g.maxLevel = nil, 0
+		for _, candidate := range g.nodes {
+			if g.entry == nil || candidate.level > g.maxLevel {
+				g.entry, g.maxLevel = candidate, candidate.level
+			}
+		}
+	}
+	return nil
+}
+
+// KNN returns up to k approximate nearest neighbors ordered by distance
+func (g *Graph) KNN(p Point, k int) ([]Point, error) {
+	if g.entry == nil || k == 0 {
+		return []Point{}, fmt.Errorf("graph is empty or K is 0")
+	}
+	distance, err := g.distFn(p.Points(), g.entry.point.Points())
+	if err != nil {
+		return nil, fmt.Errorf("compute distance error: %w", err)
+	}
+	entryPoints := []candidate{{id: g.entry.id, distance: distance}}
+	for l := g.maxLevel; l > 0; l-- {
+		if entryPoints, err = g.searchLayer(p, entryPoints, 1, l); err != nil {
+			return nil, err
+		}
+	}
+	candidates, err := g.searchLayer(p, entryPoints, maxInt(g.efSearch, k), 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) > k {
+		candidates = candidates[:k]
+	}
+	points := make([]Point, len(candidates))
+	for i := range candidates {
+		points[i] = g.nodes[candidates[i].id].point
+	}
+	return points, nil
+}
+
+// repair removes the deleted node from the links of the affected node
+// and selects new links from the joint neighborhood
+func (g *Graph) repair(a, deleted *node, layer int) error {
+	seen := map[uint64]struct{}{a.id: {}, deleted.id: {}}
+	var candidates []candidate
+	for _, list := range [][]uint64{a.links[layer], deleted.links[layer]} {
+		for _, linkID := range list {
+			if _, ok := seen[linkID]; ok {
+				continue
+			}
+			seen[linkID] = struct{}{}
+			linked, ok := g.nodes[linkID]
+			if !ok || linked.level < layer {
+				continue
+			}
+			distance, err := g.distFn(a.point.Points(), linked.point.Points())
+			if err != nil {
+				return fmt.Errorf("compute distance error: %w", err)
+			}
+			candidates = append(candidates, candidate{id: linkID, distance: distance})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].distance < candidates[j].distance
+	})
+	neighbors, err := g.selectNeighbors(candidates, g.maxLinks(layer))
+	if err != nil {
+		return err
+	}
+	return g.setLinks(a, layer, ids(neighbors))
+}
+
+// setLinks replaces the links of the node on the layer, the overflowed list is truncated to the closest neighbors
+func (g *Graph) setLinks(n *node, layer int, links []uint64) error {
+	if len(links) > g.maxLinks(layer) {
+		candidates := make([]candidate, 0, len(links))
+		for _, linkID := range links {
+			linked, ok := g.nodes[linkID]
+			if !ok {
+				continue
+			}
+			distance, err := g.distFn(n.point.Points(), linked.point.Points())
+			if err != nil {
+				return fmt.Errorf("compute distance error: %w", err)
+			}
+			candidates = append(candidates, candidate{id: linkID, distance: distance})
+		}
+		sort.Slice(candidates, func(i, j int) bool {
+			return candidates[i].distance < candidates[j].distance
+		})
+		links = ids(candidates[:minInt(len(candidates), g.maxLinks(layer))])
+	}
+
+	for _, linkID := range n.links[layer] {
+		if linked, ok := g.nodes[linkID]; ok {
+			delete(linked.inbound[layer], n.id)
+		}
+	}
+	n.links[layer] = links
+	for _, linkID := range links {
+		if linked, ok := g.nodes[linkID]; ok {
+			linked.inbound[layer][n.id] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// searchLayer returns up to ef closest to the point candidates on the layer ordered by distance
+func (g *Graph) searchLayer(p Point, entryPoints []candidate, ef, layer int) ([]candidate, error) {
+	visited := make(map[uint64]struct{}, ef*g.m)
+	candidates := &minHeap{}
+	results := &maxHeap{}
+	for _, ep := range entryPoints {
+		visited[ep.id] = struct{}{}
+		heap.Push(candidates, ep)
+		heap.Push(results, ep)
+		if results.Len() > ef {
+			heap.Pop(results)
+		}
+	}
+
+	for candidates.Len() > 0 {
+		current := heap.Pop(candidates).(candidate)
+		if current.distance > (*results)[0].distance {
+			break
+		}
+		currentNode, ok := g.nodes[current.id]
+		if !ok {
+			continue
+		}
+		for _, linkID := range currentNode.links[layer] {
+			if _, ok := visited[linkID]; ok {
+				continue
+			}
+			visited[linkID] = struct{}{}
+			linked, ok := g.nodes[linkID]
+			if !ok {
+				continue
+			}
+			distance, err := g.distFn(p.Points(), linked.point.Points())
+			if err != nil {
+				return nil, fmt.Errorf("compute distance error: %w", err)
+			}
+			if results.Len() < ef || distance < (*results)[0].distance {
+				heap.Push(candidates, candidate{id: linkID, distance: distance})
+				heap.Push(results, candidate{id: linkID, distance: distance})
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	sorted := make([]candidate, results.Len())
+	for i := len(sorted) - 1; i >= 0; i-- {
+		sorted[i] = heap.Pop(results).(candidate)
+	}
+	return sorted, nil
+}
+
+// selectNeighbors picks diverse neighbors from the ordered candidates with the heuristic from the HNSW paper,
+// the candidate is skipped when it is closer to the already selected neighbor than to the base point
+func (g *Graph) selectNeighbors(candidates []candidate, m int) ([]candidate, error) {
+	selected := make([]candidate, 0, m)
+	var discarded []candidate
+	for _, c := range candidates {
+		if len(selected) >= m {
+			break
+		}
+		good := true
+		for _, s := range selected {
+			distance, err := g.distFn(g.nodes[c.id].point.Points(), g.nodes[s.id].point.Points())
+			if err != nil {
+				return nil, fmt.Errorf("compute distance error: %w", err)
+			}
+			if distance < c.distance {
+				good = false
+				break
+			}
+		}
+		if good {
+			selected = append(selected, c)
+		} else {
+			discarded = append(discarded, c)
+		}
+	}
+	for i := 0; i < len(discarded) && len(selected) < m; i++ {
+		selected = append(selected, discarded[i])
+	}
+	return selected, nil
+}
+
+func (g *Graph) maxLinks(layer int) int {
+	if layer == 0 {
+		return 2 * g.m
+	}
+	return g.m
+}
+
+func (g *Graph) randomLevel() int {
+	return int(math.Floor(-math.Log(1-g.rnd.Float64()) * g.levelMult))
+}
+
+func ids(candidates []candidate) []uint64 {
+	list := make([]uint64, len(candidates))
+	for i := range candidates {
+		list[i] = candidates[i].id
+	}
+	return list
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,90 @@
+package hnsw
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/go-sod/sod/internal/geom"
+)
+
+func randomPoints(rnd *rand.Rand, n, dims int) []geom.Point {
+	points := make([]geom.Point, n)
+	for i := range points {
+		vec := make(geom.Point, dims)
+		for j := range vec {
+			vec[j] = rnd.Float64()
+		}
+		points[i] = vec
+	}
+	return points
+}
+
+// recall returns the share of the exact k nearest neighbors found by the graph
+func recall(t *testing.T, g *Graph, points map[uint64]geom.Point, queries []geom.Point, k int) float64 {
+	t.Helper()
+	var found, total int
+	for _, query := range queries {
+		distances := make([]float64, 0, len(points))
+		for _, p := range points {
+			distance, _ := geom.EuclideanDistance(query, p)
+			distances = append(distances, distance)
+		}
+		sort.Float64s(distances)
+		nn, err := g.KNN(query, k)
+		if err != nil {
+			t.Fatalf("calling KNN, got error: %v", err)
+		}
+		for i := range nn {
+			distance, _ := geom.EuclideanDistance(query, nn[i].Points())
+			if distance <= distances[k-1] {
+				found++
+			}
+		}
+		total += k
+	}
+	return float64(found) / float64(total)
+}
+
+func TestGraph_KNN(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name      string
+		n         int
+		dims      int
+		k         int
+		removed   int
+		minRecall float64
+	}{
+		{name: "positive_low_dim", n: 1000, dims: 4, k: 5, minRecall: 0.95},
+		{name: "positive_high_dim", n: 1000, dims: 64, k: 10, minRecall: 0.9},
+		{name: "positive_removed", n: 1000, dims: 64, k: 10, removed: 500, minRecall: 0.9},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			rnd := rand.New(rand.NewSource(1)) // nolint:gosec
+			g := New(geom.EuclideanDistance, WithSeed(1), WithM(12), WithEfConstruction(100), WithEfSearch(50))
+			points := map[uint64]geom.Point{}
+			for i, p := range randomPoints(rnd, test.n, test.dims) {
+				if err := g.Insert(uint64(i), p); err != nil {
+					t.Fatalf("calling Insert, got error: %v", err)
+				}
+				points[uint64(i)] = p
+			}
+			for i := 0; i < test.removed; i++ {
+				if err := g.Remove(uint64(i)); err != nil {
+					t.Fatalf("calling Remove, got error: %v", err)
+				}
+				delete(points, uint64(i))
+			}
+			if g.Len() != test.n-test.removed {
+				t.Errorf("calling Len, got: %v, expected: %v", g.Len(), test.n-test.removed)
+			}
+			if r := recall(t, g, points, randomPoints(rnd, 50, test.dims), test.k); r < test.minRecall {
+				t.Errorf("calling KNN, got recall: %v, expected at least: %v", r, test.minRecall)
+			}
+		})
+	}
+}
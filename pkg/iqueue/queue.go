@@ -2,74 +2,221 @@ package iqueue
 
 import (
 	"container/list"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
 )
 
-func New() *Queue {
-	return &Queue{
-		queue: list.New(),
-		send:  make(chan interface{}, 1),
-		recv:  make(chan interface{}, 1),
+var (
+	// ErrFull is returned by Send when the bounded queue is full
+	ErrFull = errors.New("queue is full")
+	// ErrClosed is returned by Send after Close
+	ErrClosed = errors.New("queue is closed")
+)
+
+// Overflow is the behavior of Send when the bounded queue is full
+type Overflow string
+
+const (
+	// OverflowBlock waits for the free space up to the block timeout, then Send returns ErrFull
+	OverflowBlock Overflow = "BLOCK"
+	// OverflowDropOldest drops the oldest value in favor of the new one
+	OverflowDropOldest Overflow = "DROP_OLDEST"
+	// OverflowDropNewest drops the new value
+	OverflowDropNewest Overflow = "DROP_NEWEST"
+	// OverflowReject returns ErrFull at once
+	OverflowReject Overflow = "REJECT"
+)
+
+func (o Overflow) Validate() error {
+	switch o {
+	case OverflowBlock, OverflowDropOldest, OverflowDropNewest, OverflowReject:
+		return nil
+	default:
+		return fmt.Errorf("unknown overflow policy %s", o)
 	}
 }
 
-type Queue struct {
-	queue *list.List
-	send  chan interface{}
-	recv  chan interface{}
+// WithCap bounds the queue, the queue is unbounded with 0
+func WithCap(size int) Option {
+	return func(q *Queue) {
+		q.cap = size
+	}
 }
 
-func (iq *Queue) Init() {
-	iq.queue = list.New()
-	iq.send = make(chan interface{}, 1)
-	iq.recv = make(chan interface{}, 1)
+func WithOverflow(overflow Overflow) Option {
+	return func(q *Queue) {
+		q.overflow = overflow
+	}
 }
 
-func (iq *Queue) Send(v interface{}) {
-	iq.send <- v
+// WithBlockTimeout limits the waiting of Send with OverflowBlock, Send waits forever with 0
+func WithBlockTimeout(t time.Duration) Option {
+	return func(q *Queue) {
+		q.blockTimeout = t
+	}
 }
 
-func (iq *Queue) Receive() <-chan interface{} {
-	return iq.recv
+// WithOnDrop sets the function called with the values dropped by the overflow policy
+func WithOnDrop(fn func(v interface{})) Option {
+	return func(q *Queue) {
+		q.onDrop = fn
+	}
 }
 
-func (iq *Queue) Len() int {
-	return iq.queue.Len()
+// WithOnSend sets the function called after the value is added to the queue, e.g. to schedule the consumer
+func WithOnSend(fn func()) Option {
+	return func(q *Queue) {
+		q.onSend = fn
+	}
 }
 
-func (iq *Queue) Queue() *list.List {
-	return iq.queue
+type Option func(*Queue)
+
+func New(opts ...Option) *Queue {
+	q := &Queue{
+		queue:    list.New(),
+		overflow: OverflowBlock,
+	}
+	for _, f := range opts {
+		f(q)
+	}
+	q.notFull = sync.NewCond(&q.mtx)
+	return q
 }
 
-func (iq *Queue) Close() {
-	close(iq.recv)
-	close(iq.send)
-}
-
-func (iq *Queue) Loop() {
-	for {
-		front := iq.queue.Front()
-		if front != nil {
-			select {
-			case iq.recv <- front.Value:
-				iq.queue.Remove(front)
-			case value, ok := <-iq.send:
-				if ok {
-					iq.queue.PushBack(value)
-				} else {
-					iq.send = nil
-				}
+// Queue passes the sent values to Pop in the order of sending
+type Queue struct {
+	mtx      sync.Mutex
+	queue    *list.List
+	notFull  *sync.Cond
+	isClosed bool
+
+	cap          int
+	overflow     Overflow
+	blockTimeout time.Duration
+	onDrop       func(v interface{})
+	onSend       func()
+}
+
+// Send adds the value to the queue, the full bounded queue applies the overflow policy
+func (iq *Queue) Send(v interface{}) error {
+	iq.mtx.Lock()
+	if iq.isClosed {
+		iq.mtx.Unlock()
+		return ErrClosed
+	}
+	if iq.cap > 0 && iq.queue.Len() >= iq.cap {
+		switch iq.overflow {
+		case OverflowDropOldest:
+			dropped := iq.queue.Remove(iq.queue.Front())
+			iq.queue.PushBack(v)
+			iq.mtx.Unlock()
+			iq.sent()
+			iq.drop(dropped)
+			return nil
+		case OverflowDropNewest:
+			iq.mtx.Unlock()
+			iq.drop(v)
+			return nil
+		case OverflowReject:
+			iq.mtx.Unlock()
+			return ErrFull
+		default:
+			if err := iq.waitNotFull(); err != nil {
+				iq.mtx.Unlock()
+				return err
 			}
-			continue
 		}
+	}
+	iq.queue.PushBack(v)
+	iq.mtx.Unlock()
+	iq.sent()
+	return nil
+}
 
-		if iq.send == nil {
-			close(iq.recv)
-			return
+func (iq *Queue) sent() {
+	if iq.onSend != nil {
+		iq.onSend()
+	}
+}
+
+// waitNotFull waits under the lock until the queue has the free space
+func (iq *Queue) waitNotFull() error {
+	var deadline time.Time
+	if iq.blockTimeout > 0 {
+		deadline = time.Now().Add(iq.blockTimeout)
+		// the waiting Send is woken up at the deadline
+		timer := time.AfterFunc(iq.blockTimeout, func() {
+			iq.mtx.Lock()
+			iq.notFull.Broadcast()
+			iq.mtx.Unlock()
+		})
+		defer timer.Stop()
+	}
+	for iq.queue.Len() >= iq.cap {
+		if iq.isClosed {
+			return ErrClosed
 		}
-		value, ok := <-iq.send
-		if !ok {
-			return
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			return ErrFull
 		}
-		iq.queue.PushBack(value)
+		iq.notFull.Wait()
+	}
+	return nil
+}
+
+func (iq *Queue) drop(v interface{}) {
+	if iq.onDrop != nil {
+		iq.onDrop(v)
+	}
+}
+
+// Pop removes and returns the oldest value, false when the queue is empty
+func (iq *Queue) Pop() (interface{}, bool) {
+	iq.mtx.Lock()
+	defer iq.mtx.Unlock()
+	front := iq.queue.Front()
+	if front == nil {
+		return nil, false
+	}
+	v := iq.queue.Remove(front)
+	iq.notFull.Signal()
+	return v, true
+}
+
+// Len returns the number of the queued values
+func (iq *Queue) Len() int {
+	iq.mtx.Lock()
+	defer iq.mtx.Unlock()
+	return iq.queue.Len()
+}
+
+// Cap returns the capacity of the bounded queue, 0 for the unbounded one
+func (iq *Queue) Cap() int {
+	return iq.cap
+}
+
+// Drain removes and returns the queued values
+func (iq *Queue) Drain() []interface{} {
+	var values []interface{}
+	iq.mtx.Lock()
+	defer iq.mtx.Unlock()
+	for e := iq.queue.Front(); e != nil; e = iq.queue.Front() {
+		values = append(values, iq.queue.Remove(e))
+	}
+	iq.notFull.Broadcast()
+	return values
+}
+
+// Close rejects the next values, the queued values are left for Drain
+func (iq *Queue) Close() {
+	iq.mtx.Lock()
+	defer iq.mtx.Unlock()
+	if iq.isClosed {
+		return
 	}
+	iq.isClosed = true
+	iq.notFull.Broadcast()
 }
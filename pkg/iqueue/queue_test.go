@@ -0,0 +1,118 @@
+package iqueue
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestQueue_Overflow(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name            string
+		overflow        Overflow
+		expectedErr     error
+		expectedValues  []int
+		expectedDropped []int
+	}{
+		{
+			name:           "positive_block_timeout",
+			overflow:       OverflowBlock,
+			expectedErr:    ErrFull,
+			expectedValues: []int{1, 2},
+		},
+		{
+			name:            "positive_drop_oldest",
+			overflow:        OverflowDropOldest,
+			expectedValues:  []int{2, 3},
+			expectedDropped: []int{1},
+		},
+		{
+			name:            "positive_drop_newest",
+			overflow:        OverflowDropNewest,
+			expectedValues:  []int{1, 2},
+			expectedDropped: []int{3},
+		},
+		{
+			name:           "negative_reject",
+			overflow:       OverflowReject,
+			expectedErr:    ErrFull,
+			expectedValues: []int{1, 2},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			var dropped []int
+			q := New(
+				WithCap(2),
+				WithOverflow(test.overflow),
+				WithBlockTimeout(10*time.Millisecond),
+				WithOnDrop(func(v interface{}) {
+					dropped = append(dropped, v.(int))
+				}),
+			)
+			for _, v := range []int{1, 2} {
+				if err := q.Send(v); err != nil {
+					t.Fatalf("send %d: %v", v, err)
+				}
+			}
+			if err := q.Send(3); !errors.Is(err, test.expectedErr) {
+				t.Fatalf("send to full queue, got: %v, expected: %v", err, test.expectedErr)
+			}
+
+			values := q.Drain()
+			if len(values) != len(test.expectedValues) {
+				t.Fatalf("values, got: %v, expected: %v", values, test.expectedValues)
+			}
+			for i := range values {
+				if values[i].(int) != test.expectedValues[i] {
+					t.Errorf("values, got: %v, expected: %v", values, test.expectedValues)
+				}
+			}
+			if len(dropped) != len(test.expectedDropped) || (len(dropped) > 0 && dropped[0] != test.expectedDropped[0]) {
+				t.Errorf("dropped, got: %v, expected: %v", dropped, test.expectedDropped)
+			}
+		})
+	}
+}
+
+func TestQueue_Pop(t *testing.T) {
+	t.Parallel()
+	sent := 0
+	q := New(WithCap(1), WithOverflow(OverflowBlock), WithOnSend(func() {
+		sent++
+	}))
+	if _, ok := q.Pop(); ok {
+		t.Fatalf("pop from empty queue")
+	}
+	if err := q.Send(1); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	// the blocked send continues when the value is popped
+	done := make(chan error)
+	go func() {
+		done <- q.Send(2)
+	}()
+	for _, expected := range []int{1, 2} {
+		if expected == 2 {
+			if err := <-done; err != nil {
+				t.Fatalf("send: %v", err)
+			}
+		}
+		v, ok := q.Pop()
+		if !ok || v.(int) != expected {
+			t.Errorf("pop, got: %v, expected: %d", v, expected)
+		}
+	}
+	if sent != 2 {
+		t.Errorf("on send calls, got: %d, expected: 2", sent)
+	}
+
+	q.Close()
+	if err := q.Send(1); !errors.Is(err, ErrClosed) {
+		t.Errorf("send to closed queue, got: %v, expected: %v", err, ErrClosed)
+	}
+}
@@ -0,0 +1,246 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package sodpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// SodClient is the client API for Sod service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SodClient interface {
+	// Collect queues the points of the stream for the detection
+	Collect(ctx context.Context, opts ...grpc.CallOption) (Sod_CollectClient, error)
+	// Predict checks the points without storing them
+	Predict(ctx context.Context, in *PredictRequest, opts ...grpc.CallOption) (*PredictResponse, error)
+	// PredictStream answers every request of the stream with the conclusions of its points
+	PredictStream(ctx context.Context, opts ...grpc.CallOption) (Sod_PredictStreamClient, error)
+}
+
+type sodClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSodClient(cc grpc.ClientConnInterface) SodClient {
+	return &sodClient{cc}
+}
+
+func (c *sodClient) Collect(ctx context.Context, opts ...grpc.CallOption) (Sod_CollectClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Sod_ServiceDesc.Streams[0], "/sod.v1.Sod/Collect", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &sodCollectClient{stream}
+	return x, nil
+}
+
+type Sod_CollectClient interface {
+	Send(*CollectRequest) error
+	CloseAndRecv() (*CollectResponse, error)
+	grpc.ClientStream
+}
+
+type sodCollectClient struct {
+	grpc.ClientStream
+}
+
+func (x *sodCollectClient) Send(m *CollectRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *sodCollectClient) CloseAndRecv() (*CollectResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(CollectResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *sodClient) Predict(ctx context.Context, in *PredictRequest, opts ...grpc.CallOption) (*PredictResponse, error) {
+	out := new(PredictResponse)
+	err := c.cc.Invoke(ctx, "/sod.v1.Sod/Predict", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sodClient) PredictStream(ctx context.Context, opts ...grpc.CallOption) (Sod_PredictStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Sod_ServiceDesc.Streams[1], "/sod.v1.Sod/PredictStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &sodPredictStreamClient{stream}
+	return x, nil
+}
+
+type Sod_PredictStreamClient interface {
+	Send(*PredictRequest) error
+	Recv() (*PredictResponse, error)
+	grpc.ClientStream
+}
+
+type sodPredictStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *sodPredictStreamClient) Send(m *PredictRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *sodPredictStreamClient) Recv() (*PredictResponse, error) {
+	m := new(PredictResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SodServer is the server API for Sod service.
+// All implementations must embed UnimplementedSodServer
+// for forward compatibility
+type SodServer interface {
+	// Collect queues the points of the stream for the detection
+	Collect(Sod_CollectServer) error
+	// Predict checks the points without storing them
+	Predict(context.Context, *PredictRequest) (*PredictResponse, error)
+	// PredictStream answers every request of the stream with the conclusions of its points
+	PredictStream(Sod_PredictStreamServer) error
+	mustEmbedUnimplementedSodServer()
+}
+
+// UnimplementedSodServer must be embedded to have forward compatible implementations.
+type UnimplementedSodServer struct {
+}
+
+func (UnimplementedSodServer) Collect(Sod_CollectServer) error {
+	return status.Errorf(codes.Unimplemented, "method Collect not implemented")
+}
+func (UnimplementedSodServer) Predict(context.Context, *PredictRequest) (*PredictResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Predict not implemented")
+}
+func (UnimplementedSodServer) PredictStream(Sod_PredictStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method PredictStream not implemented")
+}
+func (UnimplementedSodServer) mustEmbedUnimplementedSodServer() {}
+
+// UnsafeSodServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SodServer will
+// result in compilation errors.
+type UnsafeSodServer interface {
+	mustEmbedUnimplementedSodServer()
+}
+
+func RegisterSodServer(s grpc.ServiceRegistrar, srv SodServer) {
+	s.RegisterService(&Sod_ServiceDesc, srv)
+}
+
+func _Sod_Collect_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(SodServer).Collect(&sodCollectServer{stream})
+}
+
+type Sod_CollectServer interface {
+	SendAndClose(*CollectResponse) error
+	Recv() (*CollectRequest, error)
+	grpc.ServerStream
+}
+
+type sodCollectServer struct {
+	grpc.ServerStream
+}
+
+func (x *sodCollectServer) SendAndClose(m *CollectResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *sodCollectServer) Recv() (*CollectRequest, error) {
+	m := new(CollectRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Sod_Predict_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PredictRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SodServer).Predict(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sod.v1.Sod/Predict",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SodServer).Predict(ctx, req.(*PredictRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sod_PredictStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(SodServer).PredictStream(&sodPredictStreamServer{stream})
+}
+
+type Sod_PredictStreamServer interface {
+	Send(*PredictResponse) error
+	Recv() (*PredictRequest, error)
+	grpc.ServerStream
+}
+
+type sodPredictStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *sodPredictStreamServer) Send(m *PredictResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *sodPredictStreamServer) Recv() (*PredictRequest, error) {
+	m := new(PredictRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Sod_ServiceDesc is the grpc.ServiceDesc for Sod service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Sod_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "sod.v1.Sod",
+	HandlerType: (*SodServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Predict",
+			Handler:    _Sod_Predict_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Collect",
+			Handler:       _Sod_Collect_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "PredictStream",
+			Handler:       _Sod_PredictStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "pkg/sodpb/sod.proto",
+}
@@ -0,0 +1,273 @@
+// Package webhook signs the SOD alert webhooks and verifies them on the receiver side.
+//
+// The signature is the hex HMAC-SHA256 of "<timestamp>.<delivery id>.<body>" with the shared secret of the target,
+// it is sent in the header "X-Sod-Signature: t=<unix timestamp>,v1=<signature>"
+// together with the delivery id in "X-Sod-Delivery". The retries of the alert have the same delivery id.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Sod-Signature"
+	DeliveryHeader  = "X-Sod-Delivery"
+
+	signatureVersion = "v1"
+
+	DefaultTolerance = 5 * time.Minute
+)
+
+var (
+	ErrMissingHeader    = errors.New("webhook signature headers are missing")
+	ErrInvalidHeader    = errors.New("webhook signature header is malformed")
+	ErrInvalidSignature = errors.New("webhook signature does not match")
+	ErrExpired          = errors.New("webhook timestamp is out of the tolerance")
+	ErrReplayed         = errors.New("webhook delivery is already received")
+	ErrInProgress       = errors.New("webhook delivery is being handled")
+)
+
+// Sign returns the signature of the body
+func Sign(secret []byte, timestamp time.Time, deliveryID string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	_, _ = fmt.Fprintf(mac, "%d.%s.", timestamp.Unix(), deliveryID)
+	_, _ = mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignRequest sets the signature headers of the request with the body
+func SignRequest(req *http.Request, secret []byte, deliveryID string, body []byte) {
+	timestamp := time.Now()
+	req.Header.Set(DeliveryHeader, deliveryID)
+	req.Header.Set(SignatureHeader, fmt.Sprintf(
+		"t=%d,%s=%s", timestamp.Unix(), signatureVersion, Sign(secret, timestamp, deliveryID, body),
+	))
+}
+
+type Option func(*Verifier)
+
+// WithTolerance sets the max difference between the signature timestamp and the receiver clock
+func WithTolerance(d time.Duration) Option {
+	return func(v *Verifier) {
+		v.tolerance = d
+	}
+}
+
+// WithoutReplayProtection accepts the repeated delivery ids, e.g. if the receiver deduplicates the alerts itself
+func WithoutReplayProtection() Option {
+	return func(v *Verifier) {
+		v.seen = nil
+	}
+}
+
+func withClock(fn func() time.Time) Option {
+	return func(v *Verifier) {
+		v.now = fn
+	}
+}
+
+// NewVerifier returns the verifier of the webhooks signed with one of the secrets,
+// several secrets allow to rotate the secret without rejecting the deliveries
+func NewVerifier(secrets [][]byte, opts ...Option) *Verifier {
+	v := &Verifier{
+		secrets:   secrets,
+		tolerance: DefaultTolerance,
+		now:       time.Now,
+		seen:      map[string]delivery{},
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+type Verifier struct {
+	mtx       sync.Mutex
+	secrets   [][]byte
+	tolerance time.Duration
+	now       func() time.Time
+	// delivery ids received within the tolerance
+	seen map[string]delivery
+}
+
+type delivery struct {
+	receivedAt time.Time
+	// done is false until the delivery is handled by the receiver
+	done bool
+}
+
+// Verify checks the signature headers of the body, the delivery id is accepted once within the tolerance.
+// The accepted id is reserved until Complete or Release is called: the reserved id gets ErrInProgress,
+// the completed one gets ErrReplayed and the released one is accepted again, e.g. with the retry of the failed delivery
+func (v *Verifier) Verify(header http.Header, body []byte) error {
+	deliveryID := header.Get(DeliveryHeader)
+	signatureHeader := header.Get(SignatureHeader)
+	if deliveryID == "" || signatureHeader == "" {
+		return ErrMissingHeader
+	}
+	timestamp, signatures, err := parseSignatureHeader(signatureHeader)
+	if err != nil {
+		return err
+	}
+
+	now := v.now()
+	if diff := now.Sub(timestamp); diff > v.tolerance || diff < -v.tolerance {
+		return ErrExpired
+	}
+
+	if !v.matches(timestamp, deliveryID, body, signatures) {
+		return ErrInvalidSignature
+	}
+
+	if v.seen == nil {
+		return nil
+	}
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	for id, d := range v.seen {
+		if now.Sub(d.receivedAt) > 2*v.tolerance {
+			delete(v.seen, id)
+		}
+	}
+	if d, ok := v.seen[deliveryID]; ok {
+		if d.done {
+			return ErrReplayed
+		}
+		return ErrInProgress
+	}
+	v.seen[deliveryID] = delivery{receivedAt: now}
+	return nil
+}
+
+// Complete marks the delivery as handled, the repeated delivery gets ErrReplayed
+func (v *Verifier) Complete(deliveryID string) {
+	if v.seen == nil {
+		return
+	}
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	if d, ok := v.seen[deliveryID]; ok {
+		d.done = true
+		v.seen[deliveryID] = d
+	}
+}
+
+// Release forgets the delivery not handled by the receiver, so its retry is accepted
+func (v *Verifier) Release(deliveryID string) {
+	if v.seen == nil {
+		return
+	}
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	delete(v.seen, deliveryID)
+}
+
+// VerifyRequest checks the signature of the request, the body is restored for the next readers
+func (v *Verifier) VerifyRequest(r *http.Request) error {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return fmt.Errorf("unable read body: %w", err)
+	}
+	_ = r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return v.Verify(r.Header, body)
+}
+
+// Middleware rejects the requests without the valid signature with 401.
+// The delivery is completed when the next handler responds with 2xx, the repeated delivery is acknowledged
+// without calling the next handler so SOD stops retrying it. The delivery is released when the handler fails,
+// so its retry is handled again, and the retry of the delivery being handled gets 503
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := v.VerifyRequest(r)
+		switch {
+		case errors.Is(err, ErrReplayed):
+			w.WriteHeader(http.StatusOK)
+			return
+		case errors.Is(err, ErrInProgress):
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		deliveryID := r.Header.Get(DeliveryHeader)
+		rec := &statusRecorder{ResponseWriter: w}
+		completed := false
+		// the panicking handler releases the delivery too
+		defer func() {
+			if !completed {
+				v.Release(deliveryID)
+			}
+		}()
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 || (rec.status >= 200 && rec.status < 300) {
+			v.Complete(deliveryID)
+			completed = true
+		}
+	})
+}
+
+// statusRecorder keeps the status of the response, 0 until the header is written
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (v *Verifier) matches(timestamp time.Time, deliveryID string, body []byte, signatures []string) bool {
+	for _, secret := range v.secrets {
+		expected := Sign(secret, timestamp, deliveryID, body)
+		for _, signature := range signatures {
+			if hmac.Equal([]byte(expected), []byte(signature)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func parseSignatureHeader(header string) (time.Time, []string, error) {
+	var (
+		timestamp  time.Time
+		signatures []string
+	)
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			return time.Time{}, nil, ErrInvalidHeader
+		}
+		switch kv[0] {
+		case "t":
+			sec, err := strconv.ParseInt(kv[1], 10, 64)
+			if err != nil {
+				return time.Time{}, nil, ErrInvalidHeader
+			}
+			timestamp = time.Unix(sec, 0)
+		case signatureVersion:
+			signatures = append(signatures, kv[1])
+		}
+	}
+	if timestamp.IsZero() || len(signatures) == 0 {
+		return time.Time{}, nil, ErrInvalidHeader
+	}
+	return timestamp, signatures, nil
+}
@@ -0,0 +1,137 @@
+package webhook
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestVerifier_Verify(t *testing.T) {
+	t.Parallel()
+	secret := []byte("secret")
+	body := []byte(`{"entityId":"test"}`)
+	now := time.Unix(1600000000, 0)
+	header := func(secret []byte, timestamp time.Time, deliveryID string, body []byte) http.Header {
+		h := http.Header{}
+		h.Set(DeliveryHeader, deliveryID)
+		h.Set(SignatureHeader, fmt.Sprintf("t=%d,v1=%s", timestamp.Unix(), Sign(secret, timestamp, deliveryID, body)))
+		return h
+	}
+	tests := []struct {
+		name     string
+		header   http.Header
+		body     []byte
+		expected error
+	}{
+		{
+			name:   "positive_valid",
+			header: header(secret, now, "1", body),
+			body:   body,
+		},
+		{
+			name:   "positive_rotated_secret",
+			header: header([]byte("old"), now, "1", body),
+			body:   body,
+		},
+		{
+			name:     "negative_modified_body",
+			header:   header(secret, now, "1", body),
+			body:     []byte(`{"entityId":"other"}`),
+			expected: ErrInvalidSignature,
+		},
+		{
+			name:     "negative_wrong_secret",
+			header:   header([]byte("wrong"), now, "1", body),
+			body:     body,
+			expected: ErrInvalidSignature,
+		},
+		{
+			name:     "negative_expired",
+			header:   header(secret, now.Add(-time.Hour), "1", body),
+			body:     body,
+			expected: ErrExpired,
+		},
+		{
+			name:     "negative_missing_header",
+			header:   http.Header{},
+			body:     body,
+			expected: ErrMissingHeader,
+		},
+		{
+			name: "negative_malformed_header",
+			header: http.Header{
+				DeliveryHeader:  []string{"1"},
+				SignatureHeader: []string{"v1=abc"},
+			},
+			body:     body,
+			expected: ErrInvalidHeader,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			v := NewVerifier([][]byte{secret, []byte("old")}, withClock(func() time.Time { return now }))
+			if err := v.Verify(test.header, test.body); !errors.Is(err, test.expected) {
+				t.Errorf("verify, got: %v, expected: %v", err, test.expected)
+			}
+		})
+	}
+}
+
+func TestVerifier_Replay(t *testing.T) {
+	t.Parallel()
+	secret := []byte("secret")
+	body := []byte(`{}`)
+	req, err := http.NewRequest("POST", "http://localhost", nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	SignRequest(req, secret, "delivery", body)
+	v := NewVerifier([][]byte{secret})
+	if err := v.Verify(req.Header, body); err != nil {
+		t.Fatalf("first delivery: %v", err)
+	}
+	if err := v.Verify(req.Header, body); !errors.Is(err, ErrInProgress) {
+		t.Errorf("delivery being handled, got: %v, expected: %v", err, ErrInProgress)
+	}
+	// the failed delivery is accepted again
+	v.Release("delivery")
+	if err := v.Verify(req.Header, body); err != nil {
+		t.Fatalf("released delivery: %v", err)
+	}
+	v.Complete("delivery")
+	if err := v.Verify(req.Header, body); !errors.Is(err, ErrReplayed) {
+		t.Errorf("repeated delivery, got: %v, expected: %v", err, ErrReplayed)
+	}
+}
+
+func TestVerifier_Middleware(t *testing.T) {
+	t.Parallel()
+	secret := []byte("secret")
+	body := []byte(`{}`)
+	statuses := []int{http.StatusInternalServerError, http.StatusOK}
+	calls := 0
+	handler := NewVerifier([][]byte{secret}).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(statuses[calls])
+		calls++
+	}))
+
+	// the retry of the failed delivery is handled, the retry of the handled one is acknowledged
+	for i, expected := range []int{http.StatusInternalServerError, http.StatusOK, http.StatusOK} {
+		req := httptest.NewRequest("POST", "http://localhost/hook", bytes.NewReader(body))
+		SignRequest(req, secret, "delivery", body)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != expected {
+			t.Errorf("attempt %d status, got: %d, expected: %d", i, rec.Code, expected)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("handler calls, got: %d, expected: 2", calls)
+	}
+}
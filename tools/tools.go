@@ -11,4 +11,10 @@ import (
 
 	//go:generate go install github.com/client9/misspell/cmd/misspell
 	_ "github.com/client9/misspell/cmd/misspell"
+
+	//go:generate go install google.golang.org/protobuf/cmd/protoc-gen-go
+	_ "google.golang.org/protobuf/cmd/protoc-gen-go"
+
+	//go:generate go install google.golang.org/grpc/cmd/protoc-gen-go-grpc
+	_ "google.golang.org/grpc/cmd/protoc-gen-go-grpc"
 )